@@ -0,0 +1,118 @@
+package database
+
+import (
+	"log"
+	"time"
+
+	"FeaturePlus/models"
+
+	"gorm.io/gorm"
+)
+
+// legacySubFeature mirrors the old sub_features table so its rows can be read during migration
+type legacySubFeature struct {
+	ID          uint
+	FeatureID   uint
+	Title       string
+	Description string
+	Status      string
+	Priority    string
+	AssigneeID  uint
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (legacySubFeature) TableName() string {
+	return "sub_features"
+}
+
+// MigrateSubFeatures folds the old sub_features table into the feature tree. Every
+// row becomes a feature whose parent is the row's feature, tasks that pointed at the
+// sub-feature are moved onto the new feature, and the row is removed from the old
+// table. Rows whose feature no longer exists are logged and left in place, and the
+// old table and column are only dropped once no rows are left. It does nothing once
+// the sub_features table is gone.
+func (d *Database) MigrateSubFeatures() error {
+	if !d.DB.Migrator().HasTable(&legacySubFeature{}) {
+		return nil
+	}
+
+	return d.DB.Transaction(func(tx *gorm.DB) error {
+		var rows []legacySubFeature
+		if err := tx.Order("id").Find(&rows).Error; err != nil {
+			return err
+		}
+
+		hasTaskColumn := tx.Migrator().HasColumn(&models.Task{}, "sub_feature_id")
+
+		var orphans []uint
+		for _, row := range rows {
+			var parent models.Feature
+			if err := tx.Unscoped().Select("id", "project_id").First(&parent, row.FeatureID).Error; err != nil {
+				if err == gorm.ErrRecordNotFound {
+					// Nothing to attach it to, so keep it and its tasks for an operator to sort out
+					orphans = append(orphans, row.ID)
+					continue
+				}
+				return err
+			}
+
+			legacyID := row.ID
+			parentID := parent.ID
+			feature := models.Feature{
+				ProjectID:          parent.ProjectID,
+				ParentFeatureID:    &parentID,
+				Title:              row.Title,
+				Description:        row.Description,
+				Status:             normalizeLegacyStatus(row.Status),
+				Priority:           normalizeLegacyPriority(row.Priority),
+				AssigneeID:         row.AssigneeID,
+				CreatedAt:          row.CreatedAt,
+				UpdatedAt:          row.UpdatedAt,
+				LegacySubFeatureID: &legacyID,
+			}
+			if err := tx.Omit("Project", "Assignee", "ParentFeature", "Tags").Create(&feature).Error; err != nil {
+				return err
+			}
+
+			if hasTaskColumn {
+				if err := tx.Exec("UPDATE tasks SET feature_id = ?, sub_feature_id = 0 WHERE sub_feature_id = ?", feature.ID, row.ID).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Delete(&legacySubFeature{}, row.ID).Error; err != nil {
+				return err
+			}
+		}
+
+		if len(orphans) > 0 {
+			log.Printf("sub-feature migration: kept sub-features %v in the sub_features table because their feature no longer exists", orphans)
+			return nil
+		}
+
+		if hasTaskColumn {
+			if err := tx.Migrator().DropColumn(&models.Task{}, "sub_feature_id"); err != nil {
+				return err
+			}
+		}
+		return tx.Migrator().DropTable(&legacySubFeature{})
+	})
+}
+
+// normalizeLegacyStatus maps the free-text sub-feature status onto a feature status
+func normalizeLegacyStatus(status string) models.FeatureStatus {
+	switch models.FeatureStatus(status) {
+	case models.StatusTodo, models.StatusInProgress, models.StatusDone:
+		return models.FeatureStatus(status)
+	}
+	return models.StatusTodo
+}
+
+// normalizeLegacyPriority maps the free-text sub-feature priority onto a feature priority
+func normalizeLegacyPriority(priority string) models.FeaturePriority {
+	switch models.FeaturePriority(priority) {
+	case models.PriorityLow, models.PriorityMedium, models.PriorityHigh:
+		return models.FeaturePriority(priority)
+	}
+	return models.PriorityMedium
+}
@@ -0,0 +1,219 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+
+	"FeaturePlus/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// legacyTask is the tasks table as it was before the migration
+type legacyTask struct {
+	models.Task
+	SubFeatureID uint
+}
+
+func (legacyTask) TableName() string {
+	return "tasks"
+}
+
+// openLegacyDB returns an in-memory database laid out like one from before the
+// sub-feature migration, with a sub_features table and tasks.sub_feature_id
+func openLegacyDB(t *testing.T) *Database {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	d := &Database{DB: db}
+	if err := d.Migrate(&models.Feature{}, &legacyTask{}, &legacySubFeature{}); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestMigrateSubFeatures(t *testing.T) {
+	d := openLegacyDB(t)
+	db := d.DB
+
+	for _, feature := range []models.Feature{
+		{ProjectID: 7, Title: "Parent", Status: models.StatusTodo, Priority: models.PriorityLow},
+		{ProjectID: 8, Title: "Other", Status: models.StatusTodo, Priority: models.PriorityLow},
+	} {
+		if err := db.Omit("Project", "Assignee", "ParentFeature", "Tags").Create(&feature).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	rows := []legacySubFeature{
+		{ID: 1, FeatureID: 1, Title: "Kept", Description: "d", Status: "in_progress", Priority: "high", AssigneeID: 3},
+		{ID: 2, FeatureID: 2, Title: "Free text", Status: "blocked", Priority: "urgent"},
+	}
+	if err := db.Create(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, task := range []struct {
+		name         string
+		featureID    uint
+		subFeatureID uint
+	}{
+		{"on sub-feature 1", 1, 1},
+		{"on sub-feature 2", 2, 2},
+		{"on the feature", 1, 0},
+	} {
+		if err := db.Exec("INSERT INTO tasks (task_type, task_name, feature_id, sub_feature_id) VALUES ('dev', ?, ?, ?)",
+			task.name, task.featureID, task.subFeatureID).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := d.MigrateSubFeatures(); err != nil {
+		t.Fatal(err)
+	}
+
+	if db.Migrator().HasTable("sub_features") {
+		t.Error("sub_features table was not dropped")
+	}
+	if db.Migrator().HasColumn(&models.Task{}, "sub_feature_id") {
+		t.Error("tasks.sub_feature_id was not dropped")
+	}
+
+	var migrated []models.Feature
+	if err := db.Where("legacy_sub_feature_id IS NOT NULL").Order("legacy_sub_feature_id").Find(&migrated).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(migrated) != 2 {
+		t.Fatalf("migrated %d sub-features, want 2", len(migrated))
+	}
+
+	tests := []struct {
+		legacyID  uint
+		parentID  uint
+		projectID int
+		title     string
+		status    models.FeatureStatus
+		priority  models.FeaturePriority
+		assignee  uint
+	}{
+		{1, 1, 7, "Kept", models.StatusInProgress, models.PriorityHigh, 3},
+		{2, 2, 8, "Free text", models.StatusTodo, models.PriorityMedium, 0},
+	}
+	for i, tt := range tests {
+		feature := migrated[i]
+		if *feature.LegacySubFeatureID != tt.legacyID {
+			t.Fatalf("feature %d has legacy ID %d, want %d", feature.ID, *feature.LegacySubFeatureID, tt.legacyID)
+		}
+		if feature.ParentFeatureID == nil || *feature.ParentFeatureID != tt.parentID {
+			t.Errorf("sub-feature %d: parent = %v, want %d", tt.legacyID, feature.ParentFeatureID, tt.parentID)
+		}
+		if feature.ProjectID != tt.projectID || feature.Title != tt.title || feature.Status != tt.status ||
+			feature.Priority != tt.priority || feature.AssigneeID != tt.assignee {
+			t.Errorf("sub-feature %d migrated as %+v", tt.legacyID, feature)
+		}
+
+		var task models.Task
+		if err := db.Where("task_name = ?", fmt.Sprintf("on sub-feature %d", tt.legacyID)).First(&task).Error; err != nil {
+			t.Fatal(err)
+		}
+		if task.FeatureID != feature.ID {
+			t.Errorf("task of sub-feature %d is on feature %d, want %d", tt.legacyID, task.FeatureID, feature.ID)
+		}
+	}
+
+	var untouched models.Task
+	if err := db.Where("task_name = ?", "on the feature").First(&untouched).Error; err != nil {
+		t.Fatal(err)
+	}
+	if untouched.FeatureID != 1 {
+		t.Errorf("task on the feature moved to feature %d", untouched.FeatureID)
+	}
+
+	// Once the table is gone the migration must do nothing
+	if err := d.MigrateSubFeatures(); err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	db.Model(&models.Feature{}).Count(&count)
+	if count != 4 {
+		t.Errorf("got %d features after a second run, want 4", count)
+	}
+}
+
+func TestMigrateSubFeaturesKeepsOrphans(t *testing.T) {
+	d := openLegacyDB(t)
+	db := d.DB
+
+	parent := models.Feature{ProjectID: 7, Title: "Parent", Status: models.StatusTodo, Priority: models.PriorityLow}
+	if err := db.Omit("Project", "Assignee", "ParentFeature", "Tags").Create(&parent).Error; err != nil {
+		t.Fatal(err)
+	}
+	rows := []legacySubFeature{
+		{ID: 1, FeatureID: parent.ID, Title: "Attached", Status: "todo", Priority: "low"},
+		{ID: 2, FeatureID: 99, Title: "Orphan", Status: "todo", Priority: "low"},
+	}
+	if err := db.Create(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec("INSERT INTO tasks (task_type, task_name, feature_id, sub_feature_id) VALUES ('dev', 'on the orphan', 99, 2)").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.MigrateSubFeatures(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The orphan and its task link must survive until its feature is back
+	if !db.Migrator().HasColumn(&models.Task{}, "sub_feature_id") {
+		t.Fatal("tasks.sub_feature_id was dropped while a sub-feature was left unmigrated")
+	}
+	var left []uint
+	if err := db.Table("sub_features").Pluck("id", &left).Error; err != nil {
+		t.Fatalf("sub_features table was dropped while a sub-feature was left unmigrated: %v", err)
+	}
+	if len(left) != 1 || left[0] != 2 {
+		t.Fatalf("sub_features holds %v, want only the orphan 2", left)
+	}
+	var subFeatureID uint
+	if err := db.Table("tasks").Where("task_name = ?", "on the orphan").Pluck("sub_feature_id", &subFeatureID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if subFeatureID != 2 {
+		t.Errorf("orphan's task points at sub-feature %d, want 2", subFeatureID)
+	}
+
+	// Once the feature exists again the next run finishes the job
+	restored := models.Feature{ID: 99, ProjectID: 7, Title: "Restored", Status: models.StatusTodo, Priority: models.PriorityLow}
+	if err := db.Omit("Project", "Assignee", "ParentFeature", "Tags").Create(&restored).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := d.MigrateSubFeatures(); err != nil {
+		t.Fatal(err)
+	}
+	if db.Migrator().HasTable("sub_features") {
+		t.Error("sub_features table was not dropped once every row was migrated")
+	}
+
+	var features []models.Feature
+	if err := db.Where("legacy_sub_feature_id IS NOT NULL").Order("legacy_sub_feature_id").Find(&features).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(features) != 2 || *features[0].LegacySubFeatureID != 1 || *features[1].LegacySubFeatureID != 2 {
+		t.Fatalf("got %d migrated features, want sub-features 1 and 2 each migrated once", len(features))
+	}
+	var task models.Task
+	if err := db.Where("task_name = ?", "on the orphan").First(&task).Error; err != nil {
+		t.Fatal(err)
+	}
+	if task.FeatureID != features[1].ID {
+		t.Errorf("orphan's task is on feature %d, want %d", task.FeatureID, features[1].ID)
+	}
+}
@@ -0,0 +1,42 @@
+package database
+
+import (
+	"FeaturePlus/models"
+
+	"gorm.io/gorm"
+)
+
+// MigrateFeatureTags gives every feature_tags row from before tags were entities a
+// tag in its feature's project, creating one tag per project and name. It does
+// nothing once every row has a tag.
+func (d *Database) MigrateFeatureTags() error {
+	return d.DB.Transaction(func(tx *gorm.DB) error {
+		var rows []struct {
+			TagName   string
+			ProjectID int
+		}
+		err := tx.Table("feature_tags").
+			Select("DISTINCT feature_tags.tag_name, features.project_id").
+			Joins("JOIN features ON features.id = feature_tags.feature_id").
+			Where("feature_tags.tag_id = 0").
+			Scan(&rows).Error
+		if err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			tag := models.Tag{ProjectID: row.ProjectID, Name: row.TagName}
+			if err := tx.Where(tag).FirstOrCreate(&tag).Error; err != nil {
+				return err
+			}
+			err := tx.Model(&models.FeatureTag{}).
+				Where("tag_id = 0 AND tag_name = ? AND feature_id IN (?)", row.TagName,
+					tx.Unscoped().Model(&models.Feature{}).Select("id").Where("project_id = ?", row.ProjectID)).
+				UpdateColumn("tag_id", tag.ID).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"FeaturePlus/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MoveInBacklog sends a feature to the top or bottom of its project's backlog, or
+// places it ahead of before_id or after after_id
+func (h *FeatureHandler) MoveInBacklog(c *gin.Context) {
+	projectID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		return
+	}
+
+	var request struct {
+		FeatureID uint   `json:"feature_id" binding:"required"`
+		Position  string `json:"position"`
+		BeforeID  *uint  `json:"before_id"`
+		AfterID   *uint  `json:"after_id"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	given := 0
+	for _, set := range []bool{request.Position != "", request.BeforeID != nil, request.AfterID != nil} {
+		if set {
+			given++
+		}
+	}
+	if given != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "give exactly one of position, before_id or after_id"})
+		return
+	}
+	if request.Position != "" && request.Position != repositories.BacklogTop && request.Position != repositories.BacklogBottom {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "position must be top or bottom"})
+		return
+	}
+	if (request.BeforeID != nil && *request.BeforeID == request.FeatureID) || (request.AfterID != nil && *request.AfterID == request.FeatureID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a feature cannot be placed next to itself"})
+		return
+	}
+
+	feature, err := h.repo.GetFeatureByID(int(request.FeatureID))
+	if err != nil || feature.ProjectID != projectID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "feature not found"})
+		return
+	}
+
+	if err := h.repo.MoveInBacklog(feature, request.Position, request.BeforeID, request.AfterID); err != nil {
+		if errors.Is(err, repositories.ErrNotInBacklog) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ReorderBacklog puts the features listed in ids in that order within the places
+// they hold in the backlog, and returns the whole backlog order
+func (h *FeatureHandler) ReorderBacklog(c *gin.Context) {
+	projectID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		return
+	}
+
+	var request struct {
+		IDs []uint `json:"ids" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	seen := make(map[uint]bool, len(request.IDs))
+	for _, id := range request.IDs {
+		if seen[id] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "feature " + strconv.Itoa(int(id)) + " is listed twice"})
+			return
+		}
+		seen[id] = true
+	}
+
+	order, err := h.repo.ReorderBacklog(projectID, request.IDs)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotInBacklog) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ids": order})
+}
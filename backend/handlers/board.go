@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"FeaturePlus/models"
+	"FeaturePlus/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetBoard returns one column per workflow status with its cards in board order
+func (h *FeatureHandler) GetBoard(c *gin.Context) {
+	projectID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		return
+	}
+
+	workflow, err := h.workflowRepo.GetWorkflow(projectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	features, err := h.repo.GetBoardFeatures(projectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewBoard(projectID, workflow, features))
+}
+
+// MoveCard changes a feature's status and its place in the column in one step. The
+// card goes ahead of before_id, after after_id, or to the bottom of the column.
+func (h *FeatureHandler) MoveCard(c *gin.Context) {
+	projectID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		return
+	}
+
+	var request struct {
+		FeatureID uint                 `json:"feature_id" binding:"required"`
+		Status    models.FeatureStatus `json:"status"`
+		BeforeID  *uint                `json:"before_id"`
+		AfterID   *uint                `json:"after_id"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if request.BeforeID != nil && request.AfterID != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "give before_id or after_id, not both"})
+		return
+	}
+	if (request.BeforeID != nil && *request.BeforeID == request.FeatureID) || (request.AfterID != nil && *request.AfterID == request.FeatureID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a card cannot be placed next to itself"})
+		return
+	}
+
+	feature, err := h.repo.GetFeatureByID(int(request.FeatureID))
+	if err != nil || feature.ProjectID != projectID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "feature not found"})
+		return
+	}
+
+	if !checkIfMatch(c, feature.Version) {
+		return
+	}
+
+	status := request.Status
+	if status == "" {
+		status = feature.Status
+	}
+	// The WIP limit of a new status is checked by MoveCard itself
+	var workflow *models.Workflow
+	if status != feature.Status {
+		if !h.checkStatusChange(c, feature, status, feature.AssigneeID) {
+			return
+		}
+		if workflow, err = h.workflowRepo.GetWorkflow(projectID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	warning, err := h.repo.MoveCard(feature, status, workflow, request.BeforeID, request.AfterID)
+	if err != nil {
+		var wipErr *repositories.WIPLimitError
+		if errors.As(err, &wipErr) {
+			respondCheckError(c, err)
+			return
+		}
+		if errors.Is(err, repositories.ErrCardNotInColumn) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, repositories.ErrVersionConflict) {
+			respondVersionConflict(c)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status != feature.Status {
+		if err := completeParents(h.repo, feature.ParentFeatureID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Card moved but failed to complete parent features"})
+			return
+		}
+	}
+
+	moved, err := h.repo.GetFeatureByID(int(feature.ID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := gin.H{"feature": moved}
+	if warning != "" {
+		response["wip_warning"] = warning
+	}
+	setETag(c, moved.Version)
+	c.JSON(http.StatusOK, response)
+}
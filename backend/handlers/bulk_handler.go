@@ -0,0 +1,569 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"FeaturePlus/models"
+	"FeaturePlus/repositories"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxBulkItems caps how many items a single bulk request may touch
+const maxBulkItems = 500
+
+// errBulkRollback aborts the bulk transaction after a dry run or a failed item
+var errBulkRollback = errors.New("bulk operation rolled back")
+
+type BulkHandler struct {
+	db *gorm.DB
+}
+
+func NewBulkHandler(db *gorm.DB) *BulkHandler {
+	return &BulkHandler{db: db}
+}
+
+type bulkOperation struct {
+	Op    string          `json:"op"`
+	Value json.RawMessage `json:"value"`
+}
+
+type bulkItemResult struct {
+	ID      uint                   `json:"id"`
+	Status  string                 `json:"status"`
+	Changes map[string]interface{} `json:"changes,omitempty"`
+	Error   string                 `json:"error,omitempty"`
+}
+
+type bulkResponse struct {
+	DryRun    bool             `json:"dry_run"`
+	Applied   bool             `json:"applied"`
+	Matched   int              `json:"matched"`
+	Succeeded int              `json:"succeeded"`
+	Failed    int              `json:"failed"`
+	Results   []bulkItemResult `json:"results"`
+}
+
+// record adds an item outcome to the report
+func (r *bulkResponse) record(id uint, changes map[string]interface{}, err error) {
+	if err != nil {
+		r.Failed++
+		r.Results = append(r.Results, bulkItemResult{ID: id, Status: "error", Error: err.Error()})
+		return
+	}
+	r.Succeeded++
+	r.Results = append(r.Results, bulkItemResult{ID: id, Status: "ok", Changes: changes})
+}
+
+// respond writes the report; nothing is committed unless every item succeeded
+func (r *bulkResponse) respond(c *gin.Context) {
+	if r.Failed > 0 {
+		c.JSON(http.StatusUnprocessableEntity, r)
+		return
+	}
+	c.JSON(http.StatusOK, r)
+}
+
+// validateBulkOperations checks the shape of the operation list shared by all bulk endpoints
+func validateBulkOperations(operations []bulkOperation) error {
+	if len(operations) == 0 {
+		return errors.New("at least one operation is required")
+	}
+	for i, operation := range operations {
+		if operation.Op == "delete" && i != len(operations)-1 {
+			return errors.New("delete must be the last operation")
+		}
+	}
+	return nil
+}
+
+// featureBulkContext gives each step access to repositories bound to the transaction
+type featureBulkContext struct {
+	features   *repositories.FeatureRepository
+	tags       *repositories.TagRepository
+	users      *repositories.UserRepository
+	workflows  *repositories.WorkflowRepository
+	milestones *repositories.MilestoneRepository
+	userID     uint
+
+	// workflowCache holds each project's workflow once it has been loaded
+	workflowCache map[int]*models.Workflow
+}
+
+// workflow returns a project's workflow, loading it once per request
+func (ctx *featureBulkContext) workflow(projectID int) (*models.Workflow, error) {
+	if workflow, ok := ctx.workflowCache[projectID]; ok {
+		return workflow, nil
+	}
+	workflow, err := ctx.workflows.GetWorkflow(projectID)
+	if err != nil {
+		return nil, err
+	}
+	ctx.workflowCache[projectID] = workflow
+	return workflow, nil
+}
+
+// featureBulkStep applies one operation to a single feature. Column changes go into
+// updates, and everything that changed is reported through changes.
+type featureBulkStep func(ctx *featureBulkContext, feature *models.Feature, updates map[string]interface{}, changes map[string]interface{}) error
+
+type featureBulkRequest struct {
+	IDs        []uint                      `json:"ids"`
+	Filter     *repositories.FeatureFilter `json:"filter"`
+	Operations []bulkOperation             `json:"operations"`
+	DryRun     bool                        `json:"dry_run"`
+}
+
+// BulkFeatures applies a list of operations to many features in one transaction.
+// Features are picked either by ID or by filter. With dry_run the transaction is
+// always rolled back, so the report shows what would happen.
+func (h *BulkHandler) BulkFeatures(c *gin.Context) {
+	var request featureBulkRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if (len(request.IDs) == 0) == (request.Filter == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "exactly one of ids or filter is required"})
+		return
+	}
+	if request.Filter != nil && request.Filter.ProjectID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "filter.project_id is required"})
+		return
+	}
+	if err := validateBulkOperations(request.Operations); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	steps := make([]featureBulkStep, 0, len(request.Operations))
+	deletes := false
+	for _, operation := range request.Operations {
+		step, err := buildFeatureBulkStep(operation)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		steps = append(steps, step)
+		deletes = deletes || operation.Op == "delete"
+	}
+
+	userID := currentUserID(c)
+
+	response := bulkResponse{DryRun: request.DryRun, Results: []bulkItemResult{}}
+	var internalErr error
+	var tooMany bool
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		ctx := &featureBulkContext{
+			features:      repositories.NewFeatureRepository(tx),
+			tags:          repositories.NewTagRepository(tx),
+			users:         repositories.NewUserRepository(tx),
+			workflows:     repositories.NewWorkflowRepository(tx),
+			milestones:    repositories.NewMilestoneRepository(tx),
+			userID:        userID,
+			workflowCache: map[int]*models.Workflow{},
+		}
+
+		ids := request.IDs
+		if request.Filter != nil {
+			matched, err := ctx.features.FindFeatures(*request.Filter)
+			if err != nil {
+				internalErr = err
+				return err
+			}
+			ids = make([]uint, 0, len(matched))
+			for _, feature := range matched {
+				ids = append(ids, feature.ID)
+			}
+		}
+		if len(ids) > maxBulkItems {
+			tooMany = true
+			return errBulkRollback
+		}
+		response.Matched = len(ids)
+
+		for _, id := range ids {
+			changes, err := applyFeatureBulkSteps(ctx, id, steps, deletes)
+			response.record(id, changes, err)
+		}
+
+		if request.DryRun || response.Failed > 0 {
+			return errBulkRollback
+		}
+		return nil
+	})
+
+	if tooMany {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("a bulk request may touch at most %d features", maxBulkItems)})
+		return
+	}
+	if internalErr != nil || (err != nil && !errors.Is(err, errBulkRollback)) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "bulk operation failed"})
+		return
+	}
+
+	response.Applied = err == nil
+	response.respond(c)
+}
+
+// applyFeatureBulkSteps runs every step against one feature and persists the result
+func applyFeatureBulkSteps(ctx *featureBulkContext, id uint, steps []featureBulkStep, deletes bool) (map[string]interface{}, error) {
+	feature, err := ctx.features.GetFeatureByID(int(id))
+	if err != nil {
+		return nil, errors.New("feature not found")
+	}
+
+	previousParentID := feature.ParentFeatureID
+	previousStatus := feature.Status
+	updates := map[string]interface{}{}
+	changes := map[string]interface{}{}
+	for _, step := range steps {
+		if err := step(ctx, feature, updates, changes); err != nil {
+			return nil, err
+		}
+	}
+
+	// Checked after every step so a reassign can satisfy a transition's guard
+	if _, ok := updates["status"]; ok {
+		workflow, err := ctx.workflow(feature.ProjectID)
+		if err != nil {
+			return nil, err
+		}
+		if err := validateStatusChange(workflow, previousStatus, feature.Status, feature.AssigneeID); err != nil {
+			return nil, err
+		}
+		blockers, err := openBlockersPreventingDone(ctx.features, feature, workflow, previousStatus, feature.Status)
+		if err != nil {
+			return nil, err
+		}
+		if len(blockers) > 0 {
+			return nil, errors.New(blockedMessage(blockers))
+		}
+		if feature.Status != previousStatus {
+			if _, err := ctx.features.CheckWIPLimit(workflow, feature.ID, feature.Status); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if deletes {
+		// Children of a deleted feature move up, unless they are deleted in the same request
+		if _, err := ctx.features.DeleteFeatureTree(id, feature.Version, false); err != nil {
+			return nil, err
+		}
+		if err := completeParents(ctx.features, previousParentID); err != nil {
+			return nil, err
+		}
+		return changes, nil
+	}
+
+	// Tag changes still need the version bumped, so touch the row
+	if len(changes) > 0 {
+		updates["updated_at"] = time.Now()
+	}
+	if err := ctx.features.PatchFeature(int(id), feature.Version, updates); err != nil {
+		return nil, err
+	}
+
+	_, statusChanged := updates["status"]
+	_, parentChanged := updates["parent_feature_id"]
+	if statusChanged || parentChanged {
+		if err := completeParents(ctx.features, previousParentID, feature.ParentFeatureID); err != nil {
+			return nil, err
+		}
+	}
+	return changes, nil
+}
+
+// buildFeatureBulkStep decodes and validates a single feature operation
+func buildFeatureBulkStep(operation bulkOperation) (featureBulkStep, error) {
+	switch operation.Op {
+	case "set_status":
+		var status models.FeatureStatus
+		if err := json.Unmarshal(operation.Value, &status); err != nil || status == "" {
+			return nil, errors.New("set_status: value must be a status from the project's workflow")
+		}
+		return func(ctx *featureBulkContext, feature *models.Feature, updates, changes map[string]interface{}) error {
+			feature.Status = status
+			updates["status"] = status
+			changes["status"] = status
+			return nil
+		}, nil
+
+	case "set_priority":
+		var priority models.FeaturePriority
+		if err := json.Unmarshal(operation.Value, &priority); err != nil || !isValidPriority(priority) {
+			return nil, errors.New("set_priority: value must be one of low, medium, high")
+		}
+		return func(ctx *featureBulkContext, feature *models.Feature, updates, changes map[string]interface{}) error {
+			feature.Priority = priority
+			updates["priority"] = priority
+			changes["priority"] = priority
+			return nil
+		}, nil
+
+	case "reassign":
+		var assigneeID uint
+		if !isNull(operation.Value) {
+			if err := json.Unmarshal(operation.Value, &assigneeID); err != nil {
+				return nil, errors.New("reassign: value must be a user ID or null")
+			}
+		}
+		return func(ctx *featureBulkContext, feature *models.Feature, updates, changes map[string]interface{}) error {
+			if assigneeID != 0 {
+				if _, err := ctx.users.GetUserByID(int(assigneeID)); err != nil {
+					return errors.New("assignee not found")
+				}
+			}
+			feature.AssigneeID = assigneeID
+			updates["assignee_id"] = assigneeID
+			changes["assignee_id"] = assigneeID
+			return nil
+		}, nil
+
+	case "add_tags", "remove_tags":
+		var tagNames []string
+		if err := json.Unmarshal(operation.Value, &tagNames); err != nil || len(tagNames) == 0 {
+			return nil, fmt.Errorf("%s: value must be a non-empty list of tag names", operation.Op)
+		}
+		add := operation.Op == "add_tags"
+		return func(ctx *featureBulkContext, feature *models.Feature, updates, changes map[string]interface{}) error {
+			var err error
+			if add {
+				err = ctx.tags.AddFeatureTags(feature.ID, ctx.userID, tagNames)
+			} else {
+				err = ctx.tags.RemoveFeatureTags(feature.ID, tagNames)
+			}
+			if err != nil {
+				return err
+			}
+			changes[operation.Op] = tagNames
+			return nil
+		}, nil
+
+	case "move":
+		var parentID *uint
+		if !isNull(operation.Value) {
+			var id uint
+			if err := json.Unmarshal(operation.Value, &id); err != nil || id == 0 {
+				return nil, errors.New("move: value must be a parent feature ID or null")
+			}
+			parentID = &id
+		}
+		return func(ctx *featureBulkContext, feature *models.Feature, updates, changes map[string]interface{}) error {
+			if parentID != nil {
+				if *parentID == feature.ID {
+					return errors.New("a feature cannot be its own parent")
+				}
+				parent, err := ctx.features.GetFeatureByID(int(*parentID))
+				if err != nil {
+					return errors.New("parent feature not found")
+				}
+				if parent.ProjectID != feature.ProjectID {
+					return errors.New("parent feature belongs to another project")
+				}
+				cycle, err := ctx.features.IsDescendant(feature.ID, *parentID)
+				if err != nil {
+					return err
+				}
+				if cycle {
+					return errors.New("cannot move a feature below one of its own descendants")
+				}
+				if err := ctx.features.CheckDateWindow(feature.ID, parentID, feature.Window()); err != nil {
+					return err
+				}
+			}
+			feature.ParentFeatureID = parentID
+			updates["parent_feature_id"] = parentID
+			changes["parent_feature_id"] = parentID
+			return nil
+		}, nil
+
+	case "set_milestone":
+		var milestoneID *uint
+		if !isNull(operation.Value) {
+			var id uint
+			if err := json.Unmarshal(operation.Value, &id); err != nil || id == 0 {
+				return nil, errors.New("set_milestone: value must be a milestone ID or null")
+			}
+			milestoneID = &id
+		}
+		return func(ctx *featureBulkContext, feature *models.Feature, updates, changes map[string]interface{}) error {
+			if milestoneID != nil && (feature.MilestoneID == nil || *feature.MilestoneID != *milestoneID) {
+				if err := checkMilestone(ctx.milestones, feature.ProjectID, *milestoneID); err != nil {
+					return err
+				}
+			}
+			feature.MilestoneID = milestoneID
+			updates["milestone_id"] = milestoneID
+			changes["milestone_id"] = milestoneID
+			return nil
+		}, nil
+
+	case "delete":
+		return func(ctx *featureBulkContext, feature *models.Feature, updates, changes map[string]interface{}) error {
+			changes["deleted"] = true
+			return nil
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown operation %q", operation.Op)
+}
+
+// taskBulkFilter selects tasks by their feature
+type taskBulkFilter struct {
+	FeatureID uint `json:"feature_id"`
+}
+
+type taskBulkRequest struct {
+	IDs        []uint          `json:"ids"`
+	Filter     *taskBulkFilter `json:"filter"`
+	Operations []bulkOperation `json:"operations"`
+	DryRun     bool            `json:"dry_run"`
+}
+
+// BulkTasks applies set_type, set_feature and delete operations to
+// many tasks in one transaction, with the same reporting and dry-run rules as BulkFeatures
+func (h *BulkHandler) BulkTasks(c *gin.Context) {
+	var request taskBulkRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if (len(request.IDs) == 0) == (request.Filter == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "exactly one of ids or filter is required"})
+		return
+	}
+	if request.Filter != nil && request.Filter.FeatureID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "filter.feature_id is required"})
+		return
+	}
+	if err := validateBulkOperations(request.Operations); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Decode operations up front so a malformed request never opens a transaction
+	updates := map[string]interface{}{}
+	deletes := false
+	for _, operation := range request.Operations {
+		switch operation.Op {
+		case "set_type":
+			var taskType string
+			if err := json.Unmarshal(operation.Value, &taskType); err != nil || taskType == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "set_type: value must be a non-empty string"})
+				return
+			}
+			updates["task_type"] = taskType
+		case "set_feature":
+			var id uint
+			if !isNull(operation.Value) {
+				if err := json.Unmarshal(operation.Value, &id); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "set_feature: value must be a feature ID or null"})
+					return
+				}
+			}
+			updates["feature_id"] = id
+		case "delete":
+			deletes = true
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown operation %q", operation.Op)})
+			return
+		}
+	}
+
+	response := bulkResponse{DryRun: request.DryRun, Results: []bulkItemResult{}}
+	var internalErr error
+	var tooMany bool
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		taskRepo := repositories.NewTaskRepository(tx)
+		featureRepo := repositories.NewFeatureRepository(tx)
+
+		// The target is checked once since every task moves to the same place
+		var targetErr error
+		if featureID, ok := updates["feature_id"].(uint); ok && featureID != 0 {
+			if err := tx.First(&models.Feature{}, featureID).Error; err != nil {
+				targetErr = errors.New("target feature not found")
+			}
+		}
+
+		ids := request.IDs
+		if request.Filter != nil {
+			tasks, err := taskRepo.GetByFeatureID(request.Filter.FeatureID)
+			if err != nil {
+				internalErr = err
+				return err
+			}
+			ids = make([]uint, 0, len(tasks))
+			for _, task := range tasks {
+				ids = append(ids, task.ID)
+			}
+		}
+		if len(ids) > maxBulkItems {
+			tooMany = true
+			return errBulkRollback
+		}
+		response.Matched = len(ids)
+
+		for _, id := range ids {
+			task, err := taskRepo.GetByID(id)
+			if err != nil {
+				response.record(id, nil, errors.New("task not found"))
+				continue
+			}
+			if targetErr != nil {
+				response.record(id, nil, targetErr)
+				continue
+			}
+
+			// A task moving to another feature has to fit inside that feature's dates
+			if featureID, ok := updates["feature_id"].(uint); ok {
+				if err := featureRepo.CheckTaskDates(featureID, task.Window()); err != nil {
+					response.record(id, nil, err)
+					continue
+				}
+			}
+
+			changes := map[string]interface{}{}
+			if deletes {
+				err = taskRepo.Delete(task.ID, task.Version)
+				changes["deleted"] = true
+			} else {
+				// Patch adds the version bump to the map, so hand it a copy
+				taskUpdates := make(map[string]interface{}, len(updates)+1)
+				for column, value := range updates {
+					taskUpdates[column] = value
+					changes[column] = value
+				}
+				err = taskRepo.Patch(task.ID, task.Version, taskUpdates)
+			}
+			response.record(id, changes, err)
+		}
+
+		if request.DryRun || response.Failed > 0 {
+			return errBulkRollback
+		}
+		return nil
+	})
+
+	if tooMany {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("a bulk request may touch at most %d tasks", maxBulkItems)})
+		return
+	}
+	if internalErr != nil || (err != nil && !errors.Is(err, errBulkRollback)) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "bulk operation failed"})
+		return
+	}
+
+	response.Applied = err == nil
+	response.respond(c)
+}
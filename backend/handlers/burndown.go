@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"FeaturePlus/models"
+	"FeaturePlus/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultBurndownDays is how many days a burndown covers when from is left out
+const defaultBurndownDays = 30
+
+// maxBurndownDays bounds the length of a burndown series
+const maxBurndownDays = 366
+
+type BurndownHandler struct {
+	repo        *repositories.SnapshotRepository
+	projectRepo *repositories.ProjectRepository
+}
+
+func NewBurndownHandler(repo *repositories.SnapshotRepository, projectRepo *repositories.ProjectRepository) *BurndownHandler {
+	return &BurndownHandler{repo: repo, projectRepo: projectRepo}
+}
+
+// GetBurndown returns the project's remaining and completed work for each day from
+// from to to, read from the daily snapshots. Today is counted live.
+func (h *BurndownHandler) GetBurndown(c *gin.Context) {
+	projectID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		return
+	}
+
+	unit := models.BurndownUnit(c.DefaultQuery("unit", string(models.BurndownCount)))
+	if unit != models.BurndownCount && unit != models.BurndownPoints {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unit must be count or points"})
+		return
+	}
+
+	today := models.Today()
+	to := c.DefaultQuery("to", today)
+	end, err := time.Parse(models.DateLayout, to)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must be a date in YYYY-MM-DD format"})
+		return
+	}
+	from := c.DefaultQuery("from", end.AddDate(0, 0, 1-defaultBurndownDays).Format(models.DateLayout))
+	start, err := time.Parse(models.DateLayout, from)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a date in YYYY-MM-DD format"})
+		return
+	}
+	if start.After(end) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must not be after to"})
+		return
+	}
+	if end.Sub(start) >= maxBurndownDays*24*time.Hour {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a burndown covers at most " + strconv.Itoa(maxBurndownDays) + " days"})
+		return
+	}
+
+	if _, err := h.projectRepo.GetProjectByID(projectID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return
+	}
+
+	snapshots, err := h.repo.GetSnapshots(projectID, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if from <= today && today <= to {
+		live, err := h.repo.TakeSnapshot(projectID, today)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if n := len(snapshots); n > 0 && snapshots[n-1].Date == today {
+			snapshots = snapshots[:n-1]
+		}
+		snapshots = append(snapshots, *live)
+	}
+
+	c.JSON(http.StatusOK, models.BuildBurndown(projectID, unit, from, to, today, snapshots))
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"FeaturePlus/models"
+	"FeaturePlus/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultChangelogWindow is how far back a changelog goes when since is left out
+const defaultChangelogWindow = 30 * 24 * time.Hour
+
+var changelogContentTypes = map[models.ChangelogFormat]string{
+	models.ChangelogMarkdown: "text/markdown; charset=utf-8",
+	models.ChangelogHTML:     "text/html; charset=utf-8",
+}
+
+// parseChangelogTime reads a YYYY-MM-DD date or an RFC 3339 timestamp. A bare date
+// used as the end of the window covers that whole day.
+func parseChangelogTime(value string, endOfDay bool) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, true
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return t, true
+}
+
+// GetChangelog lists the project's features that moved to done between since and
+// until, grouped by tag. Markdown and HTML are rendered through the project's stored
+// template for the format when it has one, or through a built-in template otherwise.
+func (h *ProjectHandler) GetChangelog(c *gin.Context) {
+	projectID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		return
+	}
+
+	format := models.ChangelogFormat(c.DefaultQuery("format", string(models.ChangelogMarkdown)))
+	if format != models.ChangelogMarkdown && format != models.ChangelogHTML && format != models.ChangelogJSON {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be markdown, html or json"})
+		return
+	}
+
+	until := time.Now()
+	if value := c.Query("until"); value != "" {
+		var ok bool
+		if until, ok = parseChangelogTime(value, true); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "until must be a date (YYYY-MM-DD) or an RFC 3339 time"})
+			return
+		}
+	}
+	since := until.Add(-defaultChangelogWindow)
+	if value := c.Query("since"); value != "" {
+		var ok bool
+		if since, ok = parseChangelogTime(value, false); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "since must be a date (YYYY-MM-DD) or an RFC 3339 time"})
+			return
+		}
+	}
+	if since.After(until) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "since must not be after until"})
+		return
+	}
+
+	// Templates used to come in the URL, which let any caller run one
+	if _, ok := c.GetQuery("template"); ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "template is not accepted here; store it on the project as changelog_markdown_template or changelog_html_template"})
+		return
+	}
+
+	project, err := h.repo.GetProjectByID(projectID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return
+	}
+
+	workflow, err := h.workflowRepo.GetWorkflow(projectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	completedAt, err := h.featureRepo.GetCompletionTimes(projectID, workflow.DoneStatuses(), since, until)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	features, err := h.featureRepo.FindFeatures(repositories.FeatureFilter{ProjectID: projectID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	tasks, err := h.featureRepo.GetProjectTasks(projectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	changelog := models.BuildChangelog(*project, features, tasks, completedAt, workflow, since, until)
+	if format == models.ChangelogJSON {
+		c.JSON(http.StatusOK, changelog)
+		return
+	}
+
+	source := project.ChangelogMarkdownTemplate
+	if format == models.ChangelogHTML {
+		source = project.ChangelogHTMLTemplate
+	}
+	output, err := changelog.Render(format, source)
+	if errors.Is(err, models.ErrChangelogTooLarge) || errors.Is(err, models.ErrChangelogTimeout) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The markup comes from a user-written template, so keep browsers from running
+	// scripts or loading anything from it on the API origin
+	c.Header("X-Content-Type-Options", "nosniff")
+	if format == models.ChangelogHTML {
+		c.Header("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
+	}
+	c.Data(http.StatusOK, changelogContentTypes[format], output)
+}
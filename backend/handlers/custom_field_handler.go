@@ -0,0 +1,447 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"FeaturePlus/models"
+	"FeaturePlus/repositories"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// fieldKeyPattern keeps custom field keys usable as cf.<key> query parameters
+var fieldKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+
+type CustomFieldHandler struct {
+	repo        *repositories.CustomFieldRepository
+	projectRepo *repositories.ProjectRepository
+}
+
+func NewCustomFieldHandler(repo *repositories.CustomFieldRepository, projectRepo *repositories.ProjectRepository) *CustomFieldHandler {
+	return &CustomFieldHandler{repo: repo, projectRepo: projectRepo}
+}
+
+// GetCustomFields lists the custom fields defined for a project
+func (h *CustomFieldHandler) GetCustomFields(c *gin.Context) {
+	projectID, ok := h.projectID(c)
+	if !ok {
+		return
+	}
+
+	fields, err := h.repo.GetFieldsByProject(projectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, fields)
+}
+
+// CreateCustomField defines a new custom field for a project
+func (h *CustomFieldHandler) CreateCustomField(c *gin.Context) {
+	projectID, ok := h.projectID(c)
+	if !ok {
+		return
+	}
+
+	var field models.CustomField
+	if err := c.ShouldBindJSON(&field); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	field.ID = 0
+	field.ProjectID = projectID
+
+	if err := validateCustomField(&field); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := h.repo.GetFieldByKey(projectID, field.Key); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "a custom field with this key already exists"})
+		return
+	}
+
+	// New fields go after the existing ones
+	existing, err := h.repo.GetFieldsByProject(projectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	field.Position = len(existing)
+
+	if err := h.repo.CreateField(&field); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, field)
+}
+
+// UpdateCustomField changes a field's name, options, rules, required flag or position.
+// The key and type are fixed once a field exists.
+func (h *CustomFieldHandler) UpdateCustomField(c *gin.Context) {
+	projectID, ok := h.projectID(c)
+	if !ok {
+		return
+	}
+
+	existing, ok := h.field(c, projectID)
+	if !ok {
+		return
+	}
+
+	var field models.CustomField
+	if err := c.ShouldBindJSON(&field); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if (field.Key != "" && field.Key != existing.Key) || (field.Type != "" && field.Type != existing.Type) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the key and type of a custom field cannot be changed"})
+		return
+	}
+	field.ID = existing.ID
+	field.ProjectID = existing.ProjectID
+	field.Key = existing.Key
+	field.Type = existing.Type
+	field.CreatedAt = existing.CreatedAt
+
+	if err := validateCustomField(&field); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Dropping an option that features still use would leave them with invalid values
+	removed := []string{}
+	for _, option := range existing.Options {
+		if !containsString(field.Options, option) {
+			removed = append(removed, option)
+		}
+	}
+	if len(removed) > 0 {
+		inUse, err := h.repo.OptionsInUse(existing, removed)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if len(inUse) > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "options still in use by features", "options": inUse})
+			return
+		}
+	}
+
+	if err := h.repo.UpdateField(&field); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, field)
+}
+
+// DeleteCustomField removes a field and every value stored for it
+func (h *CustomFieldHandler) DeleteCustomField(c *gin.Context) {
+	projectID, ok := h.projectID(c)
+	if !ok {
+		return
+	}
+
+	field, ok := h.field(c, projectID)
+	if !ok {
+		return
+	}
+
+	if err := h.repo.DeleteField(field); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// projectID reads the project from the URL and checks that it exists
+func (h *CustomFieldHandler) projectID(c *gin.Context) (int, bool) {
+	projectID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		return 0, false
+	}
+	if _, err := h.projectRepo.GetProjectByID(projectID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return 0, false
+	}
+	return projectID, true
+}
+
+// field loads the field named in the URL
+func (h *CustomFieldHandler) field(c *gin.Context, projectID int) (*models.CustomField, bool) {
+	fieldID, err := strconv.Atoi(c.Param("field_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid custom field ID"})
+		return nil, false
+	}
+	field, err := h.repo.GetField(projectID, fieldID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "custom field not found"})
+		return nil, false
+	}
+	return field, true
+}
+
+// validateCustomField checks a field definition
+func validateCustomField(field *models.CustomField) error {
+	if !fieldKeyPattern.MatchString(field.Key) {
+		return errors.New("key must be lowercase letters, digits and underscores")
+	}
+	if field.Name == "" {
+		field.Name = field.Key
+	}
+
+	switch field.Type {
+	case models.FieldText, models.FieldNumber, models.FieldDate, models.FieldUser:
+		if len(field.Options) > 0 {
+			return errors.New("options are only allowed on select fields")
+		}
+	case models.FieldSingleSelect, models.FieldMultiSelect:
+		if len(field.Options) == 0 {
+			return errors.New("select fields need at least one option")
+		}
+		seen := map[string]bool{}
+		for _, option := range field.Options {
+			if option == "" || seen[option] {
+				return errors.New("options must be unique and not empty")
+			}
+			seen[option] = true
+		}
+	default:
+		return errors.New("type must be one of text, number, date, single_select, multi_select, user")
+	}
+	if field.Options == nil {
+		field.Options = models.StringList{}
+	}
+
+	rules := field.Rules
+	if field.Type != models.FieldText && (rules.MaxLength != nil || rules.Pattern != "") {
+		return errors.New("max_length and pattern only apply to text fields")
+	}
+	if field.Type != models.FieldNumber && (rules.Min != nil || rules.Max != nil) {
+		return errors.New("min and max only apply to number fields")
+	}
+	if rules.MaxLength != nil && *rules.MaxLength < 1 {
+		return errors.New("max_length must be positive")
+	}
+	if rules.Pattern != "" {
+		if _, err := regexp.Compile(rules.Pattern); err != nil {
+			return errors.New("pattern is not a valid regular expression")
+		}
+	}
+	if rules.Min != nil && rules.Max != nil && *rules.Min > *rules.Max {
+		return errors.New("min must not be greater than max")
+	}
+	return nil
+}
+
+// customFieldChanges are validated custom field writes for one feature
+type customFieldChanges struct {
+	set    []models.CustomFieldValue
+	remove []models.CustomField
+}
+
+func (changes *customFieldChanges) removeIDs() []uint {
+	ids := make([]uint, 0, len(changes.remove))
+	for _, field := range changes.remove {
+		ids = append(ids, field.ID)
+	}
+	return ids
+}
+
+// resolveCustomFields validates custom field values sent for a feature of a project.
+// With replace every field missing from values is cleared and required fields must be
+// given; otherwise only the fields present change and null clears a value.
+func (h *FeatureHandler) resolveCustomFields(projectID int, values map[string]json.RawMessage, replace bool) (*customFieldChanges, error) {
+	fields, err := h.fieldRepo.GetFieldsByProject(projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	byKey := make(map[string]*models.CustomField, len(fields))
+	for i := range fields {
+		byKey[fields[i].Key] = &fields[i]
+	}
+	for key := range values {
+		if _, ok := byKey[key]; !ok {
+			return nil, &fieldValueError{fmt.Sprintf("unknown custom field %q", key)}
+		}
+	}
+
+	changes := &customFieldChanges{}
+	for i := range fields {
+		field := &fields[i]
+		raw, present := values[field.Key]
+		if !present && !replace {
+			continue
+		}
+		if !present || isNull(raw) {
+			if field.Required {
+				return nil, &fieldValueError{fmt.Sprintf("custom field %q is required", field.Key)}
+			}
+			changes.remove = append(changes.remove, *field)
+			continue
+		}
+
+		value, err := h.normalizeCustomFieldValue(field, raw)
+		if err != nil {
+			return nil, err
+		}
+		changes.set = append(changes.set, value)
+	}
+	return changes, nil
+}
+
+// saveCustomFields stores validated changes and mirrors them onto the feature
+func (h *FeatureHandler) saveCustomFields(feature *models.Feature, changes *customFieldChanges) error {
+	if err := h.fieldRepo.SetFeatureValues(feature.ID, changes.set, changes.removeIDs()); err != nil {
+		return err
+	}
+
+	if feature.CustomFields == nil {
+		feature.CustomFields = map[string]json.RawMessage{}
+	}
+	for _, field := range changes.remove {
+		delete(feature.CustomFields, field.Key)
+	}
+	for _, value := range changes.set {
+		feature.CustomFields[value.FieldKey] = json.RawMessage(value.Value)
+	}
+	return nil
+}
+
+// fieldValueError is a custom field value the client has to fix
+type fieldValueError struct {
+	message string
+}
+
+func (e *fieldValueError) Error() string {
+	return e.message
+}
+
+// respondCustomFieldError answers with 400 for invalid values and 500 otherwise
+func respondCustomFieldError(c *gin.Context, err error) {
+	var valueErr *fieldValueError
+	if errors.As(err, &valueErr) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+// normalizeCustomFieldValue checks a value against its field and prepares it for storage
+func (h *FeatureHandler) normalizeCustomFieldValue(field *models.CustomField, raw json.RawMessage) (models.CustomFieldValue, error) {
+	value := models.CustomFieldValue{FieldID: field.ID, FieldKey: field.Key}
+	invalid := func(message string) (models.CustomFieldValue, error) {
+		return value, &fieldValueError{fmt.Sprintf("custom field %q %s", field.Key, message)}
+	}
+
+	switch field.Type {
+	case models.FieldText, models.FieldDate, models.FieldSingleSelect:
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return invalid("must be a string")
+		}
+		if field.Required && s == "" {
+			return invalid("is required")
+		}
+		switch field.Type {
+		case models.FieldText:
+			if field.Rules.MaxLength != nil && utf8.RuneCountInString(s) > *field.Rules.MaxLength {
+				return invalid(fmt.Sprintf("must be at most %d characters", *field.Rules.MaxLength))
+			}
+			if field.Rules.Pattern != "" && !regexp.MustCompile(field.Rules.Pattern).MatchString(s) {
+				return invalid("does not match the required pattern")
+			}
+		case models.FieldDate:
+			if _, err := time.Parse("2006-01-02", s); err != nil {
+				return invalid("must be a date in YYYY-MM-DD format")
+			}
+		case models.FieldSingleSelect:
+			if !containsString(field.Options, s) {
+				return invalid("must be one of its options")
+			}
+		}
+		value.TextValue = s
+
+	case models.FieldNumber:
+		var n float64
+		if err := json.Unmarshal(raw, &n); err != nil {
+			return invalid("must be a number")
+		}
+		if field.Rules.Min != nil && n < *field.Rules.Min {
+			return invalid(fmt.Sprintf("must be at least %v", *field.Rules.Min))
+		}
+		if field.Rules.Max != nil && n > *field.Rules.Max {
+			return invalid(fmt.Sprintf("must be at most %v", *field.Rules.Max))
+		}
+		value.NumberValue = &n
+
+	case models.FieldMultiSelect:
+		var options []string
+		if err := json.Unmarshal(raw, &options); err != nil {
+			return invalid("must be a list of options")
+		}
+		if field.Required && len(options) == 0 {
+			return invalid("is required")
+		}
+		seen := map[string]bool{}
+		for _, option := range options {
+			if !containsString(field.Options, option) {
+				return invalid(fmt.Sprintf("has unknown option %q", option))
+			}
+			if seen[option] {
+				return invalid(fmt.Sprintf("lists %q twice", option))
+			}
+			seen[option] = true
+		}
+		if len(options) > 0 {
+			value.TextValue = options[0]
+		}
+		raw, _ = json.Marshal(options)
+
+	case models.FieldUser:
+		var userID uint
+		if err := json.Unmarshal(raw, &userID); err != nil || userID == 0 {
+			return invalid("must be a user ID")
+		}
+		if _, err := h.userRepo.GetUserByID(int(userID)); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return invalid("refers to an unknown user")
+			}
+			return value, err
+		}
+		n := float64(userID)
+		value.NumberValue = &n
+	}
+
+	// Store the value in compact form so responses are stable
+	compact, err := json.Marshal(json.RawMessage(raw))
+	if err != nil {
+		return invalid("is not valid JSON")
+	}
+	value.Value = string(compact)
+	return value, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
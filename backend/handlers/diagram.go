@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"FeaturePlus/models"
+	"FeaturePlus/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+// diagramContentTypes maps each format to the content type it is served with
+var diagramContentTypes = map[models.DiagramFormat]string{
+	models.DiagramDOT:      "text/vnd.graphviz; charset=utf-8",
+	models.DiagramMermaid:  "text/plain; charset=utf-8",
+	models.DiagramPlantUML: "text/plain; charset=utf-8",
+}
+
+// GetProjectDiagram renders the project's feature hierarchy and tasks as DOT, Mermaid
+// or PlantUML source. Options: depth, collapse_done, tag and include_tasks.
+func (h *ProjectHandler) GetProjectDiagram(c *gin.Context) {
+	projectID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		return
+	}
+
+	format := models.DiagramFormat(c.DefaultQuery("format", string(models.DiagramDOT)))
+	contentType, ok := diagramContentTypes[format]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be dot, mermaid or plantuml"})
+		return
+	}
+
+	depth, ok := parseDepth(c)
+	if !ok {
+		return
+	}
+
+	options := models.DiagramOptions{
+		MaxDepth:     depth,
+		CollapseDone: c.Query("collapse_done") == "true",
+		Tag:          c.Query("tag"),
+		IncludeTasks: c.Query("include_tasks") != "false",
+	}
+
+	project, err := h.repo.GetProjectByID(projectID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return
+	}
+
+	features, err := h.featureRepo.FindFeatures(repositories.FeatureFilter{ProjectID: projectID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var tasks []models.Task
+	if options.IncludeTasks {
+		tasks, err = h.featureRepo.GetProjectTasks(projectID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	workflow, err := h.workflowRepo.GetWorkflow(projectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	diagram := models.BuildDiagram(*project, features, tasks, workflow, options)
+	output, _ := diagram.Render(format)
+	c.Data(http.StatusOK, contentType, []byte(output))
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"FeaturePlus/models"
+	"FeaturePlus/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DueDateHandler serves the overdue and due-this-week views
+type DueDateHandler struct {
+	repo        *repositories.FeatureRepository
+	projectRepo *repositories.ProjectRepository
+	userRepo    *repositories.UserRepository
+}
+
+func NewDueDateHandler(repo *repositories.FeatureRepository, projectRepo *repositories.ProjectRepository, userRepo *repositories.UserRepository) *DueDateHandler {
+	return &DueDateHandler{repo: repo, projectRepo: projectRepo, userRepo: userRepo}
+}
+
+// overdueFilter selects items due before today
+func overdueFilter() repositories.DueFilter {
+	today := models.Today()
+	yesterday := today
+	if day, err := time.Parse(models.DateLayout, today); err == nil {
+		yesterday = day.AddDate(0, 0, -1).Format(models.DateLayout)
+	}
+	return repositories.DueFilter{To: yesterday}
+}
+
+// dueThisWeekFilter selects items due from today to the end of the week
+func dueThisWeekFilter() repositories.DueFilter {
+	today := models.Today()
+	return repositories.DueFilter{From: today, To: models.EndOfWeek(today)}
+}
+
+// GetProjectOverdue lists the project's open features and tasks whose due date has passed
+func (h *DueDateHandler) GetProjectOverdue(c *gin.Context) {
+	h.projectView(c, overdueFilter())
+}
+
+// GetProjectDueThisWeek lists the project's open features and tasks due between today and Sunday
+func (h *DueDateHandler) GetProjectDueThisWeek(c *gin.Context) {
+	h.projectView(c, dueThisWeekFilter())
+}
+
+// GetUserOverdue lists the open features assigned to the user, and their tasks, whose due date has passed
+func (h *DueDateHandler) GetUserOverdue(c *gin.Context) {
+	h.userView(c, overdueFilter())
+}
+
+// GetUserDueThisWeek lists the open features assigned to the user, and their tasks, due between today and Sunday
+func (h *DueDateHandler) GetUserDueThisWeek(c *gin.Context) {
+	h.userView(c, dueThisWeekFilter())
+}
+
+func (h *DueDateHandler) projectView(c *gin.Context, filter repositories.DueFilter) {
+	projectID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		return
+	}
+	if _, err := h.projectRepo.GetProjectByID(projectID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return
+	}
+
+	filter.ProjectID = projectID
+	h.respondDueItems(c, filter)
+}
+
+func (h *DueDateHandler) userView(c *gin.Context, filter repositories.DueFilter) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil || userID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+	if _, err := h.userRepo.GetUserByID(int(userID)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	filter.AssigneeID = uint(userID)
+	h.respondDueItems(c, filter)
+}
+
+func (h *DueDateHandler) respondDueItems(c *gin.Context, filter repositories.DueFilter) {
+	items, err := h.repo.GetDueItems(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+// checkDates validates a feature's dates against its parent, children and tasks. It
+// writes the error response itself and returns false on failure.
+func (h *FeatureHandler) checkDates(c *gin.Context, featureID uint, parentID *uint, window models.DateWindow) bool {
+	if err := h.repo.CheckDateWindow(featureID, parentID, window); err != nil {
+		respondCheckError(c, err)
+		return false
+	}
+	return true
+}
+
+// patchedDates returns the parent and dates a feature will have after the updates,
+// and whether any of them change
+func patchedDates(feature *models.Feature, updates map[string]interface{}) (*uint, models.DateWindow, bool) {
+	parentID, window := feature.ParentFeatureID, feature.Window()
+	changed := false
+	if value, ok := updates["parent_feature_id"]; ok {
+		parentID, changed = optionalUint(value), true
+	}
+	if value, ok := updates["start_date"]; ok {
+		window.Start, changed = optionalDate(value), true
+	}
+	if value, ok := updates["due_date"]; ok {
+		window.Due, changed = optionalDate(value), true
+	}
+	return parentID, window, changed
+}
+
+// optionalDate turns a decoded date patch value, or nil when it was null, into a pointer
+func optionalDate(value interface{}) *string {
+	if date, ok := value.(string); ok {
+		return &date
+	}
+	return nil
+}
+
+func optionalUint(value interface{}) *uint {
+	if id, ok := value.(uint); ok {
+		return &id
+	}
+	return nil
+}
+
+// respondCheckError maps a failed date or estimate check to a 400 for bad values or a
+// 500 otherwise
+func respondCheckError(c *gin.Context, err error) {
+	var rangeErr *repositories.DateRangeError
+	var estimateErr *repositories.EstimateError
+	var tagErr *repositories.TagError
+	if errors.As(err, &rangeErr) || errors.As(err, &estimateErr) || errors.As(err, &tagErr) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var wipErr *repositories.WIPLimitError
+	if errors.As(err, &wipErr) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
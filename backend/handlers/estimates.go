@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"FeaturePlus/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// checkEstimates validates a feature's estimates against its project's scale. It
+// writes the error response itself and returns false on failure.
+func (h *FeatureHandler) checkEstimates(c *gin.Context, projectID int, estimates models.Estimates) bool {
+	if err := h.repo.CheckEstimates(projectID, estimates); err != nil {
+		respondCheckError(c, err)
+		return false
+	}
+	return true
+}
+
+// patchedEstimates returns the estimates after the updates, and whether they change
+func patchedEstimates(estimates models.Estimates, updates map[string]interface{}) (models.Estimates, bool) {
+	changed := false
+	if value, ok := updates["estimate"]; ok {
+		estimates.Estimate, changed = optionalEstimate(value), true
+	}
+	if value, ok := updates["remaining_estimate"]; ok {
+		estimates.RemainingEstimate, changed = optionalEstimate(value), true
+	}
+	return estimates, changed
+}
+
+// optionalEstimate turns a decoded estimate patch value, or nil when it was null, into a pointer
+func optionalEstimate(value interface{}) *models.Estimate {
+	if estimate, ok := value.(models.Estimate); ok {
+		return &estimate
+	}
+	return nil
+}
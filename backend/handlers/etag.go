@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// etagFor builds the entity tag for a given resource version
+func etagFor(version uint) string {
+	return fmt.Sprintf("\"%d\"", version)
+}
+
+// setETag sets the ETag response header for a resource version
+func setETag(c *gin.Context, version uint) {
+	c.Header("ETag", etagFor(version))
+}
+
+// etagListMatches reports whether a comma-separated If-Match/If-None-Match header
+// value contains the given entity tag or the "*" wildcard
+func etagListMatches(header string, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		// If-None-Match uses weak comparison, so the W/ prefix is ignored there
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// representationETag tags a response body. It starts with the version, so it still
+// works as an If-Match precondition, and ends with a hash of the body, so it also
+// changes when data derived from other records does.
+func representationETag(version uint, body []byte) string {
+	sum := sha256.Sum256(body)
+	return fmt.Sprintf("\"%d-%s\"", version, hex.EncodeToString(sum[:8]))
+}
+
+// versionTag reduces a representation ETag to the version tag it starts with
+func versionTag(etag string) string {
+	weak := strings.HasPrefix(etag, "W/")
+	etag = strings.TrimPrefix(etag, "W/")
+	if i := strings.IndexByte(etag, '-'); i > 0 && strings.HasPrefix(etag, "\"") {
+		etag = etag[:i] + "\""
+	}
+	if weak {
+		return "W/" + etag
+	}
+	return etag
+}
+
+// respondWithETag writes a GET response tagged with a representation ETag, or 304
+// when the client's If-None-Match already holds that representation
+func respondWithETag(c *gin.Context, version uint, body interface{}) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	etag := representationETag(version, payload)
+	c.Header("ETag", etag)
+
+	if header := c.GetHeader("If-None-Match"); header != "" && etagListMatches(header, etag, true) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", payload)
+}
+
+// checkIfMatch enforces the If-Match precondition on write requests. It responds
+// 428 when the header is missing and 412 when it does not match the current version.
+func checkIfMatch(c *gin.Context, version uint) bool {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		c.JSON(http.StatusPreconditionRequired, gin.H{"error": "If-Match header is required"})
+		return false
+	}
+
+	// Representation ETags from GET responses match on the version they start with
+	candidates := strings.Split(header, ",")
+	for i, candidate := range candidates {
+		candidates[i] = versionTag(strings.TrimSpace(candidate))
+	}
+
+	etag := etagFor(version)
+	if !etagListMatches(strings.Join(candidates, ","), etag, false) {
+		c.Header("ETag", etag)
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "resource has been modified", "current_etag": etag})
+		return false
+	}
+	return true
+}
+
+// respondVersionConflict is used when the row changed between the If-Match check and the write
+func respondVersionConflict(c *gin.Context) {
+	c.JSON(http.StatusPreconditionFailed, gin.H{"error": "resource has been modified"})
+}
@@ -1,8 +1,12 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"FeaturePlus/models"
 	"FeaturePlus/repositories"
@@ -11,12 +15,17 @@ import (
 )
 
 type FeatureHandler struct {
-	repo    *repositories.FeatureRepository
-	tagRepo *repositories.TagRepository
+	repo          *repositories.FeatureRepository
+	tagRepo       *repositories.TagRepository
+	taskRepo      repositories.TaskRepository
+	workflowRepo  *repositories.WorkflowRepository
+	fieldRepo     *repositories.CustomFieldRepository
+	userRepo      *repositories.UserRepository
+	milestoneRepo *repositories.MilestoneRepository
 }
 
-func NewFeatureHandler(repo *repositories.FeatureRepository, tagRepo *repositories.TagRepository) *FeatureHandler {
-	return &FeatureHandler{repo: repo, tagRepo: tagRepo}
+func NewFeatureHandler(repo *repositories.FeatureRepository, tagRepo *repositories.TagRepository, taskRepo repositories.TaskRepository, workflowRepo *repositories.WorkflowRepository, fieldRepo *repositories.CustomFieldRepository, userRepo *repositories.UserRepository, milestoneRepo *repositories.MilestoneRepository) *FeatureHandler {
+	return &FeatureHandler{repo: repo, tagRepo: tagRepo, taskRepo: taskRepo, workflowRepo: workflowRepo, fieldRepo: fieldRepo, userRepo: userRepo, milestoneRepo: milestoneRepo}
 }
 
 type FeatureWithTags struct {
@@ -31,6 +40,37 @@ func (h *FeatureHandler) CreateFeature(c *gin.Context) {
 		return
 	}
 
+	h.createFeature(c, featureWithTags)
+}
+
+// CreateSubfeature creates a feature below the feature given in the URL
+func (h *FeatureHandler) CreateSubfeature(c *gin.Context) {
+	parentIDStr := c.Param("id")
+	parentID, err := strconv.Atoi(parentIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid parent feature ID"})
+		return
+	}
+
+	var featureWithTags FeatureWithTags
+	if err := c.ShouldBindJSON(&featureWithTags); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	parent, err := h.repo.GetFeatureByID(parentID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "parent feature not found"})
+		return
+	}
+
+	featureWithTags.ProjectID = parent.ProjectID
+	featureWithTags.ParentFeatureID = &parent.ID
+	h.createFeature(c, featureWithTags)
+}
+
+// createFeature validates and stores a new feature along with its tags
+func (h *FeatureHandler) createFeature(c *gin.Context, featureWithTags FeatureWithTags) {
 	// Extract feature from the combined structure
 	feature := featureWithTags.Feature
 
@@ -38,16 +78,61 @@ func (h *FeatureHandler) CreateFeature(c *gin.Context) {
 	feature.Status = models.FeatureStatus(feature.Status)
 	feature.Priority = models.FeaturePriority(feature.Priority)
 
-	if !isValidStatus(feature.Status) || !isValidPriority(feature.Priority) {
+	if !isValidPriority(feature.Priority) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid status or priority"})
 		return
 	}
 
+	// Sprints and board ranks have their own endpoints and overdue is set by the job
+	feature.SprintID = nil
+	feature.BoardRank, feature.BacklogRank = "", ""
+	feature.Overdue, feature.OverdueSince = false, nil
+
+	if !h.checkInitialStatus(c, &feature) {
+		return
+	}
+
+	if feature.ParentFeatureID != nil && !h.validateParent(c, &feature, *feature.ParentFeatureID) {
+		return
+	}
+
+	if feature.MilestoneID != nil && !h.validateMilestone(c, &feature, *feature.MilestoneID) {
+		return
+	}
+
+	if !h.checkDates(c, 0, feature.ParentFeatureID, feature.Window()) {
+		return
+	}
+
+	if !h.checkEstimates(c, feature.ProjectID, feature.Estimates()) {
+		return
+	}
+
+	if !checkScoring(c, feature.ScoringInputs) {
+		return
+	}
+
+	if !h.checkTags(c, feature.ProjectID, 0, featureWithTags.TagsInput) {
+		return
+	}
+
+	customFields, err := h.resolveCustomFields(feature.ProjectID, feature.CustomFields, true)
+	if err != nil {
+		respondCustomFieldError(c, err)
+		return
+	}
+	feature.CustomFields = nil
+
 	if err := h.repo.CreateFeature(&feature); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	if err := h.saveCustomFields(&feature, customFields); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Feature created but failed to save custom fields"})
+		return
+	}
+
 	// Handle tags if provided
 	if featureWithTags.TagsInput != "" {
 		var createdByUser uint = 1 // Default to admin if not available
@@ -69,11 +154,13 @@ func (h *FeatureHandler) CreateFeature(c *gin.Context) {
 		// Fetch the feature again with its tags
 		updatedFeature, err := h.repo.GetFeatureByID(int(feature.ID))
 		if err == nil {
+			setETag(c, updatedFeature.Version)
 			c.JSON(http.StatusCreated, updatedFeature)
 			return
 		}
 	}
 
+	setETag(c, feature.Version)
 	c.JSON(http.StatusCreated, feature)
 }
 
@@ -91,7 +178,24 @@ func (h *FeatureHandler) GetFeature(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, feature)
+	rollup, err := h.repo.GetFeatureRollup(feature.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	feature.Rollup = rollup
+	feature.Score = feature.ScoringInputs.Score(feature.Project.ScoringFramework)
+
+	single := []models.Feature{*feature}
+	if err := h.repo.SetBlockedStatus(single); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	feature.Blocked, feature.OpenBlockers = single[0].Blocked, single[0].OpenBlockers
+
+	// The rollup, blockers and score change without the feature's version, so the
+	// ETag covers the whole body
+	respondWithETag(c, feature.Version, feature)
 }
 
 func (h *FeatureHandler) GetProjectFeatures(c *gin.Context) {
@@ -103,20 +207,40 @@ func (h *FeatureHandler) GetProjectFeatures(c *gin.Context) {
 	}
 
 	// Check if we should return only root features
-	rootOnly := c.Query("root_only")
-	if rootOnly == "true" {
-		features, err := h.repo.GetRootFeaturesByProject(projectID)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
+	filter := repositories.FeatureFilter{ProjectID: projectID, RootOnly: c.Query("root_only") == "true"}
+
+	// cf.<key>=value filters on a custom field
+	for name, values := range c.Request.URL.Query() {
+		if key, ok := strings.CutPrefix(name, "cf."); ok && len(values) > 0 {
+			if filter.CustomFields == nil {
+				filter.CustomFields = map[string]string{}
+			}
+			filter.CustomFields[key] = values[0]
 		}
-		c.JSON(http.StatusOK, features)
+	}
+
+	// Features come in backlog order unless another sort is asked for
+	sort := repositories.FeatureSort{Field: c.DefaultQuery("sort", "rank")}
+	switch c.DefaultQuery("order", "asc") {
+	case "asc":
+	case "desc":
+		sort.Desc = true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "order must be asc or desc"})
 		return
 	}
 
-	// Return all features for the project
-	features, err := h.repo.GetFeaturesByProject(projectID)
+	features, err := h.repo.ListFeatures(filter, sort)
 	if err != nil {
+		if errors.Is(err, repositories.ErrInvalidFeatureQuery) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.repo.SetBlockedStatus(features); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -163,7 +287,7 @@ func (h *FeatureHandler) UpdateFeature(c *gin.Context) {
 	feature.Status = models.FeatureStatus(feature.Status)
 	feature.Priority = models.FeaturePriority(feature.Priority)
 
-	if !isValidStatus(feature.Status) || !isValidPriority(feature.Priority) {
+	if feature.Status == "" || !isValidPriority(feature.Priority) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid status or priority"})
 		return
 	}
@@ -174,6 +298,30 @@ func (h *FeatureHandler) UpdateFeature(c *gin.Context) {
 		return
 	}
 
+	if !checkIfMatch(c, existingFeature.Version) {
+		return
+	}
+
+	if !h.checkStatusChange(c, existingFeature, feature.Status, feature.AssigneeID) {
+		return
+	}
+
+	if !h.checkTags(c, existingFeature.ProjectID, existingFeature.ID, featureWithTags.TagsInput) {
+		return
+	}
+
+	// Custom fields are replaced as a whole when given and kept when left out
+	var customFields *customFieldChanges
+	if feature.CustomFields != nil {
+		customFields, err = h.resolveCustomFields(existingFeature.ProjectID, feature.CustomFields, true)
+		if err != nil {
+			respondCustomFieldError(c, err)
+			return
+		}
+	}
+
+	previousParentID := existingFeature.ParentFeatureID
+
 	// Update fields
 	existingFeature.Title = feature.Title
 	existingFeature.Description = feature.Description
@@ -183,14 +331,73 @@ func (h *FeatureHandler) UpdateFeature(c *gin.Context) {
 
 	// Update parent feature ID if provided
 	if feature.ParentFeatureID != nil {
+		if !h.validateParent(c, existingFeature, *feature.ParentFeatureID) {
+			return
+		}
 		existingFeature.ParentFeatureID = feature.ParentFeatureID
 	}
 
+	// Update milestone if provided
+	if feature.MilestoneID != nil {
+		if !h.validateMilestone(c, existingFeature, *feature.MilestoneID) {
+			return
+		}
+		existingFeature.MilestoneID = feature.MilestoneID
+	}
+
+	// Dates are kept when left out, like the parent; PATCH clears them with null
+	if feature.StartDate != nil || feature.DueDate != nil || feature.ParentFeatureID != nil {
+		if feature.StartDate != nil {
+			existingFeature.StartDate = feature.StartDate
+		}
+		if feature.DueDate != nil {
+			existingFeature.DueDate = feature.DueDate
+		}
+		if !h.checkDates(c, existingFeature.ID, existingFeature.ParentFeatureID, existingFeature.Window()) {
+			return
+		}
+	}
+
+	// Estimates are kept when left out too
+	if feature.Estimate != nil || feature.RemainingEstimate != nil {
+		if feature.Estimate != nil {
+			existingFeature.Estimate = feature.Estimate
+		}
+		if feature.RemainingEstimate != nil {
+			existingFeature.RemainingEstimate = feature.RemainingEstimate
+		}
+		if !h.checkEstimates(c, existingFeature.ProjectID, existingFeature.Estimates()) {
+			return
+		}
+	}
+
+	// So are scoring inputs
+	mergeScoring(&existingFeature.ScoringInputs, feature.ScoringInputs)
+	if !checkScoring(c, existingFeature.ScoringInputs) {
+		return
+	}
+
 	if err := h.repo.UpdateFeature(existingFeature); err != nil {
+		if errors.Is(err, repositories.ErrVersionConflict) {
+			respondVersionConflict(c)
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	if err := completeParents(h.repo, previousParentID, existingFeature.ParentFeatureID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Feature updated but failed to complete parent features"})
+		return
+	}
+
+	if customFields != nil {
+		if err := h.saveCustomFields(existingFeature, customFields); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Feature updated but failed to save custom fields"})
+			return
+		}
+	}
+
 	// Handle tags if provided
 	if featureWithTags.TagsInput != "" {
 		var createdByUser uint = 1 // Default to admin if not available
@@ -212,14 +419,268 @@ func (h *FeatureHandler) UpdateFeature(c *gin.Context) {
 		// Fetch the feature again with its updated tags
 		updatedFeature, err := h.repo.GetFeatureByID(featureID)
 		if err == nil {
+			setETag(c, updatedFeature.Version)
 			c.JSON(http.StatusOK, updatedFeature)
 			return
 		}
 	}
 
+	setETag(c, existingFeature.Version)
 	c.JSON(http.StatusOK, existingFeature)
 }
 
+// featurePatchFields lists the feature members that can be changed through PATCH
+var featurePatchFields = map[string]patchField{
+	"title":             {column: "title", decode: stringPatch(true)},
+	"description":       {column: "description", nullable: true, nullValue: "", decode: stringPatch(false)},
+	"status":            {column: "status", decode: statusPatch},
+	"priority":          {column: "priority", decode: priorityPatch},
+	"assignee_id":       {column: "assignee_id", nullable: true, nullValue: uint(0), decode: uintPatch(true)},
+	"parent_feature_id": {column: "parent_feature_id", nullable: true, nullValue: nil, decode: uintPatch(false)},
+	"milestone_id":      {column: "milestone_id", nullable: true, nullValue: nil, decode: uintPatch(false)},
+	"start_date":        {column: "start_date", nullable: true, nullValue: nil, decode: datePatch},
+	"due_date":          {column: "due_date", nullable: true, nullValue: nil, decode: datePatch},
+
+	"estimate":           {column: "estimate", nullable: true, nullValue: nil, decode: estimatePatch},
+	"remaining_estimate": {column: "remaining_estimate", nullable: true, nullValue: nil, decode: estimatePatch},
+
+	"reach":            {column: "reach", nullable: true, nullValue: nil, decode: scorePatch},
+	"impact":           {column: "impact", nullable: true, nullValue: nil, decode: scorePatch},
+	"confidence":       {column: "confidence", nullable: true, nullValue: nil, decode: scorePatch},
+	"effort":           {column: "effort", nullable: true, nullValue: nil, decode: scorePatch},
+	"business_value":   {column: "business_value", nullable: true, nullValue: nil, decode: scorePatch},
+	"time_criticality": {column: "time_criticality", nullable: true, nullValue: nil, decode: scorePatch},
+	"risk_reduction":   {column: "risk_reduction", nullable: true, nullValue: nil, decode: scorePatch},
+	"job_size":         {column: "job_size", nullable: true, nullValue: nil, decode: scorePatch},
+}
+
+// PatchFeature applies a JSON Merge Patch to a feature, leaving absent fields untouched
+func (h *FeatureHandler) PatchFeature(c *gin.Context) {
+	idStr := c.Param("id")
+	featureID, err := strconv.Atoi(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid feature ID"})
+		return
+	}
+
+	patch, err := bindMergePatch(c)
+	if err != nil {
+		respondPatchBindError(c, err)
+		return
+	}
+
+	// Tags are stored separately, so pull them out before building column updates
+	tagsInput, err := extractTagsInput(patch)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Custom fields are merged one by one, so they are kept out of the column updates too
+	var customFields map[string]json.RawMessage
+	if raw, ok := patch["custom_fields"]; ok {
+		delete(patch, "custom_fields")
+		if err := json.Unmarshal(raw, &customFields); err != nil || customFields == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "custom_fields must be an object"})
+			return
+		}
+	}
+
+	updates, err := buildUpdates(patch, featurePatchFields)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updatedFeature, ok := h.applyFeatureUpdates(c, featureID, updates, tagsInput, customFields, false)
+	if !ok {
+		return
+	}
+
+	setETag(c, updatedFeature.Version)
+	c.JSON(http.StatusOK, updatedFeature)
+}
+
+// extractTagsInput removes tags_input from a patch. A nil result means tags are
+// left alone, while null in the patch clears them.
+func extractTagsInput(patch mergePatch) (*string, error) {
+	raw, ok := patch["tags_input"]
+	if !ok {
+		return nil, nil
+	}
+	delete(patch, "tags_input")
+
+	var tagsInput string
+	if !isNull(raw) {
+		if err := json.Unmarshal(raw, &tagsInput); err != nil {
+			return nil, errors.New("invalid tags_input: must be a string")
+		}
+	}
+	return &tagsInput, nil
+}
+
+// applyFeatureUpdates checks If-Match, validates a parent change and writes the
+// column updates and tags for a feature. When subfeatureOnly is set the target must
+// already have a parent. It writes the error response itself and returns false on failure.
+func (h *FeatureHandler) applyFeatureUpdates(c *gin.Context, featureID int, updates map[string]interface{}, tagsInput *string, customFieldValues map[string]json.RawMessage, subfeatureOnly bool) (*models.Feature, bool) {
+	existingFeature, err := h.repo.GetFeatureByID(featureID)
+	if err != nil || (subfeatureOnly && existingFeature.ParentFeatureID == nil) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "feature not found"})
+		return nil, false
+	}
+
+	if !checkIfMatch(c, existingFeature.Version) {
+		return nil, false
+	}
+
+	if status, ok := updates["status"].(models.FeatureStatus); ok {
+		assigneeID := existingFeature.AssigneeID
+		if newAssigneeID, ok := updates["assignee_id"].(uint); ok {
+			assigneeID = newAssigneeID
+		}
+		if !h.checkStatusChange(c, existingFeature, status, assigneeID) {
+			return nil, false
+		}
+	}
+
+	if parentID, ok := updates["parent_feature_id"].(uint); ok {
+		if !h.validateParent(c, existingFeature, parentID) {
+			return nil, false
+		}
+	}
+
+	if milestoneID, ok := updates["milestone_id"].(uint); ok {
+		if !h.validateMilestone(c, existingFeature, milestoneID) {
+			return nil, false
+		}
+	}
+
+	if parentID, window, changed := patchedDates(existingFeature, updates); changed {
+		if !h.checkDates(c, existingFeature.ID, parentID, window) {
+			return nil, false
+		}
+	}
+
+	if estimates, changed := patchedEstimates(existingFeature.Estimates(), updates); changed {
+		if !h.checkEstimates(c, existingFeature.ProjectID, estimates) {
+			return nil, false
+		}
+	}
+
+	if inputs, changed := patchedScoring(existingFeature.ScoringInputs, updates); changed {
+		if !checkScoring(c, inputs) {
+			return nil, false
+		}
+	}
+
+	if tagsInput != nil && !h.checkTags(c, existingFeature.ProjectID, existingFeature.ID, *tagsInput) {
+		return nil, false
+	}
+
+	var customFields *customFieldChanges
+	if len(customFieldValues) > 0 {
+		customFields, err = h.resolveCustomFields(existingFeature.ProjectID, customFieldValues, false)
+		if err != nil {
+			respondCustomFieldError(c, err)
+			return nil, false
+		}
+		// Custom field values are part of the feature, so they bump its version too
+		updates["updated_at"] = time.Now()
+	}
+
+	if err := h.repo.PatchFeature(featureID, existingFeature.Version, updates); err != nil {
+		if errors.Is(err, repositories.ErrVersionConflict) {
+			respondVersionConflict(c)
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	// Both the old and the new parent may be left with only finished children, also
+	// when the feature moves to the top level
+	_, statusChanged := updates["status"]
+	_, parentChanged := updates["parent_feature_id"]
+	if statusChanged || parentChanged {
+		newParentID, _ := updates["parent_feature_id"].(uint)
+		if err := completeParents(h.repo, existingFeature.ParentFeatureID, &newParentID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Feature updated but failed to complete parent features"})
+			return nil, false
+		}
+	}
+
+	if customFields != nil {
+		if err := h.saveCustomFields(existingFeature, customFields); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Feature updated but failed to save custom fields"})
+			return nil, false
+		}
+	}
+
+	if tagsInput != nil {
+		var createdByUser uint = 1 // Default to admin if not available
+		if userID, exists := c.Get("user_id"); exists {
+			createdByUser = userID.(uint)
+		}
+
+		if err := h.tagRepo.UpdateFeatureTags(existingFeature.ID, createdByUser, *tagsInput); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Feature updated but failed to save tags"})
+			return nil, false
+		}
+	}
+
+	updatedFeature, err := h.repo.GetFeatureByID(featureID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return updatedFeature, true
+}
+
+// validateParent checks that parentID can become the parent of feature
+func (h *FeatureHandler) validateParent(c *gin.Context, feature *models.Feature, parentID uint) bool {
+	if feature.ID != 0 && parentID == feature.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a feature cannot be its own parent"})
+		return false
+	}
+	parent, err := h.repo.GetFeatureByID(int(parentID))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "parent feature not found"})
+		return false
+	}
+	if parent.ProjectID != feature.ProjectID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "parent feature belongs to another project"})
+		return false
+	}
+	if feature.ID != 0 {
+		cycle, err := h.repo.IsDescendant(feature.ID, parentID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return false
+		}
+		if cycle {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cannot move a feature below one of its own descendants"})
+			return false
+		}
+	}
+	return true
+}
+
+// validateMilestone checks that the feature can be scheduled into milestoneID. A
+// feature may stay in a closed milestone, but cannot be added to one.
+func (h *FeatureHandler) validateMilestone(c *gin.Context, feature *models.Feature, milestoneID uint) bool {
+	if feature.ID != 0 && feature.MilestoneID != nil && *feature.MilestoneID == milestoneID {
+		return true
+	}
+	if err := checkMilestone(h.milestoneRepo, feature.ProjectID, milestoneID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+// DeleteFeature deletes a feature along with its tags and tasks. The children query
+// parameter decides what happens below it: "reparent" (the default) moves the children
+// up to the deleted feature's parent, "cascade" deletes the whole subtree.
 func (h *FeatureHandler) DeleteFeature(c *gin.Context) {
 	idStr := c.Param("id")
 	featureID, err := strconv.Atoi(idStr)
@@ -228,17 +689,80 @@ func (h *FeatureHandler) DeleteFeature(c *gin.Context) {
 		return
 	}
 
-	// Delete associated tags first
-	h.tagRepo.DeleteTagsByFeatureID(uint(featureID))
+	var cascade bool
+	switch c.DefaultQuery("children", "reparent") {
+	case "reparent":
+	case "cascade":
+		cascade = true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "children must be reparent or cascade"})
+		return
+	}
 
-	if err := h.repo.DeleteFeature(featureID); err != nil {
+	existingFeature, err := h.repo.GetFeatureByID(featureID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "feature not found"})
+		return
+	}
+
+	if !checkIfMatch(c, existingFeature.Version) {
+		return
+	}
+
+	if _, err := h.repo.DeleteFeatureTree(existingFeature.ID, existingFeature.Version, cascade); err != nil {
+		if errors.Is(err, repositories.ErrVersionConflict) {
+			respondVersionConflict(c)
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	// Removing an open child may leave its parent with only finished children
+	if err := completeParents(h.repo, existingFeature.ParentFeatureID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Feature deleted but failed to complete parent features"})
+		return
+	}
+
 	c.Status(http.StatusNoContent)
 }
 
+// completeParents applies the project's auto-complete rule to every parent a status or
+// parent change may have affected
+func completeParents(repo *repositories.FeatureRepository, parentIDs ...*uint) error {
+	for _, parentID := range parentIDs {
+		if parentID == nil || *parentID == 0 {
+			continue
+		}
+		if _, err := repo.CompleteAncestors(*parentID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetStatusHistory lists every status a feature has been in, oldest first
+func (h *FeatureHandler) GetStatusHistory(c *gin.Context) {
+	featureID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid feature ID"})
+		return
+	}
+
+	if _, err := h.repo.GetFeatureByID(featureID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "feature not found"})
+		return
+	}
+
+	history, err := h.repo.GetStatusHistory(uint(featureID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
 // GET /api/features?tag=p0
 func (h *FeatureHandler) GetAllFeatures(c *gin.Context) {
 	var features []models.Feature
@@ -250,16 +774,79 @@ func (h *FeatureHandler) GetAllFeatures(c *gin.Context) {
 		return
 	}
 
+	if err := h.repo.SetBlockedStatus(features); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	c.JSON(http.StatusOK, features)
 }
 
 // Helper functions
-func isValidStatus(status models.FeatureStatus) bool {
-	switch status {
-	case models.StatusTodo, models.StatusInProgress, models.StatusDone:
-		return true
+
+// checkInitialStatus validates a new feature's status against its project's workflow,
+// filling in the workflow's first status when none is given
+func (h *FeatureHandler) checkInitialStatus(c *gin.Context, feature *models.Feature) bool {
+	workflow, err := h.workflowRepo.GetWorkflow(feature.ProjectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
 	}
-	return false
+
+	if feature.Status == "" {
+		feature.Status = workflow.InitialStatus()
+	}
+	if err := validateInitialStatus(workflow, feature.Status); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	if _, err := h.repo.CheckWIPLimit(workflow, feature.ID, feature.Status); err != nil {
+		respondCheckError(c, err)
+		return false
+	}
+	return true
+}
+
+// checkStatusChange validates moving feature to status under its project's workflow
+func (h *FeatureHandler) checkStatusChange(c *gin.Context, feature *models.Feature, status models.FeatureStatus, assigneeID uint) bool {
+	workflow, err := h.workflowRepo.GetWorkflow(feature.ProjectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+
+	if err := validateStatusChange(workflow, feature.Status, status, assigneeID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+
+	blockers, err := openBlockersPreventingDone(h.repo, feature, workflow, feature.Status, status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	if len(blockers) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": blockedMessage(blockers), "open_blockers": blockers})
+		return false
+	}
+
+	if status != feature.Status {
+		if _, err := h.repo.CheckWIPLimit(workflow, feature.ID, status); err != nil {
+			respondCheckError(c, err)
+			return false
+		}
+	}
+	return true
+}
+
+// checkTags refuses tag inputs that would add archived tags to a feature. featureID is
+// 0 for a new feature. It writes the error response itself and returns false on failure.
+func (h *FeatureHandler) checkTags(c *gin.Context, projectID int, featureID uint, tagInput string) bool {
+	if err := h.tagRepo.CheckFeatureTags(projectID, featureID, tagInput); err != nil {
+		respondCheckError(c, err)
+		return false
+	}
+	return true
 }
 
 func isValidPriority(priority models.FeaturePriority) bool {
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"FeaturePlus/models"
+	"FeaturePlus/repositories"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// parseDepth reads the optional depth query parameter; zero means unlimited
+func parseDepth(c *gin.Context) (int, bool) {
+	depthStr := c.Query("depth")
+	if depthStr == "" {
+		return 0, true
+	}
+	depth, err := strconv.Atoi(depthStr)
+	if err != nil || depth < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "depth must be a positive integer"})
+		return 0, false
+	}
+	return depth, true
+}
+
+// GetProjectTree returns every feature of a project nested by parent
+func (h *FeatureHandler) GetProjectTree(c *gin.Context) {
+	projectID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		return
+	}
+
+	depth, ok := parseDepth(c)
+	if !ok {
+		return
+	}
+
+	features, err := h.repo.FindFeatures(repositories.FeatureFilter{ProjectID: projectID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.BuildFeatureTree(features, depth))
+}
+
+// GetFeatureTree returns a feature with its descendants nested below it
+func (h *FeatureHandler) GetFeatureTree(c *gin.Context) {
+	featureID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid feature ID"})
+		return
+	}
+
+	depth, ok := parseDepth(c)
+	if !ok {
+		return
+	}
+
+	ids, err := h.repo.GetSubtreeIDs(uint(featureID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(ids) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "feature not found"})
+		return
+	}
+
+	features, err := h.repo.GetFeaturesByIDs(ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	for _, node := range models.BuildFeatureTree(features, depth) {
+		if node.ID == uint(featureID) {
+			c.JSON(http.StatusOK, node)
+			return
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "feature not found"})
+}
+
+// MoveFeature moves a feature and everything below it under a new parent, or to the
+// top level when parent_feature_id is null. Moving below its own subtree is rejected.
+func (h *FeatureHandler) MoveFeature(c *gin.Context) {
+	featureID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid feature ID"})
+		return
+	}
+
+	var request struct {
+		ParentFeatureID *uint `json:"parent_feature_id"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{"parent_feature_id": nil}
+	if request.ParentFeatureID != nil {
+		updates["parent_feature_id"] = *request.ParentFeatureID
+	}
+
+	feature, ok := h.applyFeatureUpdates(c, featureID, updates, nil, nil, false)
+	if !ok {
+		return
+	}
+
+	setETag(c, feature.Version)
+	c.JSON(http.StatusOK, feature)
+}
+
+// CopyFeature duplicates a feature and its subtree, including tags and, unless
+// include_tasks is false, tasks. The copy goes below parent_feature_id, or to the top
+// level of project_id (defaulting to the source project) when no parent is given.
+func (h *FeatureHandler) CopyFeature(c *gin.Context) {
+	featureID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid feature ID"})
+		return
+	}
+
+	var request struct {
+		ParentFeatureID *uint `json:"parent_feature_id"`
+		ProjectID       int   `json:"project_id"`
+		IncludeTasks    *bool `json:"include_tasks"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	source, err := h.repo.GetFeatureByID(featureID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "feature not found"})
+		return
+	}
+
+	projectID := source.ProjectID
+	if request.ParentFeatureID != nil {
+		parent, err := h.repo.GetFeatureByID(int(*request.ParentFeatureID))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "parent feature not found"})
+			return
+		}
+		projectID = parent.ProjectID
+
+		// The copy keeps the source's dates, so they have to fit the new parent
+		if !h.checkDates(c, 0, &parent.ID, source.Window()) {
+			return
+		}
+	} else if request.ProjectID != 0 {
+		projectID = request.ProjectID
+	}
+
+	includeTasks := request.IncludeTasks == nil || *request.IncludeTasks
+
+	userID := currentUserID(c)
+
+	rootCopy, err := h.repo.CopyFeatureTree(source.ID, projectID, request.ParentFeatureID, includeTasks, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "project not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	copied, err := h.repo.GetFeatureByID(int(rootCopy.ID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	setETag(c, copied.Version)
+	c.JSON(http.StatusCreated, copied)
+}
@@ -0,0 +1,496 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"FeaturePlus/models"
+	"FeaturePlus/repositories"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type FeedbackHandler struct {
+	repo        *repositories.FeedbackRepository
+	projectRepo *repositories.ProjectRepository
+	featureRepo *repositories.FeatureRepository
+}
+
+func NewFeedbackHandler(repo *repositories.FeedbackRepository, projectRepo *repositories.ProjectRepository, featureRepo *repositories.FeatureRepository) *FeedbackHandler {
+	return &FeedbackHandler{repo: repo, projectRepo: projectRepo, featureRepo: featureRepo}
+}
+
+// feedbackInput is the writable part of a request. Status may only be set to open or
+// declined; merging and shipping have their own paths.
+type feedbackInput struct {
+	Title       string                    `json:"title" binding:"required"`
+	Description string                    `json:"description"`
+	Visibility  models.FeedbackVisibility `json:"visibility"`
+	Status      models.FeedbackStatus     `json:"status"`
+}
+
+// voteInput names the customer a vote is cast for; it is empty for a user's own vote
+type voteInput struct {
+	CustomerEmail string `json:"customer_email"`
+	CustomerName  string `json:"customer_name"`
+	Organisation  string `json:"organisation"`
+}
+
+// feedbackFilter reads the list filters shared by the team and portal lists. It
+// writes the error response itself and returns false on failure.
+func (h *FeedbackHandler) feedbackFilter(c *gin.Context) (repositories.FeedbackFilter, bool) {
+	projectID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		return repositories.FeedbackFilter{}, false
+	}
+	if _, err := h.projectRepo.GetProjectByID(projectID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return repositories.FeedbackFilter{}, false
+	}
+
+	filter := repositories.FeedbackFilter{
+		ProjectID: projectID,
+		Status:    models.FeedbackStatus(c.Query("status")),
+	}
+	switch c.DefaultQuery("sort", "votes") {
+	case "votes":
+	case "newest":
+		filter.Newest = true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be votes or newest"})
+		return repositories.FeedbackFilter{}, false
+	}
+	return filter, true
+}
+
+// GetRequests lists a project's feedback requests, most voted first. Filters are
+// status, visibility and organisation; sort=newest lists the latest first.
+func (h *FeedbackHandler) GetRequests(c *gin.Context) {
+	filter, ok := h.feedbackFilter(c)
+	if !ok {
+		return
+	}
+	filter.Visibility = models.FeedbackVisibility(c.Query("visibility"))
+	filter.Organisation = c.Query("organisation")
+	if filter.Visibility != "" && !models.IsValidFeedbackVisibility(filter.Visibility) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "visibility must be public or internal"})
+		return
+	}
+
+	requests, err := h.repo.ListRequests(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, requests)
+}
+
+// GetPublicRequests lists a project's public requests for the feedback portal, which
+// needs no login. It takes status and sort like the team's list.
+func (h *FeedbackHandler) GetPublicRequests(c *gin.Context) {
+	filter, ok := h.feedbackFilter(c)
+	if !ok {
+		return
+	}
+	filter.Visibility = models.FeedbackPublic
+
+	requests, err := h.repo.ListRequests(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	portal := make([]models.PublicFeedbackRequest, 0, len(requests))
+	for _, request := range requests {
+		public, err := h.publicRequest(request)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		portal = append(portal, public)
+	}
+	c.JSON(http.StatusOK, portal)
+}
+
+// GetPublicRequest returns a public request for the feedback portal. Internal
+// requests are not found there.
+func (h *FeedbackHandler) GetPublicRequest(c *gin.Context) {
+	request, ok := h.request(c)
+	if !ok {
+		return
+	}
+	if request.Visibility != models.FeedbackPublic {
+		c.JSON(http.StatusNotFound, gin.H{"error": "request not found"})
+		return
+	}
+
+	public, err := h.publicRequest(*request)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, public)
+}
+
+// publicRequest converts a public request for the portal, pointing a merged one at
+// its target only when the target is public too
+func (h *FeedbackHandler) publicRequest(request models.FeedbackRequest) (models.PublicFeedbackRequest, error) {
+	public := models.NewPublicFeedbackRequest(request)
+	if request.MergedIntoID == nil {
+		return public, nil
+	}
+	target, err := h.repo.GetRequest(int(*request.MergedIntoID))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return public, nil
+	}
+	if err != nil {
+		return public, err
+	}
+	if target.Visibility == models.FeedbackPublic {
+		public.MergedIntoID = request.MergedIntoID
+	}
+	return public, nil
+}
+
+// CreateRequest adds an open request to a project. Requests are public by default.
+func (h *FeedbackHandler) CreateRequest(c *gin.Context) {
+	projectID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		return
+	}
+	if _, err := h.projectRepo.GetProjectByID(projectID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return
+	}
+
+	var input feedbackInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Status != "" && input.Status != models.FeedbackOpen {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "new requests are open"})
+		return
+	}
+
+	request := models.FeedbackRequest{ProjectID: projectID, Status: models.FeedbackOpen, Visibility: models.FeedbackPublic, CreatedBy: currentUserID(c)}
+	if !applyFeedbackInput(c, &request, input) {
+		return
+	}
+
+	if err := h.repo.CreateRequest(&request); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, request)
+}
+
+func (h *FeedbackHandler) GetRequest(c *gin.Context) {
+	request, ok := h.request(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, request)
+}
+
+// UpdateRequest changes a request's text and visibility, and declines or reopens it.
+// Merged and shipped requests cannot change.
+func (h *FeedbackHandler) UpdateRequest(c *gin.Context) {
+	request, ok := h.request(c)
+	if !ok {
+		return
+	}
+	if request.Status == models.FeedbackMerged || request.Status == models.FeedbackShipped {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("request is %s", request.Status)})
+		return
+	}
+
+	var input feedbackInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Status != "" && input.Status != models.FeedbackOpen && input.Status != models.FeedbackDeclined {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be open or declined"})
+		return
+	}
+
+	if !applyFeedbackInput(c, request, input) {
+		return
+	}
+
+	if err := h.repo.UpdateRequest(request); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, request)
+}
+
+// DeleteRequest removes a request with its votes. Requests that others were merged
+// into cannot be deleted, since they hold the duplicates' votes.
+func (h *FeedbackHandler) DeleteRequest(c *gin.Context) {
+	request, ok := h.request(c)
+	if !ok {
+		return
+	}
+
+	merged, err := h.repo.CountMergedInto(request.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if merged > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "other requests were merged into this one"})
+		return
+	}
+
+	if err := h.repo.DeleteRequest(request.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetVotes lists who voted for a request, with the customers voted for
+func (h *FeedbackHandler) GetVotes(c *gin.Context) {
+	request, ok := h.request(c)
+	if !ok {
+		return
+	}
+
+	votes, err := h.repo.GetVotes(request.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, votes)
+}
+
+// AddVote upvotes a request for the current user, or on behalf of a customer
+func (h *FeedbackHandler) AddVote(c *gin.Context) {
+	request, ok := h.request(c)
+	if !ok {
+		return
+	}
+	switch request.Status {
+	case models.FeedbackMerged:
+		c.JSON(http.StatusConflict, gin.H{"error": "request was merged", "merged_into_id": request.MergedIntoID})
+		return
+	case models.FeedbackDeclined:
+		c.JSON(http.StatusConflict, gin.H{"error": "request is declined"})
+		return
+	}
+
+	var input voteInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	input.CustomerEmail = strings.ToLower(strings.TrimSpace(input.CustomerEmail))
+	if input.CustomerEmail != "" && !strings.Contains(input.CustomerEmail, "@") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "customer_email must be an email address"})
+		return
+	}
+	if input.CustomerEmail == "" && input.CustomerName != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "customer_email is required to vote for a customer"})
+		return
+	}
+
+	vote := models.FeedbackVote{
+		UserID:        currentUserID(c),
+		CustomerEmail: input.CustomerEmail,
+		CustomerName:  strings.TrimSpace(input.CustomerName),
+		Organisation:  strings.TrimSpace(input.Organisation),
+	}
+	if err := h.repo.AddVote(request, &vote); err != nil {
+		if errors.Is(err, repositories.ErrDuplicateVote) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"vote": vote, "vote_count": request.VoteCount})
+}
+
+// RemoveVote withdraws a vote; only the user who cast it may do so
+func (h *FeedbackHandler) RemoveVote(c *gin.Context) {
+	request, ok := h.request(c)
+	if !ok {
+		return
+	}
+	voteID, err := strconv.Atoi(c.Param("vote_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid vote ID"})
+		return
+	}
+
+	vote, err := h.repo.GetVote(request.ID, voteID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "vote not found"})
+		return
+	}
+	if vote.UserID != currentUserID(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the voter can withdraw a vote"})
+		return
+	}
+
+	if err := h.repo.RemoveVote(request, vote.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// MergeRequest marks a request as a duplicate of into_id and moves its votes there.
+// Both requests must be open and in the same project.
+func (h *FeedbackHandler) MergeRequest(c *gin.Context) {
+	request, ok := h.request(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		IntoID uint `json:"into_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.IntoID == request.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a request cannot be merged into itself"})
+		return
+	}
+
+	target, err := h.repo.GetRequest(int(input.IntoID))
+	if err != nil || target.ProjectID != request.ProjectID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "into_id must be a request in the same project"})
+		return
+	}
+	if request.Status != models.FeedbackOpen || target.Status != models.FeedbackOpen {
+		c.JSON(http.StatusConflict, gin.H{"error": "only open requests can be merged"})
+		return
+	}
+
+	if err := h.repo.MergeRequest(request, target); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, target)
+}
+
+// LinkFeature links a request to a feature of its project, or unlinks it with a null
+// feature_id. Linking to a feature that is already done ships the request.
+func (h *FeedbackHandler) LinkFeature(c *gin.Context) {
+	request, ok := h.request(c)
+	if !ok {
+		return
+	}
+	if request.Status != models.FeedbackOpen {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("request is %s", request.Status)})
+		return
+	}
+
+	var input struct {
+		FeatureID *uint `json:"feature_id"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.FeatureID != nil {
+		feature, err := h.featureRepo.GetFeatureByID(int(*input.FeatureID))
+		if err != nil || feature.ProjectID != request.ProjectID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "feature_id must be a feature in the request's project"})
+			return
+		}
+	}
+
+	if err := h.repo.LinkFeature(request, input.FeatureID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, request)
+}
+
+// GetNotifications lists the current user's feedback notifications, newest first.
+// unread=true leaves out those already read.
+func (h *FeedbackHandler) GetNotifications(c *gin.Context) {
+	notifications, err := h.repo.GetNotifications(currentUserID(c), c.Query("unread") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, notifications)
+}
+
+// ReadNotification marks one of the current user's notifications as read
+func (h *FeedbackHandler) ReadNotification(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid notification ID"})
+		return
+	}
+
+	notification, err := h.repo.MarkNotificationRead(currentUserID(c), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "notification not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, notification)
+}
+
+// request loads the request named in the URL. It writes the error response itself
+// and returns false on failure.
+func (h *FeedbackHandler) request(c *gin.Context) (*models.FeedbackRequest, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request ID"})
+		return nil, false
+	}
+	request, err := h.repo.GetRequest(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "request not found"})
+		return nil, false
+	}
+	return request, true
+}
+
+// applyFeedbackInput copies the input onto a request, keeping the visibility and
+// status when they are left out
+func applyFeedbackInput(c *gin.Context, request *models.FeedbackRequest, input feedbackInput) bool {
+	title := strings.TrimSpace(input.Title)
+	if title == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "title must not be empty"})
+		return false
+	}
+	if input.Visibility != "" && !models.IsValidFeedbackVisibility(input.Visibility) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "visibility must be public or internal"})
+		return false
+	}
+
+	request.Title, request.Description = title, input.Description
+	if input.Visibility != "" {
+		request.Visibility = input.Visibility
+	}
+	if input.Status != "" {
+		request.Status = input.Status
+	}
+	return true
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"FeaturePlus/models"
+	"FeaturePlus/repositories"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// feedbackPortal serves the portal routes over an in-memory database holding one
+// project with a public request (1), an internal one (2), and public requests merged
+// into each of them (3 into 1, 4 into 2)
+func feedbackPortal(t *testing.T) *gin.Engine {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := db.AutoMigrate(&models.User{}, &models.Project{}, &models.FeedbackRequest{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Omit("Owner").Create(&models.Project{Name: "P", OwnerID: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+	one, two := uint(1), uint(2)
+	requests := []models.FeedbackRequest{
+		{ProjectID: 1, Title: "Public", Visibility: models.FeedbackPublic, Status: models.FeedbackOpen, CreatedBy: 7},
+		{ProjectID: 1, Title: "Internal", Visibility: models.FeedbackInternal, Status: models.FeedbackOpen},
+		{ProjectID: 1, Title: "Into public", Visibility: models.FeedbackPublic, Status: models.FeedbackMerged, MergedIntoID: &one},
+		{ProjectID: 1, Title: "Into internal", Visibility: models.FeedbackPublic, Status: models.FeedbackMerged, MergedIntoID: &two},
+	}
+	if err := db.Create(&requests).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	handler := NewFeedbackHandler(repositories.NewFeedbackRepository(db), repositories.NewProjectRepository(db), repositories.NewFeatureRepository(db))
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/public/projects/:id/feedback", handler.GetPublicRequests)
+	router.GET("/api/public/feedback/:id", handler.GetPublicRequest)
+	return router
+}
+
+func getJSON(t *testing.T, router *gin.Engine, path string, out interface{}) int {
+	t.Helper()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	if out != nil && w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return w.Code
+}
+
+func TestPublicFeedbackListShowsOnlyPublicRequests(t *testing.T) {
+	router := feedbackPortal(t)
+
+	var open []map[string]interface{}
+	if code := getJSON(t, router, "/api/public/projects/1/feedback", &open); code != http.StatusOK {
+		t.Fatalf("status = %d", code)
+	}
+	if len(open) != 1 || open[0]["title"] != "Public" {
+		t.Fatalf("portal list = %v, want only the public open request", open)
+	}
+	for _, hidden := range []string{"created_by", "visibility", "feature_id"} {
+		if _, ok := open[0][hidden]; ok {
+			t.Errorf("portal request exposes %s", hidden)
+		}
+	}
+
+	var merged []models.PublicFeedbackRequest
+	getJSON(t, router, "/api/public/projects/1/feedback?status=merged", &merged)
+	if len(merged) != 2 {
+		t.Fatalf("got %d merged requests, want 2", len(merged))
+	}
+	for _, request := range merged {
+		switch request.Title {
+		case "Into public":
+			if request.MergedIntoID == nil || *request.MergedIntoID != 1 {
+				t.Errorf("merged_into_id = %v, want the public request 1", request.MergedIntoID)
+			}
+		case "Into internal":
+			if request.MergedIntoID != nil {
+				t.Errorf("merged_into_id = %d points at an internal request", *request.MergedIntoID)
+			}
+		}
+	}
+}
+
+func TestPublicFeedbackRequest(t *testing.T) {
+	router := feedbackPortal(t)
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/api/public/feedback/1", http.StatusOK},
+		{"/api/public/feedback/2", http.StatusNotFound},
+		{"/api/public/feedback/99", http.StatusNotFound},
+		{"/api/public/feedback/x", http.StatusBadRequest},
+		{"/api/public/projects/99/feedback", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if code := getJSON(t, router, tt.path, nil); code != tt.want {
+			t.Errorf("GET %s = %d, want %d", tt.path, code, tt.want)
+		}
+	}
+}
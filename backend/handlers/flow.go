@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"FeaturePlus/models"
+	"FeaturePlus/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultFlowWindow is how far back flow metrics look when since is left out
+const defaultFlowWindow = 90 * 24 * time.Hour
+
+// GetFlowMetrics reports lead time, cycle time and weekly throughput for features
+// completed between since and until, and the age of work in progress now, overall
+// and by priority, tag and assignee
+func (h *ProjectHandler) GetFlowMetrics(c *gin.Context) {
+	projectID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		return
+	}
+
+	now := time.Now()
+	until := now
+	if value := c.Query("until"); value != "" {
+		var ok bool
+		if until, ok = parseChangelogTime(value, true); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "until must be a date (YYYY-MM-DD) or an RFC 3339 time"})
+			return
+		}
+	}
+	since := until.Add(-defaultFlowWindow)
+	if value := c.Query("since"); value != "" {
+		var ok bool
+		if since, ok = parseChangelogTime(value, false); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "since must be a date (YYYY-MM-DD) or an RFC 3339 time"})
+			return
+		}
+	}
+	if since.After(until) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "since must not be after until"})
+		return
+	}
+
+	if _, err := h.repo.GetProjectByID(projectID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return
+	}
+
+	workflow, err := h.workflowRepo.GetWorkflow(projectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	features, err := h.featureRepo.FindFeatures(repositories.FeatureFilter{ProjectID: projectID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	history, err := h.featureRepo.GetProjectStatusHistory(projectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.BuildFlowReport(projectID, features, history, workflow, since, until, now))
+}
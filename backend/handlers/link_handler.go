@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"FeaturePlus/models"
+	"FeaturePlus/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LinkHandler struct {
+	repo        *repositories.LinkRepository
+	featureRepo *repositories.FeatureRepository
+}
+
+func NewLinkHandler(repo *repositories.LinkRepository, featureRepo *repositories.FeatureRepository) *LinkHandler {
+	return &LinkHandler{repo: repo, featureRepo: featureRepo}
+}
+
+// GetFeatureLinks lists a feature's links as seen from that feature
+func (h *LinkHandler) GetFeatureLinks(c *gin.Context) {
+	featureID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid feature ID"})
+		return
+	}
+
+	if _, err := h.featureRepo.GetFeatureByID(featureID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "feature not found"})
+		return
+	}
+
+	links, err := h.repo.GetLinksForFeature(uint(featureID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, links)
+}
+
+// CreateFeatureLink links the feature to another one. The type is read from the
+// feature's side, so {"type": "blocked_by", "target_id": 7} stores "7 blocks :id".
+func (h *LinkHandler) CreateFeatureLink(c *gin.Context) {
+	featureID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid feature ID"})
+		return
+	}
+
+	var request struct {
+		Type     models.LinkType `json:"type" binding:"required"`
+		TargetID uint            `json:"target_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	linkType, swap, ok := models.NormalizeLinkType(request.Type)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be one of blocks, blocked_by, relates_to, duplicates, duplicated_by, parent_of, child_of"})
+		return
+	}
+	if request.TargetID == uint(featureID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a feature cannot be linked to itself"})
+		return
+	}
+
+	feature, err := h.featureRepo.GetFeatureByID(featureID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "feature not found"})
+		return
+	}
+	if _, err := h.featureRepo.GetFeatureByID(int(request.TargetID)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "target feature not found"})
+		return
+	}
+
+	userID := currentUserID(c)
+
+	link := models.FeatureLink{SourceID: feature.ID, TargetID: request.TargetID, Type: linkType, CreatedByUser: userID}
+	if swap {
+		link.SourceID, link.TargetID = link.TargetID, link.SourceID
+	}
+
+	exists, err := h.repo.LinkExists(link.SourceID, link.TargetID, link.Type)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if exists {
+		c.JSON(http.StatusConflict, gin.H{"error": "these features are already linked this way"})
+		return
+	}
+
+	// relates_to has no direction, so only the directed types can form a cycle
+	if link.Type != models.LinkRelatesTo {
+		cycle, err := h.repo.WouldCycle(link.SourceID, link.TargetID, link.Type)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if cycle {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("this %s link would create a cycle", link.Type)})
+			return
+		}
+	}
+
+	if err := h.repo.CreateLink(&link); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, link)
+}
+
+// DeleteFeatureLink removes one of the feature's links
+func (h *LinkHandler) DeleteFeatureLink(c *gin.Context) {
+	featureID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid feature ID"})
+		return
+	}
+	linkID, err := strconv.Atoi(c.Param("link_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid link ID"})
+		return
+	}
+
+	link, err := h.repo.GetLinkByID(linkID)
+	if err != nil || (link.SourceID != uint(featureID) && link.TargetID != uint(featureID)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "link not found"})
+		return
+	}
+
+	if err := h.repo.DeleteLink(link.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetDependencyGraph returns the project's features and every link that touches them
+func (h *LinkHandler) GetDependencyGraph(c *gin.Context) {
+	projectID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		return
+	}
+
+	graph, err := h.repo.GetProjectGraph(projectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, graph)
+}
+
+// openBlockersPreventingDone returns the open blockers that stop a feature moving from
+// one status into a done status, when its project enforces blockers
+func openBlockersPreventingDone(features *repositories.FeatureRepository, feature *models.Feature, workflow *models.Workflow, from models.FeatureStatus, to models.FeatureStatus) ([]uint, error) {
+	if !feature.Project.EnforceBlockers || !workflow.IsDone(to) || workflow.IsDone(from) {
+		return nil, nil
+	}
+
+	blocked := []models.Feature{{ID: feature.ID}}
+	if err := features.SetBlockedStatus(blocked); err != nil {
+		return nil, err
+	}
+	return blocked[0].OpenBlockers, nil
+}
+
+func blockedMessage(blockers []uint) string {
+	ids := make([]string, 0, len(blockers))
+	for _, id := range blockers {
+		ids = append(ids, strconv.FormatUint(uint64(id), 10))
+	}
+	return "feature is blocked by open features " + strings.Join(ids, ", ")
+}
@@ -0,0 +1,293 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"FeaturePlus/models"
+	"FeaturePlus/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MilestoneHandler struct {
+	repo        *repositories.MilestoneRepository
+	projectRepo *repositories.ProjectRepository
+	featureRepo *repositories.FeatureRepository
+}
+
+func NewMilestoneHandler(repo *repositories.MilestoneRepository, projectRepo *repositories.ProjectRepository, featureRepo *repositories.FeatureRepository) *MilestoneHandler {
+	return &MilestoneHandler{repo: repo, projectRepo: projectRepo, featureRepo: featureRepo}
+}
+
+// milestoneInput is the writable part of a milestone; the state only changes through
+// the close and reopen actions
+type milestoneInput struct {
+	Name        string  `json:"name" binding:"required"`
+	Description string  `json:"description"`
+	TargetDate  *string `json:"target_date"`
+}
+
+// GetMilestones lists a project's milestones by target date
+func (h *MilestoneHandler) GetMilestones(c *gin.Context) {
+	projectID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		return
+	}
+	if _, err := h.projectRepo.GetProjectByID(projectID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return
+	}
+
+	milestones, err := h.repo.GetMilestonesByProject(projectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, milestones)
+}
+
+// CreateMilestone adds an open milestone to a project
+func (h *MilestoneHandler) CreateMilestone(c *gin.Context) {
+	projectID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		return
+	}
+	if _, err := h.projectRepo.GetProjectByID(projectID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return
+	}
+
+	var input milestoneInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	milestone := models.Milestone{ProjectID: projectID, State: models.MilestoneOpen}
+	if !h.applyInput(c, &milestone, input) {
+		return
+	}
+
+	if err := h.repo.CreateMilestone(&milestone); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, milestone)
+}
+
+// GetMilestone returns a milestone with the progress of its features
+func (h *MilestoneHandler) GetMilestone(c *gin.Context) {
+	milestone, ok := h.milestone(c)
+	if !ok {
+		return
+	}
+
+	rollup, err := h.repo.GetMilestoneRollup(milestone)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	milestone.Rollup = rollup
+
+	c.JSON(http.StatusOK, milestone)
+}
+
+// GetMilestoneFeatures lists the features scheduled into a milestone
+func (h *MilestoneHandler) GetMilestoneFeatures(c *gin.Context) {
+	milestone, ok := h.milestone(c)
+	if !ok {
+		return
+	}
+
+	features, err := h.featureRepo.FindFeatures(repositories.FeatureFilter{ProjectID: milestone.ProjectID, MilestoneID: &milestone.ID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, features)
+}
+
+// UpdateMilestone changes a milestone's name, description and target date
+func (h *MilestoneHandler) UpdateMilestone(c *gin.Context) {
+	milestone, ok := h.milestone(c)
+	if !ok {
+		return
+	}
+
+	var input milestoneInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !h.applyInput(c, milestone, input) {
+		return
+	}
+
+	if err := h.repo.UpdateMilestone(milestone); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, milestone)
+}
+
+// DeleteMilestone removes a milestone; its features become unscheduled
+func (h *MilestoneHandler) DeleteMilestone(c *gin.Context) {
+	milestone, ok := h.milestone(c)
+	if !ok {
+		return
+	}
+
+	if err := h.repo.DeleteMilestone(milestone.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// CloseMilestone closes a release and moves its unfinished features to
+// next_milestone_id, or to the next open milestone by target date when none is given
+func (h *MilestoneHandler) CloseMilestone(c *gin.Context) {
+	milestone, ok := h.milestone(c)
+	if !ok {
+		return
+	}
+	if milestone.State == models.MilestoneClosed {
+		c.JSON(http.StatusConflict, gin.H{"error": "milestone is already closed"})
+		return
+	}
+
+	var request struct {
+		NextMilestoneID *uint `json:"next_milestone_id"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	nextID := request.NextMilestoneID
+	if nextID != nil {
+		if *nextID == milestone.ID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "a milestone cannot be its own next milestone"})
+			return
+		}
+		if err := checkMilestone(h.repo, milestone.ProjectID, *nextID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	} else {
+		next, err := h.repo.NextMilestone(milestone)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if next != nil {
+			nextID = &next.ID
+		}
+	}
+
+	moved, err := h.repo.CloseMilestone(milestone, nextID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNoNextMilestone) {
+			c.JSON(http.StatusConflict, gin.H{"error": "milestone has unfinished features and there is no open milestone after it; pass next_milestone_id"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := gin.H{"milestone": milestone, "moved_feature_ids": moved}
+	if len(moved) > 0 {
+		response["next_milestone_id"] = *nextID
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// ReopenMilestone puts a closed milestone back into the open state
+func (h *MilestoneHandler) ReopenMilestone(c *gin.Context) {
+	milestone, ok := h.milestone(c)
+	if !ok {
+		return
+	}
+	if milestone.State == models.MilestoneOpen {
+		c.JSON(http.StatusConflict, gin.H{"error": "milestone is already open"})
+		return
+	}
+
+	milestone.State = models.MilestoneOpen
+	milestone.ClosedAt = nil
+	if err := h.repo.UpdateMilestone(milestone); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, milestone)
+}
+
+// milestone loads the milestone named in the URL
+func (h *MilestoneHandler) milestone(c *gin.Context) (*models.Milestone, bool) {
+	milestoneID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid milestone ID"})
+		return nil, false
+	}
+	milestone, err := h.repo.GetMilestone(milestoneID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "milestone not found"})
+		return nil, false
+	}
+	return milestone, true
+}
+
+// applyInput validates the input and copies it onto the milestone
+func (h *MilestoneHandler) applyInput(c *gin.Context, milestone *models.Milestone, input milestoneInput) bool {
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name must not be empty"})
+		return false
+	}
+	if input.TargetDate != nil {
+		if _, err := time.Parse("2006-01-02", *input.TargetDate); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "target_date must be a date in YYYY-MM-DD format"})
+			return false
+		}
+	}
+	if existing, err := h.repo.GetMilestoneByName(milestone.ProjectID, input.Name); err == nil && existing.ID != milestone.ID {
+		c.JSON(http.StatusConflict, gin.H{"error": "a milestone with this name already exists"})
+		return false
+	}
+
+	milestone.Name = input.Name
+	milestone.Description = input.Description
+	milestone.TargetDate = input.TargetDate
+	return true
+}
+
+// checkMilestone reports why a feature of the project cannot be scheduled into the
+// milestone, or nil if it can
+func checkMilestone(milestones *repositories.MilestoneRepository, projectID int, milestoneID uint) error {
+	milestone, err := milestones.GetMilestone(int(milestoneID))
+	if err != nil {
+		return errors.New("milestone not found")
+	}
+	if milestone.ProjectID != projectID {
+		return errors.New("milestone belongs to another project")
+	}
+	if milestone.State == models.MilestoneClosed {
+		return errors.New("milestone is closed")
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"FeaturePlus/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// mergePatch is a decoded JSON Merge Patch (RFC 7396) document. Members that are
+// absent leave the target untouched, members set to null clear the target field.
+type mergePatch map[string]json.RawMessage
+
+// patchField describes how a single merge patch member maps onto a database column
+type patchField struct {
+	column string
+	// nullValue is written when the member is null; nil means null is rejected
+	nullValue interface{}
+	nullable  bool
+	decode    func(raw json.RawMessage) (interface{}, error)
+}
+
+// bindMergePatch reads the request body as a JSON Merge Patch object
+func bindMergePatch(c *gin.Context) (mergePatch, error) {
+	if contentType := c.GetHeader("Content-Type"); contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || (mediaType != "application/merge-patch+json" && mediaType != "application/json") {
+			return nil, errUnsupportedPatchType
+		}
+	}
+
+	var raw json.RawMessage
+	if err := json.NewDecoder(c.Request.Body).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("invalid merge patch: %w", err)
+	}
+
+	// RFC 7396 allows a non-object patch to replace the whole target, which
+	// makes no sense for our resources, so only objects are accepted
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) == 0 || trimmed[0] != '{' {
+		return nil, errors.New("merge patch must be a JSON object")
+	}
+
+	var patch mergePatch
+	if err := json.Unmarshal(raw, &patch); err != nil {
+		return nil, fmt.Errorf("invalid merge patch: %w", err)
+	}
+	return patch, nil
+}
+
+var errUnsupportedPatchType = errors.New("content type must be application/merge-patch+json or application/json")
+
+// respondPatchBindError writes the response for a bindMergePatch failure
+func respondPatchBindError(c *gin.Context, err error) {
+	if errors.Is(err, errUnsupportedPatchType) {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
+
+// isNull reports whether the member is present and explicitly null
+func isNull(raw json.RawMessage) bool {
+	return string(bytes.TrimSpace(raw)) == "null"
+}
+
+// buildUpdates validates every member of the patch against the allowed fields
+// and returns the column updates to apply. Unknown members are rejected.
+func buildUpdates(patch mergePatch, fields map[string]patchField) (map[string]interface{}, error) {
+	updates := make(map[string]interface{}, len(patch))
+	for key, raw := range patch {
+		field, ok := fields[key]
+		if !ok {
+			return nil, fmt.Errorf("field %q cannot be patched", key)
+		}
+
+		if isNull(raw) {
+			if !field.nullable {
+				return nil, fmt.Errorf("%s cannot be null", key)
+			}
+			updates[field.column] = field.nullValue
+			continue
+		}
+
+		value, err := field.decode(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", key, err)
+		}
+		updates[field.column] = value
+	}
+	return updates, nil
+}
+
+// stringPatch decodes a string member; required strings may not be blank
+func stringPatch(required bool) func(json.RawMessage) (interface{}, error) {
+	return func(raw json.RawMessage) (interface{}, error) {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, errors.New("must be a string")
+		}
+		if required && strings.TrimSpace(s) == "" {
+			return nil, errors.New("must not be empty")
+		}
+		return s, nil
+	}
+}
+
+// uintPatch decodes a non-negative integer member
+func uintPatch(allowZero bool) func(json.RawMessage) (interface{}, error) {
+	return func(raw json.RawMessage) (interface{}, error) {
+		var n uint
+		if err := json.Unmarshal(raw, &n); err != nil {
+			return nil, errors.New("must be a non-negative integer")
+		}
+		if !allowZero && n == 0 {
+			return nil, errors.New("must not be zero")
+		}
+		return n, nil
+	}
+}
+
+func boolPatch(raw json.RawMessage) (interface{}, error) {
+	var b bool
+	if err := json.Unmarshal(raw, &b); err != nil {
+		return nil, errors.New("must be true or false")
+	}
+	return b, nil
+}
+
+func statusPatch(raw json.RawMessage) (interface{}, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil || s == "" {
+		return nil, errors.New("must be a status from the project's workflow")
+	}
+	return models.FeatureStatus(s), nil
+}
+
+func priorityPatch(raw json.RawMessage) (interface{}, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil || !isValidPriority(models.FeaturePriority(s)) {
+		return nil, errors.New("must be one of low, medium, high")
+	}
+	return s, nil
+}
+
+func estimateUnitPatch(raw json.RawMessage) (interface{}, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil || !models.IsValidEstimateUnit(models.EstimateUnit(s)) {
+		return nil, errors.New("must be points or hours")
+	}
+	return s, nil
+}
+
+func estimateScalePatch(raw json.RawMessage) (interface{}, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil || !models.IsValidEstimateScale(models.EstimateScale(s)) {
+		return nil, errors.New("must be fibonacci, tshirt or free")
+	}
+	return s, nil
+}
+
+func scoringFrameworkPatch(raw json.RawMessage) (interface{}, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil || !models.IsValidScoringFramework(models.ScoringFramework(s)) {
+		return nil, errors.New("must be rice or wsjf")
+	}
+	return s, nil
+}
+
+// scorePatch decodes a non-negative scoring input
+func scorePatch(raw json.RawMessage) (interface{}, error) {
+	var n float64
+	if err := json.Unmarshal(raw, &n); err != nil || n < 0 {
+		return nil, errors.New("must be a non-negative number")
+	}
+	return n, nil
+}
+
+// estimatePatch decodes an estimate given as a number or a t-shirt size
+func estimatePatch(raw json.RawMessage) (interface{}, error) {
+	var estimate models.Estimate
+	if err := json.Unmarshal(raw, &estimate); err != nil {
+		return nil, err
+	}
+	return estimate, nil
+}
+
+// datePatch decodes a YYYY-MM-DD date
+func datePatch(raw json.RawMessage) (interface{}, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, errors.New("must be a date string")
+	}
+	if _, err := time.Parse(models.DateLayout, s); err != nil {
+		return nil, errors.New("must be a date in YYYY-MM-DD format")
+	}
+	return s, nil
+}
+
+// changelogTemplatePatch decodes a changelog template and checks it parses
+func changelogTemplatePatch(format models.ChangelogFormat) func(json.RawMessage) (interface{}, error) {
+	return func(raw json.RawMessage) (interface{}, error) {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, errors.New("must be a string")
+		}
+		if err := models.CheckChangelogTemplate(format, s); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+}
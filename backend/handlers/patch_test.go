@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testPatchFields covers each kind of member a handler allows
+var testPatchFields = map[string]patchField{
+	"title":       {column: "title", decode: stringPatch(true)},
+	"description": {column: "description", nullable: true, nullValue: "", decode: stringPatch(false)},
+	"assignee_id": {column: "assignee_id", nullable: true, nullValue: nil, decode: uintPatch(false)},
+	"archived":    {column: "archived", decode: boolPatch},
+	"due_date":    {column: "due_date", nullable: true, nullValue: nil, decode: datePatch},
+}
+
+func TestBuildUpdates(t *testing.T) {
+	tests := []struct {
+		name    string
+		patch   string
+		want    map[string]interface{}
+		wantErr string
+	}{
+		{"empty patch", `{}`, map[string]interface{}{}, ""},
+		{"absent members are left alone", `{"title":"New"}`, map[string]interface{}{"title": "New"}, ""},
+		{"null clears to the null value", `{"description":null}`, map[string]interface{}{"description": ""}, ""},
+		{"null clears to NULL", `{"assignee_id":null,"due_date":null}`, map[string]interface{}{"assignee_id": nil, "due_date": nil}, ""},
+		{"null with spaces", `{"assignee_id": null }`, map[string]interface{}{"assignee_id": nil}, ""},
+		{"several members", `{"title":"T","archived":true,"assignee_id":3}`, map[string]interface{}{"title": "T", "archived": true, "assignee_id": uint(3)}, ""},
+		{"empty string is not null", `{"description":""}`, map[string]interface{}{"description": ""}, ""},
+		{"unknown member", `{"owner":1}`, nil, `field "owner" cannot be patched`},
+		{"null on a required member", `{"title":null}`, nil, "title cannot be null"},
+		{"blank required string", `{"title":"  "}`, nil, "invalid title: must not be empty"},
+		{"wrong type", `{"archived":"yes"}`, nil, "invalid archived: must be true or false"},
+		{"zero id", `{"assignee_id":0}`, nil, "invalid assignee_id: must not be zero"},
+		{"bad date", `{"due_date":"19/10/2026"}`, nil, "invalid due_date: must be a date in YYYY-MM-DD format"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var patch mergePatch
+			if err := json.Unmarshal([]byte(tt.patch), &patch); err != nil {
+				t.Fatal(err)
+			}
+			got, err := buildUpdates(patch, testPatchFields)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("updates = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBindMergePatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantKeys    []string
+		wantErr     error
+	}{
+		{"merge patch", "application/merge-patch+json", `{"title":"T","description":null}`, []string{"description", "title"}, nil},
+		{"plain json", "application/json; charset=utf-8", `{"title":"T"}`, []string{"title"}, nil},
+		{"no content type", "", `{}`, []string{}, nil},
+		{"wrong content type", "text/plain", `{}`, nil, errUnsupportedPatchType},
+		{"array", "application/merge-patch+json", `[{"title":"T"}]`, nil, errors.New("merge patch must be a JSON object")},
+		{"null document", "application/merge-patch+json", `null`, nil, errors.New("merge patch must be a JSON object")},
+		{"malformed", "application/merge-patch+json", `{"title":`, nil, errors.New("invalid merge patch")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("PATCH", "/", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				c.Request.Header.Set("Content-Type", tt.contentType)
+			}
+
+			patch, err := bindMergePatch(c)
+			if tt.wantErr != nil {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr.Error()) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, key := range tt.wantKeys {
+				if _, ok := patch[key]; !ok {
+					t.Errorf("patch is missing %q", key)
+				}
+			}
+			if len(patch) != len(tt.wantKeys) {
+				t.Errorf("patch has %d members, want %d", len(patch), len(tt.wantKeys))
+			}
+		})
+	}
+}
+
+func TestBindMergePatchKeepsNull(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("PATCH", "/", strings.NewReader(`{"assignee_id":null}`))
+
+	patch, err := bindMergePatch(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, ok := patch["assignee_id"]
+	if !ok || !isNull(raw) {
+		t.Fatalf("assignee_id = %q, want an explicit null", raw)
+	}
+}
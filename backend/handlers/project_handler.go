@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -11,11 +12,13 @@ import (
 )
 
 type ProjectHandler struct {
-	repo *repositories.ProjectRepository
+	repo         *repositories.ProjectRepository
+	featureRepo  *repositories.FeatureRepository
+	workflowRepo *repositories.WorkflowRepository
 }
 
-func NewProjectHandler(repo *repositories.ProjectRepository) *ProjectHandler {
-	return &ProjectHandler{repo: repo}
+func NewProjectHandler(repo *repositories.ProjectRepository, featureRepo *repositories.FeatureRepository, workflowRepo *repositories.WorkflowRepository) *ProjectHandler {
+	return &ProjectHandler{repo: repo, featureRepo: featureRepo, workflowRepo: workflowRepo}
 }
 
 // CreateProject handles project creation
@@ -31,11 +34,22 @@ func (h *ProjectHandler) CreateProject(c *gin.Context) {
 		return
 	}
 
+	if !checkEstimation(c, &project, models.EstimatePoints, models.ScaleFibonacci) {
+		return
+	}
+	if !checkScoringFramework(c, &project, models.ScoringRICE) {
+		return
+	}
+	if !checkChangelogTemplates(c, &project) {
+		return
+	}
+
 	if err := h.repo.CreateProject(&project); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	setETag(c, project.Version)
 	c.JSON(http.StatusCreated, project)
 }
 
@@ -64,7 +78,15 @@ func (h *ProjectHandler) GetProject(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, project)
+	rollup, err := h.featureRepo.GetProjectRollup(project.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	project.Rollup = rollup
+
+	// The rollup changes without the project's version, so the ETag covers the whole body
+	respondWithETag(c, project.Version, project)
 }
 
 // UpdateProject handles project updates
@@ -82,15 +104,153 @@ func (h *ProjectHandler) UpdateProject(c *gin.Context) {
 		return
 	}
 
+	existingProject, err := h.repo.GetProjectByID(projectID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return
+	}
+
+	if !checkIfMatch(c, existingProject.Version) {
+		return
+	}
+
 	project.ID = projectID
+	project.Version = existingProject.Version
+	project.CreatedAt = existingProject.CreatedAt
+	if !checkEstimation(c, &project, existingProject.EstimateUnit, existingProject.EstimateScale) {
+		return
+	}
+	if !checkScoringFramework(c, &project, existingProject.ScoringFramework) {
+		return
+	}
+	if !checkChangelogTemplates(c, &project) {
+		return
+	}
 	if err := h.repo.UpdateProject(&project); err != nil {
+		if errors.Is(err, repositories.ErrVersionConflict) {
+			respondVersionConflict(c)
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	setETag(c, project.Version)
 	c.JSON(http.StatusOK, project)
 }
 
+// projectPatchFields lists the project members that can be changed through PATCH
+var projectPatchFields = map[string]patchField{
+	"name":                  {column: "name", decode: stringPatch(true)},
+	"description":           {column: "description", nullable: true, nullValue: "", decode: stringPatch(false)},
+	"owner_id":              {column: "owner_id", decode: uintPatch(false)},
+	"auto_complete_parents": {column: "auto_complete_parents", decode: boolPatch},
+	"enforce_blockers":      {column: "enforce_blockers", decode: boolPatch},
+	"estimate_unit":         {column: "estimate_unit", decode: estimateUnitPatch},
+	"estimate_scale":        {column: "estimate_scale", decode: estimateScalePatch},
+	"scoring_framework":     {column: "scoring_framework", decode: scoringFrameworkPatch},
+
+	"changelog_markdown_template": {column: "changelog_markdown_template", nullable: true, nullValue: "", decode: changelogTemplatePatch(models.ChangelogMarkdown)},
+	"changelog_html_template":     {column: "changelog_html_template", nullable: true, nullValue: "", decode: changelogTemplatePatch(models.ChangelogHTML)},
+}
+
+// PatchProject handles partial project updates using JSON Merge Patch
+func (h *ProjectHandler) PatchProject(c *gin.Context) {
+	idStr := c.Param("id")
+	projectID, err := strconv.Atoi(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		return
+	}
+
+	patch, err := bindMergePatch(c)
+	if err != nil {
+		respondPatchBindError(c, err)
+		return
+	}
+
+	updates, err := buildUpdates(patch, projectPatchFields)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	existingProject, err := h.repo.GetProjectByID(projectID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return
+	}
+
+	if !checkIfMatch(c, existingProject.Version) {
+		return
+	}
+
+	if err := h.repo.PatchProject(projectID, existingProject.Version, updates); err != nil {
+		if errors.Is(err, repositories.ErrVersionConflict) {
+			respondVersionConflict(c)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	project, err := h.repo.GetProjectByID(projectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	setETag(c, project.Version)
+	c.JSON(http.StatusOK, project)
+}
+
+// checkEstimation fills in the estimate unit and scale when they are left out and
+// rejects unknown ones. It writes the error response itself and returns false on failure.
+func checkEstimation(c *gin.Context, project *models.Project, unit models.EstimateUnit, scale models.EstimateScale) bool {
+	if project.EstimateUnit == "" {
+		project.EstimateUnit = unit
+	}
+	if project.EstimateScale == "" {
+		project.EstimateScale = scale
+	}
+	if !models.IsValidEstimateUnit(project.EstimateUnit) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "estimate_unit must be points or hours"})
+		return false
+	}
+	if !models.IsValidEstimateScale(project.EstimateScale) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "estimate_scale must be fibonacci, tshirt or free"})
+		return false
+	}
+	return true
+}
+
+// checkScoringFramework fills in the scoring framework when it is left out and rejects
+// unknown ones. It writes the error response itself and returns false on failure.
+func checkScoringFramework(c *gin.Context, project *models.Project, framework models.ScoringFramework) bool {
+	if project.ScoringFramework == "" {
+		project.ScoringFramework = framework
+	}
+	if !models.IsValidScoringFramework(project.ScoringFramework) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scoring_framework must be rice or wsjf"})
+		return false
+	}
+	return true
+}
+
+// checkChangelogTemplates rejects stored changelog templates that are too large or do
+// not parse. It writes the error response itself and returns false on failure.
+func checkChangelogTemplates(c *gin.Context, project *models.Project) bool {
+	if err := models.CheckChangelogTemplate(models.ChangelogMarkdown, project.ChangelogMarkdownTemplate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid changelog_markdown_template: " + err.Error()})
+		return false
+	}
+	if err := models.CheckChangelogTemplate(models.ChangelogHTML, project.ChangelogHTMLTemplate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid changelog_html_template: " + err.Error()})
+		return false
+	}
+	return true
+}
+
 // DeleteProject handles project deletion
 func (h *ProjectHandler) DeleteProject(c *gin.Context) {
 	idStr := c.Param("id")
@@ -100,7 +260,21 @@ func (h *ProjectHandler) DeleteProject(c *gin.Context) {
 		return
 	}
 
-	if err := h.repo.DeleteProject(projectID); err != nil {
+	existingProject, err := h.repo.GetProjectByID(projectID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return
+	}
+
+	if !checkIfMatch(c, existingProject.Version) {
+		return
+	}
+
+	if err := h.repo.DeleteProject(projectID, existingProject.Version); err != nil {
+		if errors.Is(err, repositories.ErrVersionConflict) {
+			respondVersionConflict(c)
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
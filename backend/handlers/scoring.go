@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+
+	"FeaturePlus/models"
+	"FeaturePlus/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+// checkScoring validates a feature's scoring inputs. It writes the error response
+// itself and returns false on failure.
+func checkScoring(c *gin.Context, inputs models.ScoringInputs) bool {
+	if problem := inputs.Check(); problem != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": problem})
+		return false
+	}
+	return true
+}
+
+// scoringInputFields maps the PATCH members of the scoring inputs to their fields
+func scoringInputFields(inputs *models.ScoringInputs) map[string]**float64 {
+	return map[string]**float64{
+		"reach":            &inputs.Reach,
+		"impact":           &inputs.Impact,
+		"confidence":       &inputs.Confidence,
+		"effort":           &inputs.Effort,
+		"business_value":   &inputs.BusinessValue,
+		"time_criticality": &inputs.TimeCriticality,
+		"risk_reduction":   &inputs.RiskReduction,
+		"job_size":         &inputs.JobSize,
+	}
+}
+
+// patchedScoring returns the scoring inputs after the updates, and whether they change
+func patchedScoring(inputs models.ScoringInputs, updates map[string]interface{}) (models.ScoringInputs, bool) {
+	changed := false
+	for name, field := range scoringInputFields(&inputs) {
+		value, ok := updates[name]
+		if !ok {
+			continue
+		}
+		*field, changed = nil, true
+		if n, ok := value.(float64); ok {
+			*field = &n
+		}
+	}
+	return inputs, changed
+}
+
+// mergeScoring copies the inputs that are set in given over inputs, keeping the rest
+func mergeScoring(inputs *models.ScoringInputs, given models.ScoringInputs) {
+	fields := scoringInputFields(inputs)
+	for name, value := range scoringInputFields(&given) {
+		if *value != nil {
+			*fields[name] = *value
+		}
+	}
+}
+
+// scoringFramework reads the framework query parameter, falling back to the project's
+// framework. It writes the error response itself and returns false on failure.
+func scoringFramework(c *gin.Context, project *models.Project) (models.ScoringFramework, bool) {
+	framework := models.ScoringFramework(c.DefaultQuery("framework", string(project.ScoringFramework)))
+	if !models.IsValidScoringFramework(framework) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "framework must be rice or wsjf"})
+		return "", false
+	}
+	return framework, true
+}
+
+// GetScores ranks a project's open features by score, highest first. Features missing
+// inputs come last. include_done=true ranks done features too.
+func (h *ProjectHandler) GetScores(c *gin.Context) {
+	projectID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		return
+	}
+
+	project, err := h.repo.GetProjectByID(projectID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return
+	}
+
+	framework, ok := scoringFramework(c, project)
+	if !ok {
+		return
+	}
+	includeDone := c.Query("include_done") == "true"
+
+	workflow, err := h.workflowRepo.GetWorkflow(projectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	features, err := h.featureRepo.FindFeatures(repositories.FeatureFilter{ProjectID: projectID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ranking := models.ScoreRanking{ProjectID: projectID, Framework: framework, Features: []models.ScoredFeature{}}
+	for _, feature := range features {
+		if !includeDone && workflow.IsDone(feature.Status) {
+			continue
+		}
+		explanation := feature.ScoringInputs.Explain(framework)
+		ranking.Features = append(ranking.Features, models.ScoredFeature{
+			ID:       feature.ID,
+			Title:    feature.Title,
+			Status:   feature.Status,
+			Priority: feature.Priority,
+			Score:    explanation.Score,
+			Missing:  explanation.Missing,
+		})
+	}
+	sort.SliceStable(ranking.Features, func(i, j int) bool {
+		a, b := ranking.Features[i].Score, ranking.Features[j].Score
+		if a == nil || b == nil {
+			return a != nil && b == nil
+		}
+		return *a > *b
+	})
+
+	c.JSON(http.StatusOK, ranking)
+}
+
+// GetScore explains how a feature's score is computed under its project's framework,
+// or the one given in the framework query parameter
+func (h *FeatureHandler) GetScore(c *gin.Context) {
+	featureID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid feature ID"})
+		return
+	}
+
+	feature, err := h.repo.GetFeatureByID(featureID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "feature not found"})
+		return
+	}
+
+	framework, ok := scoringFramework(c, &feature.Project)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, feature.ScoringInputs.Explain(framework))
+}
@@ -0,0 +1,460 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"FeaturePlus/models"
+	"FeaturePlus/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SprintHandler struct {
+	repo        *repositories.SprintRepository
+	projectRepo *repositories.ProjectRepository
+	featureRepo *repositories.FeatureRepository
+	taskRepo    repositories.TaskRepository
+}
+
+func NewSprintHandler(repo *repositories.SprintRepository, projectRepo *repositories.ProjectRepository, featureRepo *repositories.FeatureRepository, taskRepo repositories.TaskRepository) *SprintHandler {
+	return &SprintHandler{repo: repo, projectRepo: projectRepo, featureRepo: featureRepo, taskRepo: taskRepo}
+}
+
+// sprintInput is the writable part of a sprint; the state only changes through the
+// start and close actions
+type sprintInput struct {
+	Name      string `json:"name" binding:"required"`
+	Goal      string `json:"goal"`
+	StartDate string `json:"start_date" binding:"required"`
+	EndDate   string `json:"end_date" binding:"required"`
+}
+
+// GetSprints lists a project's sprints by start date
+func (h *SprintHandler) GetSprints(c *gin.Context) {
+	projectID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		return
+	}
+	if _, err := h.projectRepo.GetProjectByID(projectID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return
+	}
+
+	sprints, err := h.repo.GetSprintsByProject(projectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, sprints)
+}
+
+// CreateSprint adds a planned sprint to a project
+func (h *SprintHandler) CreateSprint(c *gin.Context) {
+	projectID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		return
+	}
+	if _, err := h.projectRepo.GetProjectByID(projectID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return
+	}
+
+	var input sprintInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sprint := models.Sprint{ProjectID: projectID, State: models.SprintPlanned}
+	if !h.applyInput(c, &sprint, input) {
+		return
+	}
+
+	if err := h.repo.CreateSprint(&sprint); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, sprint)
+}
+
+// GetSprint returns a sprint with its current scope and progress
+func (h *SprintHandler) GetSprint(c *gin.Context) {
+	sprint, ok := h.sprint(c)
+	if !ok {
+		return
+	}
+
+	progress, err := h.repo.GetSprintProgress(sprint)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	sprint.Progress = progress
+
+	c.JSON(http.StatusOK, sprint)
+}
+
+// UpdateSprint changes the name, goal and dates of a sprint that is not closed
+func (h *SprintHandler) UpdateSprint(c *gin.Context) {
+	sprint, ok := h.sprint(c)
+	if !ok {
+		return
+	}
+	if sprint.State == models.SprintClosed {
+		c.JSON(http.StatusConflict, gin.H{"error": "sprint is closed"})
+		return
+	}
+
+	var input sprintInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !h.applyInput(c, sprint, input) {
+		return
+	}
+
+	if err := h.repo.UpdateSprint(sprint); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, sprint)
+}
+
+// DeleteSprint removes a planned sprint; its features and tasks leave the sprint
+func (h *SprintHandler) DeleteSprint(c *gin.Context) {
+	sprint, ok := h.sprint(c)
+	if !ok {
+		return
+	}
+	if sprint.State != models.SprintPlanned {
+		c.JSON(http.StatusConflict, gin.H{"error": "only planned sprints can be deleted"})
+		return
+	}
+
+	if err := h.repo.DeleteSprint(sprint.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetSprintItems lists the features and tasks in a sprint
+func (h *SprintHandler) GetSprintItems(c *gin.Context) {
+	sprint, ok := h.sprint(c)
+	if !ok {
+		return
+	}
+
+	items, err := h.repo.GetSprintItems(sprint.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, items)
+}
+
+// AddFeature moves a feature of the sprint's project into the sprint
+func (h *SprintHandler) AddFeature(c *gin.Context) {
+	h.moveFeature(c, true)
+}
+
+// RemoveFeature takes a feature out of the sprint
+func (h *SprintHandler) RemoveFeature(c *gin.Context) {
+	h.moveFeature(c, false)
+}
+
+// AddTask moves a task of the sprint's project into the sprint
+func (h *SprintHandler) AddTask(c *gin.Context) {
+	h.moveTask(c, true)
+}
+
+// RemoveTask takes a task out of the sprint
+func (h *SprintHandler) RemoveTask(c *gin.Context) {
+	h.moveTask(c, false)
+}
+
+func (h *SprintHandler) moveFeature(c *gin.Context, add bool) {
+	sprint, ok := h.openSprint(c)
+	if !ok {
+		return
+	}
+	featureID, err := strconv.Atoi(c.Param("feature_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid feature ID"})
+		return
+	}
+	feature, err := h.featureRepo.GetFeatureByID(featureID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "feature not found"})
+		return
+	}
+	if feature.ProjectID != sprint.ProjectID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "feature belongs to another project"})
+		return
+	}
+
+	target := sprint
+	if !add {
+		if feature.SprintID == nil || *feature.SprintID != sprint.ID {
+			c.JSON(http.StatusNotFound, gin.H{"error": "feature is not in this sprint"})
+			return
+		}
+		target = nil
+	}
+	if !h.checkLeaving(c, feature.SprintID, sprint) {
+		return
+	}
+
+	if err := h.repo.MoveItem(&feature.ID, nil, target, currentUserID(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *SprintHandler) moveTask(c *gin.Context, add bool) {
+	sprint, ok := h.openSprint(c)
+	if !ok {
+		return
+	}
+	taskID, err := strconv.ParseUint(c.Param("task_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid task ID"})
+		return
+	}
+	task, err := h.taskRepo.GetByID(uint(taskID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "task not found"})
+		return
+	}
+	feature, err := h.featureRepo.GetFeatureByID(int(task.FeatureID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "feature not found"})
+		return
+	}
+	if feature.ProjectID != sprint.ProjectID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "task belongs to another project"})
+		return
+	}
+
+	target := sprint
+	if !add {
+		if task.SprintID == nil || *task.SprintID != sprint.ID {
+			c.JSON(http.StatusNotFound, gin.H{"error": "task is not in this sprint"})
+			return
+		}
+		target = nil
+	}
+	if !h.checkLeaving(c, task.SprintID, sprint) {
+		return
+	}
+
+	if err := h.repo.MoveItem(nil, &task.ID, target, currentUserID(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// checkLeaving refuses to pull an item out of a closed sprint, whose record of
+// completed work must stay intact
+func (h *SprintHandler) checkLeaving(c *gin.Context, currentID *uint, sprint *models.Sprint) bool {
+	if currentID == nil || *currentID == sprint.ID {
+		return true
+	}
+	current, err := h.repo.GetSprint(int(*currentID))
+	if err != nil {
+		return true
+	}
+	if current.State == models.SprintClosed {
+		c.JSON(http.StatusConflict, gin.H{"error": "item belongs to a closed sprint"})
+		return false
+	}
+	return true
+}
+
+// StartSprint makes a planned sprint active and records its scope as the commitment.
+// A project runs one sprint at a time.
+func (h *SprintHandler) StartSprint(c *gin.Context) {
+	sprint, ok := h.sprint(c)
+	if !ok {
+		return
+	}
+	if sprint.State != models.SprintPlanned {
+		c.JSON(http.StatusConflict, gin.H{"error": "only planned sprints can be started"})
+		return
+	}
+
+	active, err := h.repo.GetActiveSprint(sprint.ProjectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if active != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "sprint " + active.Name + " is already active in this project"})
+		return
+	}
+
+	if err := h.repo.StartSprint(sprint); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, sprint)
+}
+
+// CloseSprint closes an active sprint and reports completed versus carried-over work.
+// Unfinished features and tasks move to next_sprint_id, or to the next planned sprint
+// by start date when none is given.
+func (h *SprintHandler) CloseSprint(c *gin.Context) {
+	sprint, ok := h.sprint(c)
+	if !ok {
+		return
+	}
+	if sprint.State != models.SprintActive {
+		c.JSON(http.StatusConflict, gin.H{"error": "only active sprints can be closed"})
+		return
+	}
+
+	var request struct {
+		NextSprintID *uint `json:"next_sprint_id"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	nextID := request.NextSprintID
+	if nextID != nil {
+		if *nextID == sprint.ID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "a sprint cannot be its own next sprint"})
+			return
+		}
+		next, err := h.repo.GetSprint(int(*nextID))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "next sprint not found"})
+			return
+		}
+		if next.ProjectID != sprint.ProjectID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "next sprint belongs to another project"})
+			return
+		}
+		if next.State == models.SprintClosed {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "next sprint is closed"})
+			return
+		}
+	} else {
+		next, err := h.repo.NextPlannedSprint(sprint)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if next != nil {
+			nextID = &next.ID
+		}
+	}
+
+	report, err := h.repo.CloseSprint(sprint, nextID, currentUserID(c))
+	if err != nil {
+		if errors.Is(err, repositories.ErrNoNextSprint) {
+			c.JSON(http.StatusConflict, gin.H{"error": "sprint has unfinished work and there is no planned sprint after it; pass next_sprint_id"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// GetScopeChanges lists the features and tasks added to or removed from a sprint
+// after it started
+func (h *SprintHandler) GetScopeChanges(c *gin.Context) {
+	sprint, ok := h.sprint(c)
+	if !ok {
+		return
+	}
+
+	changes, err := h.repo.GetScopeChanges(sprint.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, changes)
+}
+
+// sprint loads the sprint named in the URL
+func (h *SprintHandler) sprint(c *gin.Context) (*models.Sprint, bool) {
+	sprintID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid sprint ID"})
+		return nil, false
+	}
+	sprint, err := h.repo.GetSprint(sprintID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "sprint not found"})
+		return nil, false
+	}
+	return sprint, true
+}
+
+// openSprint loads the sprint named in the URL and refuses closed sprints, whose
+// scope no longer changes
+func (h *SprintHandler) openSprint(c *gin.Context) (*models.Sprint, bool) {
+	sprint, ok := h.sprint(c)
+	if !ok {
+		return nil, false
+	}
+	if sprint.State == models.SprintClosed {
+		c.JSON(http.StatusConflict, gin.H{"error": "sprint is closed"})
+		return nil, false
+	}
+	return sprint, true
+}
+
+// applyInput validates the input and copies it onto the sprint
+func (h *SprintHandler) applyInput(c *gin.Context, sprint *models.Sprint, input sprintInput) bool {
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name must not be empty"})
+		return false
+	}
+	if _, err := time.Parse(models.DateLayout, input.StartDate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "start_date must be a date in YYYY-MM-DD format"})
+		return false
+	}
+	if _, err := time.Parse(models.DateLayout, input.EndDate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "end_date must be a date in YYYY-MM-DD format"})
+		return false
+	}
+	if input.StartDate > input.EndDate {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "start_date must not be after end_date"})
+		return false
+	}
+	if existing, err := h.repo.GetSprintByName(sprint.ProjectID, input.Name); err == nil && existing.ID != sprint.ID {
+		c.JSON(http.StatusConflict, gin.H{"error": "a sprint with this name already exists"})
+		return false
+	}
+
+	sprint.Name = input.Name
+	sprint.Goal = input.Goal
+	sprint.StartDate = input.StartDate
+	sprint.EndDate = input.EndDate
+	return true
+}
@@ -1,214 +1,372 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
-	"time"
+	"sort"
+	"strconv"
 
 	"FeaturePlus/models"
+	"FeaturePlus/repositories"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-func CreateSubFeature(db *gorm.DB) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		var subFeature models.SubFeature
-		if err := c.ShouldBindJSON(&subFeature); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
-			return
-		}
-
-		// Validate required fields
-		if subFeature.Title == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Title is required"})
-			return
-		}
-		if subFeature.FeatureID == 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Feature ID is required"})
-			return
-		}
+// The /api/sub-features routes are kept for older clients. Sub-features are now
+// ordinary features with a parent, so these handlers translate between the legacy
+// SubFeature shape and the feature tree. New clients should use
+// /api/features/:id/subfeatures instead.
 
-		// Verify feature exists
-		var feature models.Feature
-		if err := db.First(&feature, subFeature.FeatureID).Error; err != nil {
-			if err == gorm.ErrRecordNotFound {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Feature not found"})
-				return
-			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify feature"})
-			return
-		}
+// ResolveLegacySubFeature rewrites the :id of a legacy sub-feature route to the ID of
+// the feature it names, so the handlers behind it can treat it as a feature ID
+func (h *FeatureHandler) ResolveLegacySubFeature(c *gin.Context) {
+	param := c.Param("id")
+	if param == "" {
+		c.Next()
+		return
+	}
+	id, err := strconv.ParseUint(param, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sub-feature ID"})
+		c.Abort()
+		return
+	}
 
-		// Set default values
-		subFeature.CreatedAt = time.Now()
-		subFeature.UpdatedAt = time.Now()
+	featureID, err := h.repo.ResolveLegacySubFeatureID(uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sub-feature not found"})
+		c.Abort()
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Abort()
+		return
+	}
 
-		// Insert into database
-		if err := db.Create(&subFeature).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create sub-feature: " + err.Error()})
-			return
+	for i := range c.Params {
+		if c.Params[i].Key == "id" {
+			c.Params[i].Value = strconv.FormatUint(uint64(featureID), 10)
 		}
+	}
+	c.Next()
+}
 
-		c.JSON(http.StatusCreated, subFeature)
+// legacySubFeatures converts child features into the legacy shape, handing out
+// legacy IDs to those that have never been served as sub-features
+func legacySubFeatures(repo *repositories.FeatureRepository, features []models.Feature) ([]models.SubFeature, error) {
+	if err := repo.AssignLegacySubFeatureIDs(features); err != nil {
+		return nil, err
 	}
+	subFeatures := make([]models.SubFeature, 0, len(features))
+	for _, feature := range features {
+		subFeatures = append(subFeatures, models.NewSubFeature(feature))
+	}
+	return subFeatures, nil
 }
 
-func UpdateSubFeature(db *gorm.DB) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		var subFeature models.SubFeature
-		if err := c.ShouldBindJSON(&subFeature); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
-			return
-		}
+// respondLegacySubFeature writes a single sub-feature in the legacy shape
+func (h *FeatureHandler) respondLegacySubFeature(c *gin.Context, status int, feature models.Feature) {
+	subFeatures, err := legacySubFeatures(h.repo, []models.Feature{feature})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	setETag(c, feature.Version)
+	c.JSON(status, subFeatures[0])
+}
 
-		// Validate required fields
-		if subFeature.ID == 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Sub-feature ID is required"})
-			return
-		}
-		if subFeature.Title == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Title is required"})
-			return
-		}
+// legacySubFeatureUpdates turns a legacy sub-feature body into feature column updates
+func legacySubFeatureUpdates(subFeature models.SubFeature) (map[string]interface{}, string) {
+	if subFeature.Title == "" {
+		return nil, "Title is required"
+	}
+	if subFeature.FeatureID == 0 {
+		return nil, "Feature ID is required"
+	}
 
-		// Verify sub-feature exists
-		var existingSubFeature models.SubFeature
-		if err := db.First(&existingSubFeature, subFeature.ID).Error; err != nil {
-			if err == gorm.ErrRecordNotFound {
-				c.JSON(http.StatusNotFound, gin.H{"error": "Sub-feature not found"})
-				return
-			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify sub-feature"})
-			return
-		}
+	// Older clients never sent a priority, so fall back to the default
+	priority := models.FeaturePriority(subFeature.Priority)
+	if priority == "" {
+		priority = models.PriorityMedium
+	}
+	if !isValidPriority(priority) {
+		return nil, "invalid status or priority"
+	}
 
-		// Update timestamp
-		subFeature.UpdatedAt = time.Now()
+	updates := map[string]interface{}{
+		"title":             subFeature.Title,
+		"description":       subFeature.Description,
+		"priority":          priority,
+		"assignee_id":       subFeature.AssigneeID,
+		"parent_feature_id": subFeature.FeatureID,
+	}
+	// Estimates are kept when left out
+	if subFeature.Estimate != nil {
+		updates["estimate"] = *subFeature.Estimate
+	}
+	if subFeature.RemainingEstimate != nil {
+		updates["remaining_estimate"] = *subFeature.RemainingEstimate
+	}
+	// Without a status the current one is kept, and the workflow's initial one is used on create
+	if subFeature.Status != "" {
+		updates["status"] = models.FeatureStatus(subFeature.Status)
+	}
+	return updates, ""
+}
 
-		// Update in database
-		if err := db.Save(&subFeature).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update sub-feature: " + err.Error()})
-			return
-		}
+// CreateLegacySubFeature creates a child feature from a legacy sub-feature body
+func (h *FeatureHandler) CreateLegacySubFeature(c *gin.Context) {
+	var subFeature models.SubFeature
+	if err := c.ShouldBindJSON(&subFeature); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
 
-		c.JSON(http.StatusOK, subFeature)
+	updates, message := legacySubFeatureUpdates(subFeature)
+	if message != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
+		return
 	}
+
+	// Verify feature exists
+	parent, err := h.repo.GetFeatureByID(int(subFeature.FeatureID))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Feature not found"})
+		return
+	}
+
+	feature := models.Feature{
+		ProjectID:       parent.ProjectID,
+		ParentFeatureID: &parent.ID,
+		Title:           subFeature.Title,
+		Description:     subFeature.Description,
+		Status:          models.FeatureStatus(subFeature.Status),
+		Priority:        updates["priority"].(models.FeaturePriority),
+		AssigneeID:      subFeature.AssigneeID,
+
+		Estimate:          subFeature.Estimate,
+		RemainingEstimate: subFeature.RemainingEstimate,
+	}
+	if !h.checkInitialStatus(c, &feature) {
+		return
+	}
+	if !h.checkEstimates(c, feature.ProjectID, feature.Estimates()) {
+		return
+	}
+	// Legacy clients cannot send custom fields, so this only fails on required ones
+	customFields, err := h.resolveCustomFields(feature.ProjectID, nil, true)
+	if err != nil {
+		respondCustomFieldError(c, err)
+		return
+	}
+	if err := h.repo.CreateFeature(&feature); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create sub-feature: " + err.Error()})
+		return
+	}
+	if err := h.saveCustomFields(&feature, customFields); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create sub-feature: " + err.Error()})
+		return
+	}
+
+	h.respondLegacySubFeature(c, http.StatusCreated, feature)
 }
 
-func GetSubFeaturesByFeature(db *gorm.DB) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		featureID := c.Query("feature_id")
-		if featureID == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Feature ID is required"})
-			return
-		}
+// UpdateLegacySubFeature replaces a sub-feature's fields from a legacy body
+func (h *FeatureHandler) UpdateLegacySubFeature(c *gin.Context) {
+	var subFeature models.SubFeature
+	if err := c.ShouldBindJSON(&subFeature); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
 
-		// Verify feature exists
-		var feature models.Feature
-		if err := db.First(&feature, featureID).Error; err != nil {
-			if err == gorm.ErrRecordNotFound {
-				c.JSON(http.StatusNotFound, gin.H{"error": "Feature not found"})
-				return
-			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify feature"})
+	// Older clients send the ID in the body rather than the URL
+	if subFeature.ID != 0 {
+		featureID, err := h.repo.ResolveLegacySubFeatureID(subFeature.ID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Sub-feature not found"})
 			return
 		}
-
-		var subFeatures []models.SubFeature
-		if err := db.Where("feature_id = ?", featureID).Order("created_at DESC").Find(&subFeatures).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sub-features: " + err.Error()})
+		subFeature.ID = featureID
+	} else {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Sub-feature ID is required"})
 			return
 		}
+		subFeature.ID = uint(id)
+	}
 
-		c.JSON(http.StatusOK, subFeatures)
+	updates, message := legacySubFeatureUpdates(subFeature)
+	if message != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
+		return
 	}
+
+	feature, ok := h.applyFeatureUpdates(c, int(subFeature.ID), updates, nil, nil, true)
+	if !ok {
+		return
+	}
+
+	h.respondLegacySubFeature(c, http.StatusOK, *feature)
 }
 
-func GetSubFeaturesByProject(db *gorm.DB) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		projectID := c.Query("project_id")
-		if projectID == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Project ID is required"})
-			return
-		}
+// PatchLegacySubFeature applies a JSON Merge Patch written against the legacy sub-feature shape
+func (h *FeatureHandler) PatchLegacySubFeature(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sub-feature ID"})
+		return
+	}
+
+	patch, err := bindMergePatch(c)
+	if err != nil {
+		respondPatchBindError(c, err)
+		return
+	}
 
-		// Verify project exists
-		var project models.Project
-		if err := db.First(&project, projectID).Error; err != nil {
-			if err == gorm.ErrRecordNotFound {
-				c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
-				return
-			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify project"})
+	// The legacy feature_id is the parent, and a sub-feature always keeps one
+	if _, ok := patch["parent_feature_id"]; ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "field \"parent_feature_id\" cannot be patched"})
+		return
+	}
+	if raw, ok := patch["feature_id"]; ok {
+		if isNull(raw) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "feature_id cannot be null"})
 			return
 		}
+		delete(patch, "feature_id")
+		patch["parent_feature_id"] = raw
+	}
 
-		type SubFeatureWithFeatureInfo struct {
-			models.SubFeature
-			FeatureTitle string `json:"feature_title"`
-		}
+	updates, err := buildUpdates(patch, featurePatchFields)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-		var subFeatures []SubFeatureWithFeatureInfo
+	feature, ok := h.applyFeatureUpdates(c, id, updates, nil, nil, true)
+	if !ok {
+		return
+	}
 
-		// Join with features to get both sub-features and their parent feature info
-		if err := db.Table("sub_features").
-			Select("sub_features.*, features.title as feature_title").
-			Joins("JOIN features ON sub_features.feature_id = features.id").
-			Where("features.project_id = ?", projectID).
-			Order("sub_features.created_at DESC").
-			Find(&subFeatures).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sub-features: " + err.Error()})
-			return
-		}
+	h.respondLegacySubFeature(c, http.StatusOK, *feature)
+}
+
+// GetLegacySubFeaturesByFeature lists a feature's children in the legacy shape, newest first
+func (h *FeatureHandler) GetLegacySubFeaturesByFeature(c *gin.Context) {
+	featureID, err := strconv.Atoi(c.Query("feature_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Feature ID is required"})
+		return
+	}
 
-		c.JSON(http.StatusOK, subFeatures)
+	// Verify feature exists
+	if _, err := h.repo.GetFeatureByID(featureID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Feature not found"})
+		return
 	}
+
+	children, err := h.repo.GetSubfeaturesByParentID(uint(featureID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sub-features: " + err.Error()})
+		return
+	}
+
+	sort.SliceStable(children, func(i, j int) bool {
+		return children[i].CreatedAt.After(children[j].CreatedAt)
+	})
+
+	subFeatures, err := legacySubFeatures(h.repo, children)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sub-features: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, subFeatures)
 }
 
-func GetSubFeatureDetail(db *gorm.DB) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		id := c.Param("id")
-		if id == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Sub-feature ID is required"})
-			return
-		}
+// GetLegacySubFeaturesByProject lists every child feature of a project along with its parent's title
+func (h *FeatureHandler) GetLegacySubFeaturesByProject(c *gin.Context) {
+	projectID, err := strconv.Atoi(c.Query("project_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Project ID is required"})
+		return
+	}
 
-		// Get the sub-feature
-		var subFeature models.SubFeature
-		if err := db.First(&subFeature, id).Error; err != nil {
-			if err == gorm.ErrRecordNotFound {
-				c.JSON(http.StatusNotFound, gin.H{"error": "Sub-feature not found"})
-				return
-			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sub-feature"})
-			return
-		}
+	features, err := h.repo.GetFeaturesByProject(projectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sub-features: " + err.Error()})
+		return
+	}
 
-		// Get the parent feature
-		var parentFeature models.Feature
-		if err := db.First(&parentFeature, subFeature.FeatureID).Error; err != nil {
-			if err == gorm.ErrRecordNotFound {
-				c.JSON(http.StatusNotFound, gin.H{"error": "Parent feature not found"})
-				return
-			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch parent feature"})
-			return
-		}
+	type SubFeatureWithFeatureInfo struct {
+		models.SubFeature
+		FeatureTitle string `json:"feature_title"`
+	}
 
-		// Get related tasks
-		var tasks []models.Task
-		if err := db.Where("sub_feature_id = ?", id).Find(&tasks).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tasks"})
-			return
+	children := []models.Feature{}
+	for _, feature := range features {
+		if feature.ParentFeature != nil {
+			children = append(children, feature)
 		}
+	}
+	converted, err := legacySubFeatures(h.repo, children)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sub-features: " + err.Error()})
+		return
+	}
 
-		// Construct response
-		response := gin.H{
-			"sub_feature":    subFeature,
-			"parent_feature": gin.H{"id": parentFeature.ID, "title": parentFeature.Title},
-			"tasks":          tasks,
-		}
+	subFeatures := make([]SubFeatureWithFeatureInfo, 0, len(children))
+	for i, child := range children {
+		subFeatures = append(subFeatures, SubFeatureWithFeatureInfo{
+			SubFeature:   converted[i],
+			FeatureTitle: child.ParentFeature.Title,
+		})
+	}
+
+	sort.SliceStable(subFeatures, func(i, j int) bool {
+		return subFeatures[i].CreatedAt.After(subFeatures[j].CreatedAt)
+	})
+
+	c.JSON(http.StatusOK, subFeatures)
+}
 
-		c.JSON(http.StatusOK, response)
+// GetLegacySubFeatureDetail returns a sub-feature with its parent and tasks
+func (h *FeatureHandler) GetLegacySubFeatureDetail(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Sub-feature ID is required"})
+		return
 	}
+
+	feature, err := h.repo.GetFeatureByID(id)
+	if err != nil || feature.ParentFeature == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sub-feature not found"})
+		return
+	}
+
+	// Get related tasks
+	tasks, err := h.taskRepo.GetByFeatureID(feature.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tasks"})
+		return
+	}
+
+	subFeatures, err := legacySubFeatures(h.repo, []models.Feature{*feature})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Construct response
+	response := gin.H{
+		"sub_feature":    subFeatures[0],
+		"parent_feature": gin.H{"id": feature.ParentFeature.ID, "title": feature.ParentFeature.Title},
+		"tasks":          tasks,
+	}
+
+	// The tasks change without the sub-feature's version, so the ETag covers the whole body
+	respondWithETag(c, feature.Version, response)
 }
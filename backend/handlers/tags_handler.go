@@ -1,28 +1,45 @@
 package handlers
 
 import (
+	"FeaturePlus/models"
 	"FeaturePlus/repositories"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type TagHandler struct {
 	tagRepo     *repositories.TagRepository
 	featureRepo *repositories.FeatureRepository
+	projectRepo *repositories.ProjectRepository
+	taskRepo    repositories.TaskRepository
 }
 
 func NewTagHandler(
 	tagRepo *repositories.TagRepository,
 	featureRepo *repositories.FeatureRepository,
+	projectRepo *repositories.ProjectRepository,
+	taskRepo repositories.TaskRepository,
 ) *TagHandler {
 	return &TagHandler{
 		tagRepo:     tagRepo,
 		featureRepo: featureRepo,
+		projectRepo: projectRepo,
+		taskRepo:    taskRepo,
 	}
 }
 
+// tagInput is the writable part of a tag
+type tagInput struct {
+	Name        string `json:"name" binding:"required"`
+	Colour      string `json:"colour"`
+	Description string `json:"description"`
+	Archived    bool   `json:"archived"`
+}
+
 // GetFeatureTags godoc
 // @Summary Get tags for a feature
 // @Description Get all tags associated with a feature
@@ -110,13 +127,423 @@ func (h *TagHandler) GetFeaturesByTag(c *gin.Context) {
 // @Failure 500 {object} ErrorResponse
 // @Router /features/{id}/tags [put]
 func (h *TagHandler) UpdateFeatureTags(c *gin.Context) {
-	featureIDStr := c.Param("id")
-	featureID, err := strconv.ParseUint(featureIDStr, 10, 32)
+	featureID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid feature ID"})
 		return
 	}
 
+	h.updateTags(c, "feature", func(userID uint, tags string) error {
+		return h.tagRepo.UpdateFeatureTags(uint(featureID), userID, tags)
+	})
+}
+
+// GetSubFeatureTags godoc
+// @Summary Get tags for a sub-feature
+// @Description Get all tags associated with a sub-feature
+// @Tags tags
+// @Produce json
+// @Param id path int true "Sub-feature ID"
+// @Success 200 {array} models.FeatureTag
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /sub-features/{id}/tags [get]
+func (h *TagHandler) GetSubFeatureTags(c *gin.Context) {
+	if !h.subFeature(c) {
+		return
+	}
+	h.GetFeatureTags(c)
+}
+
+// UpdateSubFeatureTags godoc
+// @Summary Update tags for a sub-feature
+// @Description Delete existing tags and add new tags to a sub-feature
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Param id path int true "Sub-feature ID"
+// @Param tags body string true "Tags string (comma/space/semicolon separated)"
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /sub-features/{id}/tags [put]
+func (h *TagHandler) UpdateSubFeatureTags(c *gin.Context) {
+	if !h.subFeature(c) {
+		return
+	}
+	h.UpdateFeatureTags(c)
+}
+
+// GetTaskTags godoc
+// @Summary Get tags for a task
+// @Description Get all tags associated with a task
+// @Tags tags
+// @Produce json
+// @Param id path int true "Task ID"
+// @Success 200 {array} models.TaskTag
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /tasks/{id}/tags [get]
+func (h *TagHandler) GetTaskTags(c *gin.Context) {
+	taskID, ok := h.task(c)
+	if !ok {
+		return
+	}
+
+	tags, err := h.tagRepo.GetTagsByTaskID(taskID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get tags"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tags)
+}
+
+// UpdateTaskTags godoc
+// @Summary Update tags for a task
+// @Description Delete existing tags and add new tags to a task, drawn from its project's tags
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Param id path int true "Task ID"
+// @Param tags body string true "Tags string (comma/space/semicolon separated)"
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /tasks/{id}/tags [put]
+func (h *TagHandler) UpdateTaskTags(c *gin.Context) {
+	taskID, ok := h.task(c)
+	if !ok {
+		return
+	}
+
+	h.updateTags(c, "task", func(userID uint, tags string) error {
+		return h.tagRepo.UpdateTaskTags(taskID, userID, tags)
+	})
+}
+
+// GetProjectTags godoc
+// @Summary Get tags for a project
+// @Description Get the tags the project itself carries
+// @Tags tags
+// @Produce json
+// @Param id path int true "Project ID"
+// @Success 200 {array} models.ProjectTag
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /projects/{id}/tags [get]
+func (h *TagHandler) GetProjectTags(c *gin.Context) {
+	projectID, ok := h.project(c)
+	if !ok {
+		return
+	}
+
+	tags, err := h.tagRepo.GetTagsByProjectID(uint(projectID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get tags"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tags)
+}
+
+// UpdateProjectTags godoc
+// @Summary Update tags for a project
+// @Description Delete existing tags and add new tags to the project itself
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Param id path int true "Project ID"
+// @Param tags body string true "Tags string (comma/space/semicolon separated)"
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /projects/{id}/tags [put]
+func (h *TagHandler) UpdateProjectTags(c *gin.Context) {
+	projectID, ok := h.project(c)
+	if !ok {
+		return
+	}
+
+	h.updateTags(c, "project", func(userID uint, tags string) error {
+		return h.tagRepo.UpdateProjectTags(uint(projectID), userID, tags)
+	})
+}
+
+// GetTagged godoc
+// @Summary Get everything with a tag
+// @Description Get the features, sub-features, tasks and projects carrying a tag, in any project
+// @Tags tags
+// @Produce json
+// @Param tag_name path string true "Tag name"
+// @Success 200 {object} map[string]interface{}
+// @Failure 500 {object} ErrorResponse
+// @Router /tags/{tag_name} [get]
+func (h *TagHandler) GetTagged(c *gin.Context) {
+	tagName := c.Param("tag_name")
+
+	features, err := h.tagRepo.GetFeaturesByTagName(tagName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get features by tag"})
+		return
+	}
+	tasks, err := h.tagRepo.GetTasksByTagName(tagName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get tasks by tag"})
+		return
+	}
+	projects, err := h.tagRepo.GetProjectsByTagName(tagName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get projects by tag"})
+		return
+	}
+
+	topLevel, children := splitSubFeatures(features)
+	subFeatures, err := legacySubFeatures(h.featureRepo, children)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get features by tag"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"tag_name":     tagName,
+		"features":     topLevel,
+		"sub_features": subFeatures,
+		"tasks":        tasks,
+		"projects":     projects,
+	})
+}
+
+// GetSubFeaturesByTag godoc
+// @Summary Get sub-features by tag
+// @Description Get all sub-features associated with a tag
+// @Tags tags
+// @Produce json
+// @Param tag_name path string true "Tag name"
+// @Success 200 {array} models.SubFeature
+// @Failure 500 {object} ErrorResponse
+// @Router /tags/{tag_name}/sub-features [get]
+func (h *TagHandler) GetSubFeaturesByTag(c *gin.Context) {
+	features, err := h.tagRepo.GetFeaturesByTagName(c.Param("tag_name"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get sub-features by tag"})
+		return
+	}
+
+	_, children := splitSubFeatures(features)
+	subFeatures, err := legacySubFeatures(h.featureRepo, children)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get sub-features by tag"})
+		return
+	}
+	c.JSON(http.StatusOK, subFeatures)
+}
+
+// GetTasksByTag godoc
+// @Summary Get tasks by tag
+// @Description Get all tasks associated with a tag
+// @Tags tags
+// @Produce json
+// @Param tag_name path string true "Tag name"
+// @Success 200 {array} models.Task
+// @Failure 500 {object} ErrorResponse
+// @Router /tags/{tag_name}/tasks [get]
+func (h *TagHandler) GetTasksByTag(c *gin.Context) {
+	tasks, err := h.tagRepo.GetTasksByTagName(c.Param("tag_name"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get tasks by tag"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tasks)
+}
+
+// GetProjectsByTag godoc
+// @Summary Get projects by tag
+// @Description Get all projects that carry a tag themselves
+// @Tags tags
+// @Produce json
+// @Param tag_name path string true "Tag name"
+// @Success 200 {array} models.Project
+// @Failure 500 {object} ErrorResponse
+// @Router /tags/{tag_name}/projects [get]
+func (h *TagHandler) GetProjectsByTag(c *gin.Context) {
+	projects, err := h.tagRepo.GetProjectsByTagName(c.Param("tag_name"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get projects by tag"})
+		return
+	}
+
+	c.JSON(http.StatusOK, projects)
+}
+
+// GetTagCatalogue godoc
+// @Summary Get a project's tags
+// @Description List the tags a project's features, tasks and the project itself draw from, with usage counts; archived tags only with include_archived=true
+// @Tags tags
+// @Produce json
+// @Param id path int true "Project ID"
+// @Success 200 {array} models.Tag
+// @Failure 404 {object} ErrorResponse
+// @Router /projects/{id}/tag-catalogue [get]
+func (h *TagHandler) GetTagCatalogue(c *gin.Context) {
+	projectID, ok := h.project(c)
+	if !ok {
+		return
+	}
+
+	tags, err := h.tagRepo.GetProjectTags(projectID, c.Query("include_archived") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tags)
+}
+
+// CreateTag godoc
+// @Summary Create a tag
+// @Description Add a tag with a colour and description to a project
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Param id path int true "Project ID"
+// @Success 201 {object} models.Tag
+// @Failure 400 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /projects/{id}/tag-catalogue [post]
+func (h *TagHandler) CreateTag(c *gin.Context) {
+	projectID, ok := h.project(c)
+	if !ok {
+		return
+	}
+
+	var input tagInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tag := models.Tag{ProjectID: projectID}
+	if !h.applyTagInput(c, &tag, input) {
+		return
+	}
+
+	if err := h.tagRepo.CreateTag(&tag); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, tag)
+}
+
+// UpdateTag godoc
+// @Summary Update a tag
+// @Description Rename, recolour, describe or archive a tag; a new name carries over to every feature with the tag
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Param id path int true "Project ID"
+// @Param tag_id path int true "Tag ID"
+// @Success 200 {object} models.Tag
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /projects/{id}/tag-catalogue/{tag_id} [put]
+func (h *TagHandler) UpdateTag(c *gin.Context) {
+	tag, ok := h.tag(c)
+	if !ok {
+		return
+	}
+
+	var input tagInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	previousName := tag.Name
+	if !h.applyTagInput(c, tag, input) {
+		return
+	}
+
+	if err := h.tagRepo.UpdateTag(tag, previousName); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.respondTag(c, tag)
+}
+
+// MergeTag godoc
+// @Summary Merge a tag into another
+// @Description Move every feature tagged with the tag over to into_id and delete the tag
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Param id path int true "Project ID"
+// @Param tag_id path int true "Tag ID"
+// @Success 200 {object} models.Tag
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /projects/{id}/tag-catalogue/{tag_id}/merge [post]
+func (h *TagHandler) MergeTag(c *gin.Context) {
+	tag, ok := h.tag(c)
+	if !ok {
+		return
+	}
+
+	var requestBody struct {
+		IntoID uint `json:"into_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if requestBody.IntoID == tag.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a tag cannot be merged into itself"})
+		return
+	}
+
+	target, err := h.tagRepo.GetTag(tag.ProjectID, int(requestBody.IntoID))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "into_id must be a tag in the same project"})
+		return
+	}
+
+	if err := h.tagRepo.MergeTags(tag, target); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.respondTag(c, target)
+}
+
+// DeleteTag godoc
+// @Summary Delete a tag
+// @Description Remove a tag from every feature and delete it
+// @Tags tags
+// @Param id path int true "Project ID"
+// @Param tag_id path int true "Tag ID"
+// @Success 204
+// @Failure 404 {object} ErrorResponse
+// @Router /projects/{id}/tag-catalogue/{tag_id} [delete]
+func (h *TagHandler) DeleteTag(c *gin.Context) {
+	tag, ok := h.tag(c)
+	if !ok {
+		return
+	}
+
+	if err := h.tagRepo.DeleteTag(tag); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// updateTags binds a tags input and applies it with update on behalf of the current
+// user, writing the response
+func (h *TagHandler) updateTags(c *gin.Context, kind string, update func(userID uint, tags string) error) {
 	var requestBody struct {
 		Tags string `json:"tags"`
 	}
@@ -133,12 +560,129 @@ func (h *TagHandler) UpdateFeatureTags(c *gin.Context) {
 		return
 	}
 
-	currentUserID := userID.(uint)
-
-	if err := h.tagRepo.UpdateFeatureTags(uint(featureID), currentUserID, requestBody.Tags); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update tags"})
+	if err := update(userID.(uint), requestBody.Tags); err != nil {
+		var tagErr *repositories.TagError
+		switch {
+		case errors.As(err, &tagErr):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": kind + " not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update tags"})
+		}
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Tags updated successfully"})
 }
+
+// subFeature checks the feature named in the URL exists and is a sub-feature. It
+// writes the error response itself and returns false on failure.
+func (h *TagHandler) subFeature(c *gin.Context) bool {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Sub-feature ID is required"})
+		return false
+	}
+	feature, err := h.featureRepo.GetFeatureByID(id)
+	if err != nil || feature.ParentFeatureID == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sub-feature not found"})
+		return false
+	}
+	return true
+}
+
+// task reads the task named in the URL and checks it exists. It writes the error
+// response itself and returns false on failure.
+func (h *TagHandler) task(c *gin.Context) (uint, bool) {
+	taskID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return 0, false
+	}
+	if _, err := h.taskRepo.GetByID(uint(taskID)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "task not found"})
+		return 0, false
+	}
+	return uint(taskID), true
+}
+
+// splitSubFeatures separates top-level features from sub-features
+func splitSubFeatures(features []models.Feature) ([]models.Feature, []models.Feature) {
+	topLevel := []models.Feature{}
+	children := []models.Feature{}
+	for _, feature := range features {
+		if feature.ParentFeatureID == nil {
+			topLevel = append(topLevel, feature)
+		} else {
+			children = append(children, feature)
+		}
+	}
+	return topLevel, children
+}
+
+// project reads the project named in the URL and checks it exists. It writes the
+// error response itself and returns false on failure.
+func (h *TagHandler) project(c *gin.Context) (int, bool) {
+	projectID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		return 0, false
+	}
+	if _, err := h.projectRepo.GetProjectByID(projectID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return 0, false
+	}
+	return projectID, true
+}
+
+// tag loads the project's tag named in the URL. It writes the error response itself
+// and returns false on failure.
+func (h *TagHandler) tag(c *gin.Context) (*models.Tag, bool) {
+	projectID, ok := h.project(c)
+	if !ok {
+		return nil, false
+	}
+	tagID, err := strconv.Atoi(c.Param("tag_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tag ID"})
+		return nil, false
+	}
+	tag, err := h.tagRepo.GetTag(projectID, tagID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "tag not found"})
+		return nil, false
+	}
+	return tag, true
+}
+
+// respondTag writes a tag with its usage counts
+func (h *TagHandler) respondTag(c *gin.Context, tag *models.Tag) {
+	if err := h.tagRepo.CountUsage(tag); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, tag)
+}
+
+// applyTagInput validates the input and copies it onto a tag. Names must stay unique
+// within the project; tags that should share a name are merged instead.
+func (h *TagHandler) applyTagInput(c *gin.Context, tag *models.Tag, input tagInput) bool {
+	name, ok := repositories.NormalizeTagName(input.Name)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name must be a single tag of at most 50 characters"})
+		return false
+	}
+	if !models.IsValidTagColour(input.Colour) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "colour must be a #rrggbb colour"})
+		return false
+	}
+
+	if existing, err := h.tagRepo.GetTagByName(tag.ProjectID, name); err == nil && existing.ID != tag.ID {
+		c.JSON(http.StatusConflict, gin.H{"error": "a tag with this name already exists; merge the tags instead", "tag_id": existing.ID})
+		return false
+	}
+
+	tag.Name, tag.Colour, tag.Description, tag.Archived = name, input.Colour, input.Description, input.Archived
+	return true
+}
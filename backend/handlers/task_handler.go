@@ -3,6 +3,7 @@ package handlers
 import (
 	"FeaturePlus/models"
 	"FeaturePlus/repositories"
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -10,11 +11,12 @@ import (
 )
 
 type TaskHandler struct {
-	taskRepo repositories.TaskRepository
+	taskRepo    repositories.TaskRepository
+	featureRepo *repositories.FeatureRepository
 }
 
-func NewTaskHandler(taskRepo repositories.TaskRepository) *TaskHandler {
-	return &TaskHandler{taskRepo}
+func NewTaskHandler(taskRepo repositories.TaskRepository, featureRepo *repositories.FeatureRepository) *TaskHandler {
+	return &TaskHandler{taskRepo, featureRepo}
 }
 
 // CreateTask creates a standalone task not tied to a specific feature
@@ -31,36 +33,152 @@ func (h *TaskHandler) CreateTask(c *gin.Context) {
 		return
 	}
 	task.CreatedByUser = userID.(uint)
+	// Sprints are joined through the sprint endpoints and overdue is set by the job
+	task.SprintID = nil
+	task.Overdue, task.OverdueSince = false, nil
+
+	if !h.checkTask(c, &task) {
+		return
+	}
 
 	if err := h.taskRepo.Create(&task); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create task"})
 		return
 	}
 
+	setETag(c, task.Version)
 	c.JSON(http.StatusCreated, task)
 }
 
 // UpdateTask updates a standalone task by JSON input
 func (h *TaskHandler) UpdateTask(c *gin.Context) {
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
 	var task models.Task
 	if err := c.ShouldBindJSON(&task); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	existing, ok := h.loadTaskForWrite(c, uint(taskID))
+	if !ok {
+		return
+	}
+
+	task.ID = existing.ID
+	task.CreatedAt = existing.CreatedAt
+	task.CreatedByUser = existing.CreatedByUser
+	task.Version = existing.Version
+	task.SprintID = existing.SprintID
+	task.Overdue, task.OverdueSince = existing.Overdue, existing.OverdueSince
+
+	if !h.checkTask(c, &task) {
+		return
+	}
+
 	if err := h.taskRepo.Update(&task); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update task"})
+		respondTaskWriteError(c, err, "Could not update task")
+		return
+	}
+
+	setETag(c, task.Version)
+	c.JSON(http.StatusOK, task)
+}
+
+// taskPatchFields lists the task members that can be changed through PATCH
+var taskPatchFields = map[string]patchField{
+	"task_type":   {column: "task_type", decode: stringPatch(true)},
+	"task_name":   {column: "task_name", decode: stringPatch(true)},
+	"description": {column: "description", nullable: true, nullValue: "", decode: stringPatch(false)},
+	"feature_id":  {column: "feature_id", nullable: true, nullValue: uint(0), decode: uintPatch(true)},
+	"start_date":  {column: "start_date", nullable: true, nullValue: nil, decode: datePatch},
+	"due_date":    {column: "due_date", nullable: true, nullValue: nil, decode: datePatch},
+
+	"estimate":           {column: "estimate", nullable: true, nullValue: nil, decode: estimatePatch},
+	"remaining_estimate": {column: "remaining_estimate", nullable: true, nullValue: nil, decode: estimatePatch},
+}
+
+// PatchTask partially updates a task using JSON Merge Patch
+func (h *TaskHandler) PatchTask(c *gin.Context) {
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	patch, err := bindMergePatch(c)
+	if err != nil {
+		respondPatchBindError(c, err)
+		return
+	}
+
+	updates, err := buildUpdates(patch, taskPatchFields)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	existing, ok := h.loadTaskForWrite(c, uint(taskID))
+	if !ok {
+		return
+	}
+
+	_, featureChanged := updates["feature_id"]
+	_, startChanged := updates["start_date"]
+	_, dueChanged := updates["due_date"]
+	_, estimateChanged := updates["estimate"]
+	_, remainingChanged := updates["remaining_estimate"]
+	if featureChanged || startChanged || dueChanged || estimateChanged || remainingChanged {
+		patched := *existing
+		if featureID, ok := updates["feature_id"].(uint); ok {
+			patched.FeatureID = featureID
+		}
+		if startChanged {
+			patched.StartDate = optionalDate(updates["start_date"])
+		}
+		if dueChanged {
+			patched.DueDate = optionalDate(updates["due_date"])
+		}
+		if estimateChanged {
+			patched.Estimate = optionalEstimate(updates["estimate"])
+		}
+		if remainingChanged {
+			patched.RemainingEstimate = optionalEstimate(updates["remaining_estimate"])
+		}
+		if !h.checkTask(c, &patched) {
+			return
+		}
+	}
+
+	if err := h.taskRepo.Patch(existing.ID, existing.Version, updates); err != nil {
+		respondTaskWriteError(c, err, "Could not update task")
+		return
+	}
+
+	task, err := h.taskRepo.GetByID(uint(taskID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch task"})
 		return
 	}
 
+	setETag(c, task.Version)
 	c.JSON(http.StatusOK, task)
 }
 
 // DeleteTask deletes a standalone task by ID
 func (h *TaskHandler) DeleteTask(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
-	if err := h.taskRepo.Delete(uint(id)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not delete task"})
+	existing, ok := h.loadTaskForWrite(c, uint(id))
+	if !ok {
+		return
+	}
+
+	if err := h.taskRepo.Delete(existing.ID, existing.Version); err != nil {
+		respondTaskWriteError(c, err, "Could not delete task")
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Task deleted"})
@@ -74,7 +192,9 @@ func (h *TaskHandler) GetTask(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
 		return
 	}
-	c.JSON(http.StatusOK, task)
+
+	// The overdue flag changes without the task's version, so the ETag covers the whole body
+	respondWithETag(c, task.Version, task)
 }
 
 // GetTasksByFeature lists all tasks under a specific feature
@@ -111,12 +231,20 @@ func (h *TaskHandler) CreateTaskForFeature(c *gin.Context) {
 
 	task.FeatureID = uint(featureID)
 	task.CreatedByUser = userID.(uint)
+	// Sprints are joined through the sprint endpoints and overdue is set by the job
+	task.SprintID = nil
+	task.Overdue, task.OverdueSince = false, nil
+
+	if !h.checkTask(c, &task) {
+		return
+	}
 
 	if err := h.taskRepo.Create(&task); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create task"})
 		return
 	}
 
+	setETag(c, task.Version)
 	c.JSON(http.StatusCreated, task)
 }
 
@@ -141,14 +269,29 @@ func (h *TaskHandler) UpdateTaskForFeature(c *gin.Context) {
 		return
 	}
 
-	task.ID = uint(taskID)
+	existing, ok := h.loadTaskForWrite(c, uint(taskID))
+	if !ok {
+		return
+	}
+
+	task.ID = existing.ID
 	task.FeatureID = uint(featureID)
+	task.CreatedAt = existing.CreatedAt
+	task.CreatedByUser = existing.CreatedByUser
+	task.Version = existing.Version
+	task.SprintID = existing.SprintID
+	task.Overdue, task.OverdueSince = existing.Overdue, existing.OverdueSince
+
+	if !h.checkTask(c, &task) {
+		return
+	}
 
 	if err := h.taskRepo.Update(&task); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update task"})
+		respondTaskWriteError(c, err, "Could not update task")
 		return
 	}
 
+	setETag(c, task.Version)
 	c.JSON(http.StatusOK, task)
 }
 
@@ -160,101 +303,51 @@ func (h *TaskHandler) DeleteTaskForFeature(c *gin.Context) {
 		return
 	}
 
-	if err := h.taskRepo.Delete(uint(taskID)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not delete task"})
+	existing, ok := h.loadTaskForWrite(c, uint(taskID))
+	if !ok {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Task deleted"})
-}
-
-// GetTasksBySubFeature lists all tasks under a specific sub-feature
-func (h *TaskHandler) GetTasksBySubFeature(c *gin.Context) {
-	subFeatureID, _ := strconv.Atoi(c.Param("id"))
-	tasks, err := h.taskRepo.GetBySubFeatureID(uint(subFeatureID))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch tasks"})
+	if err := h.taskRepo.Delete(existing.ID, existing.Version); err != nil {
+		respondTaskWriteError(c, err, "Could not delete task")
 		return
 	}
-	c.JSON(http.StatusOK, tasks)
-}
 
-// CreateTaskForSubFeature creates a task and links it to a sub-feature
-func (h *TaskHandler) CreateTaskForSubFeature(c *gin.Context) {
-	subFeatureID, err := strconv.Atoi(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sub-feature ID"})
-		return
-	}
-
-	// Get user ID from context first
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
+	c.JSON(http.StatusOK, gin.H{"message": "Task deleted"})
+}
 
-	var task models.Task
-	if err := c.ShouldBindJSON(&task); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+// checkTask validates the task's dates and estimates against the feature it belongs
+// to. It writes the error response itself and returns false on failure.
+func (h *TaskHandler) checkTask(c *gin.Context, task *models.Task) bool {
+	if err := h.featureRepo.CheckTaskDates(task.FeatureID, task.Window()); err != nil {
+		respondCheckError(c, err)
+		return false
 	}
-
-	task.SubFeatureID = uint(subFeatureID)
-	task.CreatedByUser = userID.(uint)
-
-	if err := h.taskRepo.Create(&task); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create task"})
-		return
+	if err := h.featureRepo.CheckTaskEstimates(task.FeatureID, task.Estimates()); err != nil {
+		respondCheckError(c, err)
+		return false
 	}
-
-	c.JSON(http.StatusCreated, task)
+	return true
 }
 
-// UpdateTaskForSubFeature updates a task that belongs to a sub-feature
-func (h *TaskHandler) UpdateTaskForSubFeature(c *gin.Context) {
-	taskID, err := strconv.Atoi(c.Param("task_id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
-		return
-	}
-
-	// Get sub-feature ID
-	subFeatureID, err := strconv.Atoi(c.Param("id"))
+// loadTaskForWrite fetches a task and enforces the If-Match precondition on it
+func (h *TaskHandler) loadTaskForWrite(c *gin.Context, taskID uint) (*models.Task, bool) {
+	task, err := h.taskRepo.GetByID(taskID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sub-feature ID"})
-		return
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return nil, false
 	}
-
-	var task models.Task
-	if err := c.ShouldBindJSON(&task); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+	if !checkIfMatch(c, task.Version) {
+		return nil, false
 	}
-
-	task.ID = uint(taskID)
-	task.SubFeatureID = uint(subFeatureID)
-
-	if err := h.taskRepo.Update(&task); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update task"})
-		return
-	}
-
-	c.JSON(http.StatusOK, task)
+	return task, true
 }
 
-// DeleteTaskForSubFeature deletes a task under a sub-feature by ID
-func (h *TaskHandler) DeleteTaskForSubFeature(c *gin.Context) {
-	taskID, err := strconv.Atoi(c.Param("task_id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
-		return
-	}
-
-	if err := h.taskRepo.Delete(uint(taskID)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not delete task"})
+// respondTaskWriteError maps a failed task write to a 412 on version conflicts or a 500 otherwise
+func respondTaskWriteError(c *gin.Context, err error, message string) {
+	if errors.Is(err, repositories.ErrVersionConflict) {
+		respondVersionConflict(c)
 		return
 	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "Task deleted"})
+	c.JSON(http.StatusInternalServerError, gin.H{"error": message})
 }
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"FeaturePlus/models"
+	"FeaturePlus/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+// statusKeyPattern keeps status keys usable in URLs and filters
+var statusKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+
+type WorkflowHandler struct {
+	repo        *repositories.WorkflowRepository
+	projectRepo *repositories.ProjectRepository
+}
+
+func NewWorkflowHandler(repo *repositories.WorkflowRepository, projectRepo *repositories.ProjectRepository) *WorkflowHandler {
+	return &WorkflowHandler{repo: repo, projectRepo: projectRepo}
+}
+
+// GetWorkflow returns the project's workflow, or the default one if it has none
+func (h *WorkflowHandler) GetWorkflow(c *gin.Context) {
+	projectID, ok := h.projectID(c)
+	if !ok {
+		return
+	}
+
+	workflow, err := h.repo.GetWorkflow(projectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, workflow)
+}
+
+// UpdateWorkflow replaces the project's statuses and transitions. Statuses are
+// ordered as given; removing a status that features still use is rejected.
+func (h *WorkflowHandler) UpdateWorkflow(c *gin.Context) {
+	projectID, ok := h.projectID(c)
+	if !ok {
+		return
+	}
+
+	var workflow models.Workflow
+	if err := c.ShouldBindJSON(&workflow); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	workflow.ProjectID = projectID
+	if workflow.Transitions == nil {
+		workflow.Transitions = []models.WorkflowTransition{}
+	}
+
+	if err := validateWorkflow(&workflow); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.repo.ReplaceWorkflow(&workflow); err != nil {
+		respondWorkflowError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, workflow)
+}
+
+// ResetWorkflow drops the project's workflow so the default applies again
+func (h *WorkflowHandler) ResetWorkflow(c *gin.Context) {
+	projectID, ok := h.projectID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.repo.ResetWorkflow(projectID); err != nil {
+		respondWorkflowError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// projectID reads the project from the URL and checks that it exists
+func (h *WorkflowHandler) projectID(c *gin.Context) (int, bool) {
+	projectID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		return 0, false
+	}
+	if _, err := h.projectRepo.GetProjectByID(projectID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return 0, false
+	}
+	return projectID, true
+}
+
+func respondWorkflowError(c *gin.Context, err error) {
+	var inUse *repositories.StatusInUseError
+	if errors.As(err, &inUse) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "statuses": inUse.Statuses})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+// validateWorkflow checks a workflow definition and numbers its statuses in order
+func validateWorkflow(workflow *models.Workflow) error {
+	if len(workflow.Statuses) == 0 {
+		return errors.New("a workflow needs at least one status")
+	}
+
+	hasDone := false
+	seen := map[models.FeatureStatus]bool{}
+	for i := range workflow.Statuses {
+		status := &workflow.Statuses[i]
+		if !statusKeyPattern.MatchString(string(status.Key)) {
+			return fmt.Errorf("status key %q must be lowercase letters, digits and underscores", status.Key)
+		}
+		if seen[status.Key] {
+			return fmt.Errorf("status %q is listed twice", status.Key)
+		}
+		seen[status.Key] = true
+
+		if status.Name == "" {
+			status.Name = string(status.Key)
+		}
+		switch status.Category {
+		case models.CategoryNotStarted, models.CategoryActive:
+		case models.CategoryDone:
+			hasDone = true
+		default:
+			return fmt.Errorf("status %q: category must be one of not_started, active, done", status.Key)
+		}
+		if status.WIPLimit < 0 {
+			return fmt.Errorf("status %q: wip_limit must not be negative", status.Key)
+		}
+		switch status.WIPPolicy {
+		case "":
+			status.WIPPolicy = models.WIPWarn
+		case models.WIPWarn, models.WIPBlock:
+		default:
+			return fmt.Errorf("status %q: wip_policy must be warn or block", status.Key)
+		}
+		status.Position = i
+	}
+	if !hasDone {
+		return errors.New("a workflow needs at least one status in the done category")
+	}
+
+	type move struct{ from, to models.FeatureStatus }
+	seenMoves := map[move]bool{}
+	for _, transition := range workflow.Transitions {
+		if !seen[transition.From] || !seen[transition.To] {
+			return fmt.Errorf("transition %s -> %s uses an unknown status", transition.From, transition.To)
+		}
+		if transition.From == transition.To {
+			return fmt.Errorf("transition %s -> %s does not change the status", transition.From, transition.To)
+		}
+		if seenMoves[move{transition.From, transition.To}] {
+			return fmt.Errorf("transition %s -> %s is listed twice", transition.From, transition.To)
+		}
+		seenMoves[move{transition.From, transition.To}] = true
+	}
+	return nil
+}
+
+// validateInitialStatus checks that a new feature's status exists in the workflow
+func validateInitialStatus(workflow *models.Workflow, status models.FeatureStatus) error {
+	if _, ok := workflow.Status(status); !ok {
+		return fmt.Errorf("status %q is not part of the project's workflow", status)
+	}
+	return nil
+}
+
+// validateStatusChange checks a status change against the workflow's transitions
+// and their guards. assigneeID is the assignee the feature will have afterwards.
+func validateStatusChange(workflow *models.Workflow, from models.FeatureStatus, to models.FeatureStatus, assigneeID uint) error {
+	if from == to {
+		return nil
+	}
+	if err := validateInitialStatus(workflow, to); err != nil {
+		return err
+	}
+
+	transition, ok := workflow.Transition(from, to)
+	if !ok {
+		return fmt.Errorf("moving from %q to %q is not allowed by the project's workflow", from, to)
+	}
+	if transition.RequiresAssignee && assigneeID == 0 {
+		return fmt.Errorf("moving from %q to %q requires an assignee", from, to)
+	}
+	return nil
+}
@@ -0,0 +1,440 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"FeaturePlus/models"
+	"FeaturePlus/repositories"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type WorklogHandler struct {
+	repo        *repositories.WorklogRepository
+	featureRepo *repositories.FeatureRepository
+	taskRepo    repositories.TaskRepository
+	projectRepo *repositories.ProjectRepository
+	userRepo    *repositories.UserRepository
+}
+
+func NewWorklogHandler(repo *repositories.WorklogRepository, featureRepo *repositories.FeatureRepository, taskRepo repositories.TaskRepository, projectRepo *repositories.ProjectRepository, userRepo *repositories.UserRepository) *WorklogHandler {
+	return &WorklogHandler{repo: repo, featureRepo: featureRepo, taskRepo: taskRepo, projectRepo: projectRepo, userRepo: userRepo}
+}
+
+// worklogInput is the body for manual entries, timers and edits. Timers ignore the
+// date and minutes, and edits cannot change the feature or task.
+type worklogInput struct {
+	FeatureID *uint  `json:"feature_id"`
+	TaskID    *uint  `json:"task_id"`
+	Date      string `json:"date"`
+	Minutes   int    `json:"minutes"`
+	Note      string `json:"note"`
+}
+
+// CreateWorklog logs time spent on a feature or task. The date defaults to today.
+func (h *WorklogHandler) CreateWorklog(c *gin.Context) {
+	var input worklogInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	worklog := models.Worklog{UserID: currentUserID(c), Note: input.Note}
+	if !h.resolveTarget(c, &worklog, input) || !applyWorklogTime(c, &worklog, input) {
+		return
+	}
+
+	if err := h.repo.CreateWorklog(&worklog); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, worklog)
+}
+
+// GetTimer returns the current user's running timer
+func (h *WorklogHandler) GetTimer(c *gin.Context) {
+	worklog, err := h.repo.GetRunningTimer(currentUserID(c))
+	if err != nil {
+		respondTimerLookupError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, worklog)
+}
+
+// StartTimer starts timing work on a feature or task. A user runs one timer at a time.
+func (h *WorklogHandler) StartTimer(c *gin.Context) {
+	var input worklogInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	worklog := models.Worklog{UserID: currentUserID(c), Note: input.Note, Date: now.Format(models.DateLayout), StartedAt: &now}
+	if !h.resolveTarget(c, &worklog, input) {
+		return
+	}
+
+	if err := h.repo.StartTimer(&worklog); err != nil {
+		if errors.Is(err, repositories.ErrTimerRunning) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, worklog)
+}
+
+// StopTimer stops the current user's running timer and records the time spent
+func (h *WorklogHandler) StopTimer(c *gin.Context) {
+	worklog, err := h.repo.GetRunningTimer(currentUserID(c))
+	if err != nil {
+		respondTimerLookupError(c, err)
+		return
+	}
+
+	worklog.Stop(time.Now())
+	if err := h.repo.UpdateWorklog(worklog); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, worklog)
+}
+
+// UpdateWorklog changes the date, minutes and note of one of the user's own worklogs
+func (h *WorklogHandler) UpdateWorklog(c *gin.Context) {
+	worklog, ok := h.loadOwnWorklog(c)
+	if !ok {
+		return
+	}
+
+	var input worklogInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if worklog.Running() {
+		c.JSON(http.StatusConflict, gin.H{"error": "stop the timer before editing it"})
+		return
+	}
+
+	worklog.Note = input.Note
+	if !applyWorklogTime(c, worklog, input) {
+		return
+	}
+	if err := h.repo.UpdateWorklog(worklog); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, worklog)
+}
+
+// DeleteWorklog deletes one of the user's own worklogs, including a running timer
+func (h *WorklogHandler) DeleteWorklog(c *gin.Context) {
+	worklog, ok := h.loadOwnWorklog(c)
+	if !ok {
+		return
+	}
+
+	if err := h.repo.DeleteWorklog(worklog.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetFeatureWorklogs lists the time logged directly on a feature and on its tasks
+func (h *WorklogHandler) GetFeatureWorklogs(c *gin.Context) {
+	featureID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid feature ID"})
+		return
+	}
+	h.respondWorklogs(c, repositories.WorklogFilter{FeatureID: uint(featureID)})
+}
+
+// GetTaskWorklogs lists the time logged on a task
+func (h *WorklogHandler) GetTaskWorklogs(c *gin.Context) {
+	taskID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid task ID"})
+		return
+	}
+	h.respondWorklogs(c, repositories.WorklogFilter{TaskID: uint(taskID)})
+}
+
+// GetTimesheet returns a user's logged time between from and to, day by day
+func (h *WorklogHandler) GetTimesheet(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil || userID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+	from, to, ok := worklogRange(c)
+	if !ok {
+		return
+	}
+	if _, err := h.userRepo.GetUserByID(int(userID)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	worklogs, err := h.repo.ListWorklogs(repositories.WorklogFilter{UserID: uint(userID), From: from, To: to})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.BuildTimesheet(uint(userID), from, to, worklogs))
+}
+
+// GetProjectTime totals the time logged on a project between from and to by user,
+// feature and date
+func (h *WorklogHandler) GetProjectTime(c *gin.Context) {
+	projectID, from, to, ok := h.projectRange(c)
+	if !ok {
+		return
+	}
+
+	worklogs, err := h.repo.ListWorklogs(repositories.WorklogFilter{ProjectID: projectID, From: from, To: to})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.BuildProjectTime(projectID, from, to, worklogs))
+}
+
+// GetProjectWorklogs lists a project's worklogs between from and to, optionally for
+// one user_id. format=csv exports the finished entries as CSV for billing.
+func (h *WorklogHandler) GetProjectWorklogs(c *gin.Context) {
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or csv"})
+		return
+	}
+	projectID, from, to, ok := h.projectRange(c)
+	if !ok {
+		return
+	}
+
+	filter := repositories.WorklogFilter{ProjectID: projectID, From: from, To: to}
+	if value := c.Query("user_id"); value != "" {
+		userID, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+			return
+		}
+		filter.UserID = uint(userID)
+	}
+
+	worklogs, err := h.repo.ListWorklogs(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if format == "json" {
+		c.JSON(http.StatusOK, worklogs)
+		return
+	}
+
+	output, err := worklogCSV(worklogs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	filename := fmt.Sprintf("project-%d-worklogs-%s-to-%s.csv", projectID, from, to)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", output)
+}
+
+// worklogCSV writes one row per finished worklog. Running timers are left out.
+func worklogCSV(worklogs []models.Worklog) ([]byte, error) {
+	var out bytes.Buffer
+	writer := csv.NewWriter(&out)
+	rows := [][]string{{"date", "user_id", "username", "project_id", "feature_id", "feature", "task_id", "task", "minutes", "hours", "note"}}
+	for _, worklog := range worklogs {
+		if worklog.Running() {
+			continue
+		}
+		rows = append(rows, []string{
+			worklog.Date,
+			strconv.FormatUint(uint64(worklog.UserID), 10),
+			csvText(worklog.Username),
+			strconv.Itoa(worklog.ProjectID),
+			optionalID(worklog.FeatureID),
+			csvText(worklog.FeatureTitle),
+			optionalID(worklog.TaskID),
+			csvText(worklog.TaskName),
+			strconv.Itoa(worklog.Minutes),
+			strconv.FormatFloat(float64(worklog.Minutes)/60, 'f', 2, 64),
+			csvText(worklog.Note),
+		})
+	}
+	if err := writer.WriteAll(rows); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// csvText keeps user-entered text from being run as a formula when the export is
+// opened in a spreadsheet, by prefixing cells that start like one with a quote
+func csvText(text string) string {
+	if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
+
+func optionalID(id *uint) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.FormatUint(uint64(*id), 10)
+}
+
+// resolveTarget points the worklog at the feature or task in the input, taking the
+// project and feature from the task. Tasks without a feature are refused. It writes the error response itself and returns
+// false on failure.
+func (h *WorklogHandler) resolveTarget(c *gin.Context, worklog *models.Worklog, input worklogInput) bool {
+	if (input.FeatureID == nil) == (input.TaskID == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "exactly one of feature_id or task_id is required"})
+		return false
+	}
+
+	featureID := input.FeatureID
+	if input.TaskID != nil {
+		task, err := h.taskRepo.GetByID(*input.TaskID)
+		if err != nil || task.DeletedAt.Valid {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "task not found"})
+			return false
+		}
+		// Without a feature the time would belong to no project and drop out of
+		// project totals and exports
+		if task.FeatureID == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "task has no feature, so its time cannot be logged"})
+			return false
+		}
+		worklog.TaskID = &task.ID
+		featureID = &task.FeatureID
+	}
+
+	feature, err := h.featureRepo.GetFeatureByID(int(*featureID))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "feature not found"})
+		return false
+	}
+	worklog.FeatureID = &feature.ID
+	worklog.ProjectID = feature.ProjectID
+	return true
+}
+
+// applyWorklogTime sets the date, defaulting to today, and the minutes of a manual
+// entry. It writes the error response itself and returns false on failure.
+func applyWorklogTime(c *gin.Context, worklog *models.Worklog, input worklogInput) bool {
+	if input.Date == "" {
+		input.Date = models.Today()
+	}
+	if _, err := time.Parse(models.DateLayout, input.Date); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "date must be a date in YYYY-MM-DD format"})
+		return false
+	}
+	if input.Minutes < 1 || input.Minutes > models.MaxWorklogMinutes {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("minutes must be between 1 and %d", models.MaxWorklogMinutes)})
+		return false
+	}
+	worklog.Date = input.Date
+	worklog.Minutes = input.Minutes
+	return true
+}
+
+// loadOwnWorklog fetches the worklog in the URL, which must belong to the current user
+func (h *WorklogHandler) loadOwnWorklog(c *gin.Context) (*models.Worklog, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid worklog ID"})
+		return nil, false
+	}
+	worklog, err := h.repo.GetWorklogByID(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "worklog not found"})
+		return nil, false
+	}
+	if worklog.UserID != currentUserID(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "worklogs can only be changed by the user who logged them"})
+		return nil, false
+	}
+	return worklog, true
+}
+
+// projectRange reads the project in the URL and the from and to query parameters
+func (h *WorklogHandler) projectRange(c *gin.Context) (int, string, string, bool) {
+	projectID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		return 0, "", "", false
+	}
+	from, to, ok := worklogRange(c)
+	if !ok {
+		return 0, "", "", false
+	}
+	if _, err := h.projectRepo.GetProjectByID(projectID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return 0, "", "", false
+	}
+	return projectID, from, to, true
+}
+
+// worklogRange reads the from and to dates of a report. to defaults to today and from
+// to the first day of to's month.
+func worklogRange(c *gin.Context) (string, string, bool) {
+	to := c.DefaultQuery("to", models.Today())
+	toDate, err := time.Parse(models.DateLayout, to)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must be a date in YYYY-MM-DD format"})
+		return "", "", false
+	}
+	from := c.DefaultQuery("from", toDate.AddDate(0, 0, 1-toDate.Day()).Format(models.DateLayout))
+	if _, err := time.Parse(models.DateLayout, from); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a date in YYYY-MM-DD format"})
+		return "", "", false
+	}
+	if from > to {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must not be after to"})
+		return "", "", false
+	}
+	return from, to, true
+}
+
+func (h *WorklogHandler) respondWorklogs(c *gin.Context, filter repositories.WorklogFilter) {
+	worklogs, err := h.repo.ListWorklogs(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, worklogs)
+}
+
+func respondTimerLookupError(c *gin.Context, err error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no timer is running"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+// currentUserID is the authenticated user, or the admin when there is none
+func currentUserID(c *gin.Context) uint {
+	var userID uint = 1 // Default to admin if not available
+	if id, exists := c.Get("user_id"); exists {
+		userID = id.(uint)
+	}
+	return userID
+}
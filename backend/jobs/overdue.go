@@ -0,0 +1,33 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"FeaturePlus/models"
+	"FeaturePlus/repositories"
+)
+
+// StartOverdueJob flags features and tasks as they become overdue. It runs once right
+// away and then every interval in the background for as long as the server runs.
+func StartOverdueJob(repo *repositories.FeatureRepository, interval time.Duration) {
+	go func() {
+		checkOverdue(repo)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			checkOverdue(repo)
+		}
+	}()
+}
+
+func checkOverdue(repo *repositories.FeatureRepository) {
+	flagged, cleared, err := repo.FlagOverdue(models.Today())
+	if err != nil {
+		log.Printf("overdue check failed: %v", err)
+		return
+	}
+	if flagged > 0 || cleared > 0 {
+		log.Printf("overdue check: %d flagged, %d cleared", flagged, cleared)
+	}
+}
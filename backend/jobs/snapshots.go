@@ -0,0 +1,29 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"FeaturePlus/models"
+	"FeaturePlus/repositories"
+)
+
+// StartSnapshotJob records the day's state of every project for burndown charts. It
+// runs once right away and then every interval; later runs on the same day replace
+// that day's snapshot.
+func StartSnapshotJob(repo *repositories.SnapshotRepository, interval time.Duration) {
+	go func() {
+		takeSnapshots(repo)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			takeSnapshots(repo)
+		}
+	}()
+}
+
+func takeSnapshots(repo *repositories.SnapshotRepository) {
+	if _, err := repo.SaveSnapshots(models.Today()); err != nil {
+		log.Printf("project snapshots failed: %v", err)
+	}
+}
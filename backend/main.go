@@ -3,6 +3,7 @@ package main
 import (
 	"FeaturePlus/database"
 	"FeaturePlus/handlers"
+	"FeaturePlus/jobs"
 	"FeaturePlus/middleware"
 	"FeaturePlus/models"
 	"FeaturePlus/repositories"
@@ -11,6 +12,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -23,31 +25,70 @@ func main() {
 	}
 
 	// Migrate all schemas
-	if err := db.Migrate(&models.User{}, &models.Project{}, &models.Feature{}, &models.SubFeature{}, &models.Task{}, &models.FeatureTag{}); err != nil {
+	if err := db.Migrate(&models.User{}, &models.Project{}, &models.Feature{}, &models.Task{}, &models.FeatureTag{}, &models.IdempotencyKey{}, &models.WorkflowStatus{}, &models.WorkflowTransition{}, &models.CustomField{}, &models.CustomFieldValue{}, &models.FeatureLink{}, &models.Milestone{}, &models.FeatureStatusChange{}, &models.Worklog{}, &models.Sprint{}, &models.SprintScopeChange{}, &models.ProjectSnapshot{}, &models.FeedbackRequest{}, &models.FeedbackVote{}, &models.FeedbackNotification{}, &models.Tag{}, &models.TaskTag{}, &models.ProjectTag{}); err != nil {
 		panic("failed to migrate database: " + err.Error())
 	}
 
+	// Fold the legacy sub_features table into the feature tree
+	if err := db.MigrateSubFeatures(); err != nil {
+		panic("failed to migrate sub-features: " + err.Error())
+	}
+
+	// Give tags from before tags were entities their project's tag
+	if err := db.MigrateFeatureTags(); err != nil {
+		panic("failed to migrate tags: " + err.Error())
+	}
+
 	// Create repositories
 	userRepo := repositories.NewUserRepository(db.DB)
 	projectRepo := repositories.NewProjectRepository(db.DB)
 	featureRepo := repositories.NewFeatureRepository(db.DB)
 	taskRepo := repositories.NewTaskRepository(db.DB)
 	tagRepo := repositories.NewTagRepository(db.DB)
+	idempotencyRepo := repositories.NewIdempotencyRepository(db.DB)
+	workflowRepo := repositories.NewWorkflowRepository(db.DB)
+	customFieldRepo := repositories.NewCustomFieldRepository(db.DB)
+	linkRepo := repositories.NewLinkRepository(db.DB)
+	milestoneRepo := repositories.NewMilestoneRepository(db.DB)
+	worklogRepo := repositories.NewWorklogRepository(db.DB)
+	sprintRepo := repositories.NewSprintRepository(db.DB)
+	snapshotRepo := repositories.NewSnapshotRepository(db.DB)
+	feedbackRepo := repositories.NewFeedbackRepository(db.DB)
 
 	// Create handlers
 	userHandler := handlers.NewUserHandler(userRepo)
-	projectHandler := handlers.NewProjectHandler(projectRepo)
-	featureHandler := handlers.NewFeatureHandler(featureRepo, tagRepo)
-	taskHandler := handlers.NewTaskHandler(taskRepo)
-	tagHandler := handlers.NewTagHandler(tagRepo, featureRepo)
+	projectHandler := handlers.NewProjectHandler(projectRepo, featureRepo, workflowRepo)
+	featureHandler := handlers.NewFeatureHandler(featureRepo, tagRepo, taskRepo, workflowRepo, customFieldRepo, userRepo, milestoneRepo)
+	taskHandler := handlers.NewTaskHandler(taskRepo, featureRepo)
+	tagHandler := handlers.NewTagHandler(tagRepo, featureRepo, projectRepo, taskRepo)
+	bulkHandler := handlers.NewBulkHandler(db.DB)
+	workflowHandler := handlers.NewWorkflowHandler(workflowRepo, projectRepo)
+	customFieldHandler := handlers.NewCustomFieldHandler(customFieldRepo, projectRepo)
+	linkHandler := handlers.NewLinkHandler(linkRepo, featureRepo)
+	milestoneHandler := handlers.NewMilestoneHandler(milestoneRepo, projectRepo, featureRepo)
+	dueDateHandler := handlers.NewDueDateHandler(featureRepo, projectRepo, userRepo)
+	worklogHandler := handlers.NewWorklogHandler(worklogRepo, featureRepo, taskRepo, projectRepo, userRepo)
+	sprintHandler := handlers.NewSprintHandler(sprintRepo, projectRepo, featureRepo, taskRepo)
+	burndownHandler := handlers.NewBurndownHandler(snapshotRepo, projectRepo)
+	feedbackHandler := handlers.NewFeedbackHandler(feedbackRepo, projectRepo, featureRepo)
+
+	// Idempotency keys are remembered for IDEMPOTENCY_WINDOW (default 24h)
+	idempotency := middleware.IdempotencyMiddleware(idempotencyRepo, envDuration("IDEMPOTENCY_WINDOW", 24*time.Hour))
+
+	// Overdue flags are refreshed every OVERDUE_CHECK_INTERVAL (default 1h)
+	jobs.StartOverdueJob(featureRepo, envDuration("OVERDUE_CHECK_INTERVAL", time.Hour))
+
+	// Burndown snapshots are refreshed every SNAPSHOT_INTERVAL (default 1h)
+	jobs.StartSnapshotJob(snapshotRepo, envDuration("SNAPSHOT_INTERVAL", time.Hour))
 
 	router := gin.Default()
 
 	// CORS middleware
 	router.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, If-Match, If-None-Match, Idempotency-Key")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "ETag, Idempotent-Replayed")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 
 		if c.Request.Method == "OPTIONS" {
@@ -70,28 +111,84 @@ func main() {
 		userRoutes.DELETE("/:id", userHandler.DeleteUser)
 	}
 
+	// Due date views and timesheets per user, protected unlike the user routes above
+	userDueRoutes := router.Group("/api/users", middleware.AuthMiddleware())
+	{
+		userDueRoutes.GET("/:id/overdue", dueDateHandler.GetUserOverdue)
+		userDueRoutes.GET("/:id/due-this-week", dueDateHandler.GetUserDueThisWeek)
+		userDueRoutes.GET("/:id/timesheet", worklogHandler.GetTimesheet)
+	}
+
 	// Protected routes - requires authentication
 	// Project routes
-	projectRoutes := router.Group("/api/projects", middleware.AuthMiddleware())
+	projectRoutes := router.Group("/api/projects", middleware.AuthMiddleware(), idempotency)
 	{
 		projectRoutes.POST("", projectHandler.CreateProject)
 		projectRoutes.GET("", projectHandler.GetAllProjects)
 		projectRoutes.GET("/:id", projectHandler.GetProject)
 		projectRoutes.PUT("/:id", projectHandler.UpdateProject)
+		projectRoutes.PATCH("/:id", projectHandler.PatchProject)
 		projectRoutes.DELETE("/:id", projectHandler.DeleteProject)
 		projectRoutes.GET("/user/:user_id", projectHandler.GetProjectsByUser)
+		projectRoutes.GET("/:id/tree", featureHandler.GetProjectTree)
+		projectRoutes.GET("/:id/workflow", workflowHandler.GetWorkflow)
+		projectRoutes.PUT("/:id/workflow", workflowHandler.UpdateWorkflow)
+		projectRoutes.DELETE("/:id/workflow", workflowHandler.ResetWorkflow)
+		projectRoutes.GET("/:id/custom-fields", customFieldHandler.GetCustomFields)
+		projectRoutes.POST("/:id/custom-fields", customFieldHandler.CreateCustomField)
+		projectRoutes.PUT("/:id/custom-fields/:field_id", customFieldHandler.UpdateCustomField)
+		projectRoutes.DELETE("/:id/custom-fields/:field_id", customFieldHandler.DeleteCustomField)
+		projectRoutes.GET("/:id/dependencies", linkHandler.GetDependencyGraph)
+		projectRoutes.GET("/:id/diagram", projectHandler.GetProjectDiagram)
+		projectRoutes.GET("/:id/changelog", projectHandler.GetChangelog)
+		projectRoutes.GET("/:id/milestones", milestoneHandler.GetMilestones)
+		projectRoutes.POST("/:id/milestones", milestoneHandler.CreateMilestone)
+		projectRoutes.GET("/:id/sprints", sprintHandler.GetSprints)
+		projectRoutes.POST("/:id/sprints", sprintHandler.CreateSprint)
+		projectRoutes.GET("/:id/burndown", burndownHandler.GetBurndown)
+		projectRoutes.GET("/:id/flow", projectHandler.GetFlowMetrics)
+		projectRoutes.GET("/:id/scores", projectHandler.GetScores)
+		projectRoutes.GET("/:id/feedback", feedbackHandler.GetRequests)
+		projectRoutes.GET("/:id/tags", tagHandler.GetProjectTags)
+		projectRoutes.PUT("/:id/tags", tagHandler.UpdateProjectTags)
+		projectRoutes.GET("/:id/tag-catalogue", tagHandler.GetTagCatalogue)
+		projectRoutes.POST("/:id/tag-catalogue", tagHandler.CreateTag)
+		projectRoutes.PUT("/:id/tag-catalogue/:tag_id", tagHandler.UpdateTag)
+		projectRoutes.DELETE("/:id/tag-catalogue/:tag_id", tagHandler.DeleteTag)
+		projectRoutes.POST("/:id/tag-catalogue/:tag_id/merge", tagHandler.MergeTag)
+		projectRoutes.POST("/:id/feedback", feedbackHandler.CreateRequest)
+		projectRoutes.GET("/:id/board", featureHandler.GetBoard)
+		projectRoutes.POST("/:id/board/move", featureHandler.MoveCard)
+		projectRoutes.POST("/:id/backlog/move", featureHandler.MoveInBacklog)
+		projectRoutes.POST("/:id/backlog/reorder", featureHandler.ReorderBacklog)
+		projectRoutes.GET("/:id/overdue", dueDateHandler.GetProjectOverdue)
+		projectRoutes.GET("/:id/due-this-week", dueDateHandler.GetProjectDueThisWeek)
+		projectRoutes.GET("/:id/time", worklogHandler.GetProjectTime)
+		projectRoutes.GET("/:id/worklogs", worklogHandler.GetProjectWorklogs)
 	}
 
 	// Feature routes
-	featureRoutes := router.Group("/api/features", middleware.AuthMiddleware())
+	featureRoutes := router.Group("/api/features", middleware.AuthMiddleware(), idempotency)
 	{
 		featureRoutes.POST("", featureHandler.CreateFeature)
+		featureRoutes.POST("/bulk", bulkHandler.BulkFeatures)
 		featureRoutes.GET("", featureHandler.GetAllFeatures)
 		featureRoutes.GET("/:id", featureHandler.GetFeature)
 		featureRoutes.GET("/project/:project_id", featureHandler.GetProjectFeatures)
 		featureRoutes.PUT("/:id", featureHandler.UpdateFeature)
+		featureRoutes.PATCH("/:id", featureHandler.PatchFeature)
 		featureRoutes.DELETE("/:id", featureHandler.DeleteFeature)
 		featureRoutes.GET("/:id/subfeatures", featureHandler.GetSubfeatures)
+		featureRoutes.POST("/:id/subfeatures", featureHandler.CreateSubfeature)
+		featureRoutes.GET("/:id/tree", featureHandler.GetFeatureTree)
+		featureRoutes.GET("/:id/status-history", featureHandler.GetStatusHistory)
+		featureRoutes.GET("/:id/score", featureHandler.GetScore)
+		featureRoutes.POST("/:id/move", featureHandler.MoveFeature)
+		featureRoutes.POST("/:id/copy", featureHandler.CopyFeature)
+		featureRoutes.GET("/:id/links", linkHandler.GetFeatureLinks)
+		featureRoutes.POST("/:id/links", linkHandler.CreateFeatureLink)
+		featureRoutes.DELETE("/:id/links/:link_id", linkHandler.DeleteFeatureLink)
+		featureRoutes.GET("/:id/worklogs", worklogHandler.GetFeatureWorklogs)
 
 		// Feature-specific Task routes
 		featureRoutes.POST("/:id/tasks", taskHandler.CreateTaskForFeature)
@@ -105,35 +202,109 @@ func main() {
 	}
 
 	// General task routes
-	taskRoutes := router.Group("/api/tasks", middleware.AuthMiddleware())
+	taskRoutes := router.Group("/api/tasks", middleware.AuthMiddleware(), idempotency)
 	{
 		taskRoutes.POST("", taskHandler.CreateTask)
+		taskRoutes.POST("/bulk", bulkHandler.BulkTasks)
 		taskRoutes.GET("/:id", taskHandler.GetTask)
 		taskRoutes.PUT("/:id", taskHandler.UpdateTask)
+		taskRoutes.PATCH("/:id", taskHandler.PatchTask)
 		taskRoutes.DELETE("/:id", taskHandler.DeleteTask)
+		taskRoutes.GET("/:id/worklogs", worklogHandler.GetTaskWorklogs)
+		taskRoutes.GET("/:id/tags", tagHandler.GetTaskTags)
+		taskRoutes.PUT("/:id/tags", tagHandler.UpdateTaskTags)
 	}
 
-	// Sub-feature routes
-	subFeatureRoutes := router.Group("/api/sub-features", middleware.AuthMiddleware())
+	// Worklog routes; timers belong to the authenticated user
+	worklogRoutes := router.Group("/api/worklogs", middleware.AuthMiddleware(), idempotency)
 	{
-		subFeatureRoutes.POST("", handlers.CreateSubFeature(db.DB))
-		subFeatureRoutes.PUT("/:id", handlers.UpdateSubFeature(db.DB))
-		subFeatureRoutes.GET("", handlers.GetSubFeaturesByFeature(db.DB))
-		subFeatureRoutes.GET("/project", handlers.GetSubFeaturesByProject(db.DB))
-		subFeatureRoutes.GET("/:id", handlers.GetSubFeatureDetail(db.DB))
+		worklogRoutes.POST("", worklogHandler.CreateWorklog)
+		worklogRoutes.PUT("/:id", worklogHandler.UpdateWorklog)
+		worklogRoutes.DELETE("/:id", worklogHandler.DeleteWorklog)
+		worklogRoutes.GET("/timer", worklogHandler.GetTimer)
+		worklogRoutes.POST("/timer/start", worklogHandler.StartTimer)
+		worklogRoutes.POST("/timer/stop", worklogHandler.StopTimer)
+	}
 
-		// Sub-feature task routes
-		subFeatureRoutes.POST("/:id/tasks", taskHandler.CreateTaskForSubFeature)
-		subFeatureRoutes.GET("/:id/tasks", taskHandler.GetTasksBySubFeature)
-		subFeatureRoutes.PUT("/:id/task/:task_id", taskHandler.UpdateTaskForSubFeature)
-		subFeatureRoutes.DELETE("/:id/task/:task_id", taskHandler.DeleteTaskForSubFeature)
+	// Legacy sub-feature routes. Sub-features are features with a parent now, so these
+	// are compatibility shims over /api/features/:id/subfeatures and the feature routes.
+	// Their :id is a legacy sub-feature ID and is resolved to a feature ID first.
+	subFeatureRoutes := router.Group("/api/sub-features", middleware.AuthMiddleware(), idempotency, featureHandler.ResolveLegacySubFeature)
+	{
+		subFeatureRoutes.POST("", featureHandler.CreateLegacySubFeature)
+		subFeatureRoutes.PUT("/:id", featureHandler.UpdateLegacySubFeature)
+		subFeatureRoutes.PATCH("/:id", featureHandler.PatchLegacySubFeature)
+		subFeatureRoutes.GET("", featureHandler.GetLegacySubFeaturesByFeature)
+		subFeatureRoutes.GET("/project", featureHandler.GetLegacySubFeaturesByProject)
+		subFeatureRoutes.GET("/:id", featureHandler.GetLegacySubFeatureDetail)
+		subFeatureRoutes.GET("/:id/tags", tagHandler.GetSubFeatureTags)
+		subFeatureRoutes.PUT("/:id/tags", tagHandler.UpdateSubFeatureTags)
+
+		// Sub-feature tasks are the tasks of the child feature
+		subFeatureRoutes.POST("/:id/tasks", taskHandler.CreateTaskForFeature)
+		subFeatureRoutes.GET("/:id/tasks", taskHandler.GetTasksByFeature)
+		subFeatureRoutes.PUT("/:id/task/:task_id", taskHandler.UpdateTaskForFeature)
+		subFeatureRoutes.DELETE("/:id/task/:task_id", taskHandler.DeleteTaskForFeature)
+	}
+
+	// Milestone routes
+	milestoneRoutes := router.Group("/api/milestones", middleware.AuthMiddleware(), idempotency)
+	{
+		milestoneRoutes.GET("/:id", milestoneHandler.GetMilestone)
+		milestoneRoutes.PUT("/:id", milestoneHandler.UpdateMilestone)
+		milestoneRoutes.DELETE("/:id", milestoneHandler.DeleteMilestone)
+		milestoneRoutes.GET("/:id/features", milestoneHandler.GetMilestoneFeatures)
+		milestoneRoutes.POST("/:id/close", milestoneHandler.CloseMilestone)
+		milestoneRoutes.POST("/:id/reopen", milestoneHandler.ReopenMilestone)
+	}
+
+	// Sprint routes
+	sprintRoutes := router.Group("/api/sprints", middleware.AuthMiddleware(), idempotency)
+	{
+		sprintRoutes.GET("/:id", sprintHandler.GetSprint)
+		sprintRoutes.PUT("/:id", sprintHandler.UpdateSprint)
+		sprintRoutes.DELETE("/:id", sprintHandler.DeleteSprint)
+		sprintRoutes.GET("/:id/items", sprintHandler.GetSprintItems)
+		sprintRoutes.POST("/:id/features/:feature_id", sprintHandler.AddFeature)
+		sprintRoutes.DELETE("/:id/features/:feature_id", sprintHandler.RemoveFeature)
+		sprintRoutes.POST("/:id/tasks/:task_id", sprintHandler.AddTask)
+		sprintRoutes.DELETE("/:id/tasks/:task_id", sprintHandler.RemoveTask)
+		sprintRoutes.POST("/:id/start", sprintHandler.StartSprint)
+		sprintRoutes.POST("/:id/close", sprintHandler.CloseSprint)
+		sprintRoutes.GET("/:id/scope-changes", sprintHandler.GetScopeChanges)
+	}
+
+	// Feedback routes; notifications belong to the authenticated user
+	feedbackRoutes := router.Group("/api/feedback", middleware.AuthMiddleware(), idempotency)
+	{
+		feedbackRoutes.GET("/notifications", feedbackHandler.GetNotifications)
+		feedbackRoutes.POST("/notifications/:id/read", feedbackHandler.ReadNotification)
+		feedbackRoutes.GET("/:id", feedbackHandler.GetRequest)
+		feedbackRoutes.PUT("/:id", feedbackHandler.UpdateRequest)
+		feedbackRoutes.DELETE("/:id", feedbackHandler.DeleteRequest)
+		feedbackRoutes.GET("/:id/votes", feedbackHandler.GetVotes)
+		feedbackRoutes.POST("/:id/votes", feedbackHandler.AddVote)
+		feedbackRoutes.DELETE("/:id/votes/:vote_id", feedbackHandler.RemoveVote)
+		feedbackRoutes.POST("/:id/merge", feedbackHandler.MergeRequest)
+		feedbackRoutes.PUT("/:id/feature", feedbackHandler.LinkFeature)
+	}
+
+	// Feedback portal for stakeholders: public requests only, no login needed
+	portalRoutes := router.Group("/api/public")
+	{
+		portalRoutes.GET("/projects/:id/feedback", feedbackHandler.GetPublicRequests)
+		portalRoutes.GET("/feedback/:id", feedbackHandler.GetPublicRequest)
 	}
 
 	// Tag routes
-	tagRoutes := router.Group("/api/tags", middleware.AuthMiddleware())
+	tagRoutes := router.Group("/api/tags", middleware.AuthMiddleware(), idempotency)
 	{
 		tagRoutes.GET("", tagHandler.GetAllTags)
+		tagRoutes.GET("/:tag_name", tagHandler.GetTagged)
 		tagRoutes.GET("/:tag_name/features", tagHandler.GetFeaturesByTag)
+		tagRoutes.GET("/:tag_name/sub-features", tagHandler.GetSubFeaturesByTag)
+		tagRoutes.GET("/:tag_name/tasks", tagHandler.GetTasksByTag)
+		tagRoutes.GET("/:tag_name/projects", tagHandler.GetProjectsByTag)
 	}
 
 	// Health check
@@ -175,3 +346,17 @@ func main() {
 		panic("failed to start server: " + err.Error())
 	}
 }
+
+// envDuration reads a positive Go duration such as "90m" from the environment,
+// falling back to def when the variable is unset
+func envDuration(name string, def time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		panic("invalid " + name + ": " + value)
+	}
+	return duration
+}
@@ -0,0 +1,168 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"time"
+
+	"FeaturePlus/models"
+	"FeaturePlus/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxIdempotencyKeyLength = 255
+
+// responseRecorder copies everything written to the client so it can be stored
+type responseRecorder struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// IdempotencyMiddleware honours the Idempotency-Key header on POST requests.
+// The first request with a key is executed and its response stored for the given
+// window; retries with the same key and body get the stored response replayed,
+// while reusing the key for a different request is rejected. Keys are scoped per
+// user, so it must run after AuthMiddleware; requests without a user are passed
+// through untouched.
+func IdempotencyMiddleware(repo *repositories.IdempotencyRepository, window time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+		if c.Request.Method != http.MethodPost || key == "" {
+			c.Next()
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is too long"})
+			c.Abort()
+			return
+		}
+
+		// Anonymous callers have no scope of their own to keep keys apart in
+		id, exists := c.Get("user_id")
+		if !exists {
+			c.Next()
+			return
+		}
+		userID := id.(uint)
+
+		// Read the body so it can be fingerprinted, then put it back for the handler
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		fingerprint := requestFingerprint(c.Request.Method, c.Request.URL.RequestURI(), body)
+
+		now := time.Now()
+		existing, err := repo.FindActive(userID, key, now)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up Idempotency-Key"})
+			c.Abort()
+			return
+		}
+		if existing != nil {
+			replayIdempotentResponse(c, existing, fingerprint)
+			return
+		}
+
+		// Clear out stale keys so an expired key can be reused
+		if err := repo.DeleteExpired(now); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store Idempotency-Key"})
+			c.Abort()
+			return
+		}
+
+		record := &models.IdempotencyKey{
+			UserID:      userID,
+			Key:         key,
+			Fingerprint: fingerprint,
+			ExpiresAt:   now.Add(window),
+		}
+		reserved, err := repo.Reserve(record)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store Idempotency-Key"})
+			c.Abort()
+			return
+		}
+		if !reserved {
+			// Another request grabbed the key between the lookup and the insert
+			c.JSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is already in progress"})
+			c.Abort()
+			return
+		}
+
+		// Unless a response gets stored, the key is freed again, also when the handler
+		// panics, so the client is not locked out of it for the whole window
+		stored := false
+		defer func() {
+			if !stored {
+				repo.Release(record.ID)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer, body: &bytes.Buffer{}}
+		c.Writer = recorder
+
+		c.Next()
+
+		// Server errors are not remembered so the client can retry them
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError {
+			return
+		}
+
+		record.StatusCode = status
+		record.ContentType = recorder.Header().Get("Content-Type")
+		record.ETag = recorder.Header().Get("ETag")
+		record.ResponseBody = recorder.body.Bytes()
+		stored = repo.Complete(record) == nil
+	}
+}
+
+// replayIdempotentResponse answers a retried request from a stored record
+func replayIdempotentResponse(c *gin.Context, record *models.IdempotencyKey, fingerprint string) {
+	defer c.Abort()
+
+	if record.Fingerprint != fingerprint {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used with a different request"})
+		return
+	}
+	if !record.Completed {
+		c.JSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is already in progress"})
+		return
+	}
+
+	if record.ETag != "" {
+		c.Header("ETag", record.ETag)
+	}
+	c.Header("Idempotent-Replayed", "true")
+	c.Data(record.StatusCode, record.ContentType, record.ResponseBody)
+}
+
+// requestFingerprint hashes the parts of a request that must match for a replay
+func requestFingerprint(method string, uri string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method))
+	hash.Write([]byte{' '})
+	hash.Write([]byte(uri))
+	hash.Write([]byte{'\n'})
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,142 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"FeaturePlus/models"
+	"FeaturePlus/repositories"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func openIdempotencyDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := db.AutoMigrate(&models.IdempotencyKey{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// idempotentRouter serves POST /run behind the middleware, as the given user when
+// userID is not zero. Every call of the handler is passed to handle.
+func idempotentRouter(db *gorm.DB, userID uint, handle func(c *gin.Context)) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(gin.CustomRecovery(func(c *gin.Context, _ any) {
+		c.AbortWithStatus(http.StatusInternalServerError)
+	}))
+	if userID != 0 {
+		router.Use(func(c *gin.Context) { c.Set("user_id", userID) })
+	}
+	router.Use(IdempotencyMiddleware(repositories.NewIdempotencyRepository(db), time.Hour))
+	router.POST("/run", handle)
+	return router
+}
+
+func postWithKey(router *gin.Engine, key string, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/run", strings.NewReader(body))
+	req.Header.Set("Idempotency-Key", key)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func countKeys(t *testing.T, db *gorm.DB) int64 {
+	t.Helper()
+	var count int64
+	if err := db.Model(&models.IdempotencyKey{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestIdempotencyReplaysStoredResponse(t *testing.T) {
+	db := openIdempotencyDB(t)
+	calls := 0
+	router := idempotentRouter(db, 1, func(c *gin.Context) {
+		calls++
+		c.JSON(http.StatusCreated, gin.H{"call": calls})
+	})
+
+	first := postWithKey(router, "k", `{"a":1}`)
+	retry := postWithKey(router, "k", `{"a":1}`)
+	if calls != 1 {
+		t.Fatalf("handler ran %d times, want 1", calls)
+	}
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() || retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("retry = %d %s, want the stored response replayed", retry.Code, retry.Body)
+	}
+	if other := postWithKey(router, "k", `{"a":2}`); other.Code != http.StatusUnprocessableEntity {
+		t.Errorf("reuse with another body = %d, want 422", other.Code)
+	}
+}
+
+func TestIdempotencyReleasesKeyAfterPanic(t *testing.T) {
+	db := openIdempotencyDB(t)
+	calls := 0
+	router := idempotentRouter(db, 1, func(c *gin.Context) {
+		calls++
+		if calls == 1 {
+			panic("boom")
+		}
+		c.JSON(http.StatusCreated, gin.H{"ok": true})
+	})
+
+	if w := postWithKey(router, "k", `{}`); w.Code != http.StatusInternalServerError {
+		t.Fatalf("panicking request = %d, want 500", w.Code)
+	}
+	if n := countKeys(t, db); n != 0 {
+		t.Fatalf("%d keys left reserved after a panic", n)
+	}
+	if w := postWithKey(router, "k", `{}`); w.Code != http.StatusCreated {
+		t.Errorf("retry after a panic = %d, want 201", w.Code)
+	}
+}
+
+func TestIdempotencyReleasesKeyAfterServerError(t *testing.T) {
+	db := openIdempotencyDB(t)
+	router := idempotentRouter(db, 1, func(c *gin.Context) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "down"})
+	})
+
+	postWithKey(router, "k", `{}`)
+	if n := countKeys(t, db); n != 0 {
+		t.Errorf("%d keys stored for a server error", n)
+	}
+}
+
+func TestIdempotencyIgnoresAnonymousRequests(t *testing.T) {
+	db := openIdempotencyDB(t)
+	calls := 0
+	router := idempotentRouter(db, 0, func(c *gin.Context) {
+		calls++
+		c.JSON(http.StatusOK, gin.H{"token": "secret"})
+	})
+
+	postWithKey(router, "k", `{"email":"a"}`)
+	if w := postWithKey(router, "k", `{"email":"b"}`); w.Code != http.StatusOK {
+		t.Errorf("second anonymous caller = %d, want 200", w.Code)
+	}
+	if calls != 2 {
+		t.Errorf("handler ran %d times, want 2", calls)
+	}
+	if n := countKeys(t, db); n != 0 {
+		t.Errorf("%d keys stored for anonymous requests", n)
+	}
+}
@@ -0,0 +1,112 @@
+package models
+
+import "strings"
+
+// rankDigits are the digits of board ranks. Ranks compare as plain strings and are
+// read as base-36 fractions, so there is always room for another rank between two.
+const rankDigits = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+// MaxRankLength is how long a rank may grow before its column is spread out again
+const MaxRankLength = 32
+
+func rankDigit(rank string, i int) int {
+	if i >= len(rank) {
+		return 0
+	}
+	return strings.IndexByte(rankDigits, rank[i])
+}
+
+// RankBetween returns a rank that sorts after before and ahead of after. An empty
+// before means the start of the column and an empty after means its end. The result
+// never ends in a zero digit, so it can always be split again.
+func RankBetween(before string, after string) string {
+	var rank []byte
+	bounded := after != ""
+	for i := 0; ; i++ {
+		low := rankDigit(before, i)
+		high := len(rankDigits)
+		if bounded {
+			high = rankDigit(after, i)
+		}
+		if high-low > 1 {
+			return string(append(rank, rankDigits[(low+high)/2]))
+		}
+		rank = append(rank, rankDigits[low])
+		if high-low == 1 {
+			bounded = false
+		}
+	}
+}
+
+// SpreadRanks returns count ranks in ascending order, spaced evenly so later moves
+// have room between them
+func SpreadRanks(count int) []string {
+	width, space := 1, len(rankDigits)
+	for space <= count*len(rankDigits) {
+		width++
+		space *= len(rankDigits)
+	}
+	step := space / (count + 1)
+
+	ranks := make([]string, count)
+	for i := range ranks {
+		value := (i + 1) * step
+		digits := make([]byte, width)
+		for j := width - 1; j >= 0; j-- {
+			digits[j] = rankDigits[value%len(rankDigits)]
+			value /= len(rankDigits)
+		}
+		ranks[i] = strings.TrimRight(string(digits), "0")
+	}
+	return ranks
+}
+
+// BoardColumn is one workflow status with its cards in rank order. OverLimit is set
+// when the column holds more cards than its WIP limit.
+type BoardColumn struct {
+	Key       FeatureStatus  `json:"key"`
+	Name      string         `json:"name"`
+	Category  StatusCategory `json:"category"`
+	WIPLimit  int            `json:"wip_limit"`
+	WIPPolicy WIPPolicy      `json:"wip_policy"`
+	Count     int            `json:"count"`
+	OverLimit bool           `json:"over_limit"`
+	Cards     []Feature      `json:"cards"`
+}
+
+// Board lays a project's features out in one column per workflow status
+type Board struct {
+	ProjectID int           `json:"project_id"`
+	Columns   []BoardColumn `json:"columns"`
+}
+
+// NewBoard places ranked features in the workflow's columns, keeping their order
+func NewBoard(projectID int, workflow *Workflow, features []Feature) *Board {
+	board := &Board{ProjectID: projectID, Columns: []BoardColumn{}}
+	index := map[FeatureStatus]int{}
+	for _, status := range workflow.Statuses {
+		index[status.Key] = len(board.Columns)
+		board.Columns = append(board.Columns, BoardColumn{
+			Key:       status.Key,
+			Name:      status.Name,
+			Category:  status.Category,
+			WIPLimit:  status.WIPLimit,
+			WIPPolicy: status.WIPPolicy,
+			Cards:     []Feature{},
+		})
+	}
+
+	for _, feature := range features {
+		i, ok := index[feature.Status]
+		if !ok {
+			continue
+		}
+		board.Columns[i].Cards = append(board.Columns[i].Cards, feature)
+	}
+	for i := range board.Columns {
+		column := &board.Columns[i]
+		column.Count = len(column.Cards)
+		column.OverLimit = column.WIPLimit > 0 && column.Count > column.WIPLimit
+	}
+	return board
+}
@@ -0,0 +1,114 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func checkRank(t *testing.T, rank string, before string, after string) {
+	t.Helper()
+	if rank <= before {
+		t.Errorf("rank %q does not sort after %q", rank, before)
+	}
+	if after != "" && rank >= after {
+		t.Errorf("rank %q does not sort ahead of %q", rank, after)
+	}
+	if strings.HasSuffix(rank, "0") {
+		t.Errorf("rank %q ends in a zero digit", rank)
+	}
+}
+
+func TestRankBetween(t *testing.T) {
+	tests := []struct {
+		name   string
+		before string
+		after  string
+		want   string
+	}{
+		{"empty column", "", "", "i"},
+		{"start", "", "i", "9"},
+		{"end", "i", "", "r"},
+		{"middle", "a", "c", "b"},
+		{"adjacent digits", "1", "2", "1i"},
+		{"adjacent letters", "a", "b", "ai"},
+		{"prefix", "a", "a1", "a0i"},
+		{"after zero padding", "a", "a01", "a00i"},
+		{"before lowest digit", "", "1", "0i"},
+		{"after highest digit", "z", "", "zi"},
+		{"longer before", "az", "b", "azi"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RankBetween(tt.before, tt.after)
+			if got != tt.want {
+				t.Errorf("RankBetween(%q, %q) = %q, want %q", tt.before, tt.after, got, tt.want)
+			}
+			checkRank(t, got, tt.before, tt.after)
+		})
+	}
+}
+
+func TestRankBetweenRepeatedInserts(t *testing.T) {
+	tests := []struct {
+		name string
+		next func(prev string) (before string, after string)
+	}{
+		{"always at the start", func(prev string) (string, string) { return "", prev }},
+		{"always at the end", func(prev string) (string, string) { return prev, "" }},
+		{"always right after the first", func(prev string) (string, string) { return "a", prev }},
+		{"always right before the last", func(prev string) (string, string) { return prev, "b" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prev := "an"
+			for i := 0; i < 10000; i++ {
+				before, after := tt.next(prev)
+				rank := RankBetween(before, after)
+				checkRank(t, rank, before, after)
+				if t.Failed() {
+					return
+				}
+				if len(rank) > MaxRankLength {
+					// This is where placeRank spreads the column out again
+					return
+				}
+				prev = rank
+			}
+			t.Errorf("rank never grew past %d digits", MaxRankLength)
+		})
+	}
+}
+
+func TestSpreadRanks(t *testing.T) {
+	tests := []struct {
+		count    int
+		maxWidth int
+	}{
+		{1, 1},
+		{2, 1},
+		{35, 2},
+		{36, 3},
+		{500, 3},
+		{2000, 4},
+	}
+	for _, tt := range tests {
+		ranks := SpreadRanks(tt.count)
+		if len(ranks) != tt.count {
+			t.Fatalf("SpreadRanks(%d) returned %d ranks", tt.count, len(ranks))
+		}
+		for i, rank := range ranks {
+			before := ""
+			if i > 0 {
+				before = ranks[i-1]
+			}
+			checkRank(t, rank, before, "")
+			if rank == "" || len(rank) > tt.maxWidth {
+				t.Errorf("SpreadRanks(%d)[%d] = %q, want 1 to %d digits", tt.count, i, rank, tt.maxWidth)
+			}
+			// A spread column must leave room for a move between every pair
+			if i > 0 {
+				checkRank(t, RankBetween(before, rank), before, rank)
+			}
+		}
+	}
+}
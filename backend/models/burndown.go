@@ -0,0 +1,147 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// ProjectSnapshot records the state of a project's work on one day. The snapshot job
+// rewrites the current day's row on every run, so a past day holds the state at the
+// last run of that day.
+type ProjectSnapshot struct {
+	ID           uint   `gorm:"primaryKey" json:"id"`
+	ProjectID    int    `gorm:"not null;uniqueIndex:idx_snapshot_day" json:"project_id"`
+	Date         string `gorm:"type:varchar(10);not null;uniqueIndex:idx_snapshot_day" json:"date"`
+	Features     int    `gorm:"not null;default:0" json:"features"`
+	DoneFeatures int    `gorm:"not null;default:0" json:"done_features"`
+	Tasks        int    `gorm:"not null;default:0" json:"tasks"`
+	DoneTasks    int    `gorm:"not null;default:0" json:"done_tasks"`
+
+	// Points add up feature estimates; open features count their remaining effort
+	DonePoints      float64   `gorm:"not null;default:0" json:"done_points"`
+	RemainingPoints float64   `gorm:"not null;default:0" json:"remaining_points"`
+	TakenAt         time.Time `gorm:"not null" json:"taken_at"`
+}
+
+// NewProjectSnapshot counts the features and tasks of a project. A task is done once
+// its feature is done.
+func NewProjectSnapshot(projectID int, date string, features []Feature, tasks []Task, workflow *Workflow) *ProjectSnapshot {
+	snapshot := &ProjectSnapshot{
+		ProjectID: projectID,
+		Date:      date,
+		Features:  len(features),
+		Tasks:     len(tasks),
+		TakenAt:   time.Now(),
+	}
+
+	done := map[uint]bool{}
+	for _, feature := range features {
+		if !workflow.IsDone(feature.Status) {
+			snapshot.RemainingPoints += feature.Estimates().Remaining()
+			continue
+		}
+		done[feature.ID] = true
+		snapshot.DoneFeatures++
+		if feature.Estimate != nil {
+			snapshot.DonePoints += float64(*feature.Estimate)
+		}
+	}
+	for _, task := range tasks {
+		if done[task.FeatureID] {
+			snapshot.DoneTasks++
+		}
+	}
+	return snapshot
+}
+
+type BurndownUnit string
+
+const (
+	BurndownCount  BurndownUnit = "count"
+	BurndownPoints BurndownUnit = "points"
+)
+
+// BurndownPoint is one day of a burndown. Remaining, Completed and Scope are nil for
+// days without data, such as days before the first snapshot or in the future, and
+// Ideal is nil before the first day with data.
+type BurndownPoint struct {
+	Date      string   `json:"date"`
+	Remaining *float64 `json:"remaining"`
+	Completed *float64 `json:"completed"`
+	Scope     *float64 `json:"scope"`
+	Ideal     *float64 `json:"ideal"`
+}
+
+// Burndown is a daily series of remaining and completed work in a project
+type Burndown struct {
+	ProjectID int             `json:"project_id"`
+	Unit      BurndownUnit    `json:"unit"`
+	From      string          `json:"from"`
+	To        string          `json:"to"`
+	Points    []BurndownPoint `json:"points"`
+}
+
+// values reads the remaining and completed work of a snapshot in the given unit
+func (s *ProjectSnapshot) values(unit BurndownUnit) (float64, float64) {
+	if unit == BurndownPoints {
+		return s.RemainingPoints, s.DonePoints
+	}
+	done := s.DoneFeatures + s.DoneTasks
+	return float64(s.Features + s.Tasks - done), float64(done)
+}
+
+// BuildBurndown lays snapshots out over the days from..to. A day without its own
+// snapshot repeats the latest earlier one, so snapshots may include one taken before
+// from. Days after today are left empty. The ideal line falls evenly from the work
+// remaining on the first day with data to zero on the last day.
+func BuildBurndown(projectID int, unit BurndownUnit, from string, to string, today string, snapshots []ProjectSnapshot) *Burndown {
+	burndown := &Burndown{ProjectID: projectID, Unit: unit, From: from, To: to, Points: []BurndownPoint{}}
+
+	start, err := time.Parse(DateLayout, from)
+	if err != nil {
+		return burndown
+	}
+	end, err := time.Parse(DateLayout, to)
+	if err != nil {
+		return burndown
+	}
+
+	next := 0
+	var latest *ProjectSnapshot
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		date := day.Format(DateLayout)
+		for next < len(snapshots) && snapshots[next].Date <= date {
+			latest = &snapshots[next]
+			next++
+		}
+
+		point := BurndownPoint{Date: date}
+		if latest != nil && date <= today {
+			remaining, completed := latest.values(unit)
+			scope := remaining + completed
+			point.Remaining, point.Completed, point.Scope = &remaining, &completed, &scope
+		}
+		burndown.Points = append(burndown.Points, point)
+	}
+
+	first := -1
+	for i, point := range burndown.Points {
+		if point.Remaining != nil {
+			first = i
+			break
+		}
+	}
+	if first < 0 {
+		return burndown
+	}
+	startValue := *burndown.Points[first].Remaining
+	steps := len(burndown.Points) - 1 - first
+	for i := first; i < len(burndown.Points); i++ {
+		ideal := 0.0
+		if steps > 0 {
+			ideal = math.Round(startValue*float64(len(burndown.Points)-1-i)/float64(steps)*100) / 100
+		}
+		burndown.Points[i].Ideal = &ideal
+	}
+	return burndown
+}
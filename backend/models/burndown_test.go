@@ -0,0 +1,118 @@
+package models
+
+import "testing"
+
+// burndownSeries reads a burndown series, using -1 for days without a value
+func burndownSeries(points []BurndownPoint, field func(BurndownPoint) *float64) []float64 {
+	series := make([]float64, len(points))
+	for i, point := range points {
+		series[i] = -1
+		if value := field(point); value != nil {
+			series[i] = *value
+		}
+	}
+	return series
+}
+
+func equalSeries(a []float64, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestBuildBurndownIdealLine(t *testing.T) {
+	tests := []struct {
+		name      string
+		from, to  string
+		today     string
+		snapshots []ProjectSnapshot
+		ideal     []float64
+		remaining []float64
+	}{
+		{
+			name: "data from the first day",
+			from: "2026-10-01", to: "2026-10-05", today: "2026-10-05",
+			snapshots: []ProjectSnapshot{{Date: "2026-10-01", Features: 10}},
+			ideal:     []float64{10, 7.5, 5, 2.5, 0},
+			remaining: []float64{10, 10, 10, 10, 10},
+		},
+		{
+			name: "starts at the first day with data",
+			from: "2026-10-01", to: "2026-10-05", today: "2026-10-05",
+			snapshots: []ProjectSnapshot{{Date: "2026-10-02", Features: 10}},
+			ideal:     []float64{-1, 10, 6.67, 3.33, 0},
+			remaining: []float64{-1, 10, 10, 10, 10},
+		},
+		{
+			name: "snapshot taken before from",
+			from: "2026-10-01", to: "2026-10-03", today: "2026-10-03",
+			snapshots: []ProjectSnapshot{
+				{Date: "2026-09-20", Features: 4, DoneFeatures: 0},
+				{Date: "2026-10-02", Features: 4, DoneFeatures: 1},
+			},
+			ideal:     []float64{4, 2, 0},
+			remaining: []float64{4, 3, 3},
+		},
+		{
+			name: "continues past today",
+			from: "2026-10-01", to: "2026-10-05", today: "2026-10-02",
+			snapshots: []ProjectSnapshot{{Date: "2026-10-01", Features: 8}},
+			ideal:     []float64{8, 6, 4, 2, 0},
+			remaining: []float64{8, 8, -1, -1, -1},
+		},
+		{
+			name: "ignores later scope changes",
+			from: "2026-10-01", to: "2026-10-03", today: "2026-10-03",
+			snapshots: []ProjectSnapshot{
+				{Date: "2026-10-01", Features: 6},
+				{Date: "2026-10-02", Features: 12},
+			},
+			ideal:     []float64{6, 3, 0},
+			remaining: []float64{6, 12, 12},
+		},
+		{
+			name: "single day",
+			from: "2026-10-01", to: "2026-10-01", today: "2026-10-01",
+			snapshots: []ProjectSnapshot{{Date: "2026-10-01", Features: 5}},
+			ideal:     []float64{0},
+			remaining: []float64{5},
+		},
+		{
+			name: "no data",
+			from: "2026-10-01", to: "2026-10-03", today: "2026-10-03",
+			ideal:     []float64{-1, -1, -1},
+			remaining: []float64{-1, -1, -1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			burndown := BuildBurndown(1, BurndownCount, tt.from, tt.to, tt.today, tt.snapshots)
+			ideal := burndownSeries(burndown.Points, func(p BurndownPoint) *float64 { return p.Ideal })
+			if !equalSeries(ideal, tt.ideal) {
+				t.Errorf("ideal = %v, want %v", ideal, tt.ideal)
+			}
+			remaining := burndownSeries(burndown.Points, func(p BurndownPoint) *float64 { return p.Remaining })
+			if !equalSeries(remaining, tt.remaining) {
+				t.Errorf("remaining = %v, want %v", remaining, tt.remaining)
+			}
+		})
+	}
+}
+
+func TestBuildBurndownPointsUnit(t *testing.T) {
+	snapshots := []ProjectSnapshot{{Date: "2026-10-01", Features: 3, RemainingPoints: 13, DonePoints: 5}}
+	burndown := BuildBurndown(1, BurndownPoints, "2026-10-01", "2026-10-03", "2026-10-03", snapshots)
+	ideal := burndownSeries(burndown.Points, func(p BurndownPoint) *float64 { return p.Ideal })
+	if want := []float64{13, 6.5, 0}; !equalSeries(ideal, want) {
+		t.Errorf("ideal = %v, want %v", ideal, want)
+	}
+	if scope := *burndown.Points[0].Scope; scope != 18 {
+		t.Errorf("scope = %v, want 18", scope)
+	}
+}
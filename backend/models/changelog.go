@@ -0,0 +1,394 @@
+package models
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+type ChangelogFormat string
+
+const (
+	ChangelogMarkdown ChangelogFormat = "markdown"
+	ChangelogHTML     ChangelogFormat = "html"
+	ChangelogJSON     ChangelogFormat = "json"
+)
+
+// ChangelogUser credits the person a shipped feature was assigned to
+type ChangelogUser struct {
+	ID       uint   `json:"id"`
+	Username string `json:"username"`
+}
+
+// ChangelogContributor is an assignee with the number of shipped items they owned
+type ChangelogContributor struct {
+	ChangelogUser
+	Count int `json:"count"`
+}
+
+type ChangelogTask struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// ChangelogEntry is a shipped feature. SubFeatures lists its finished descendants
+// depth first, with Depth counting levels below the entry.
+type ChangelogEntry struct {
+	ID          uint            `json:"id"`
+	Title       string          `json:"title"`
+	Description string          `json:"description"`
+	Status      FeatureStatus   `json:"status"`
+	Priority    FeaturePriority `json:"priority"`
+	CompletedAt *time.Time      `json:"completed_at"`
+	Assignee    *ChangelogUser  `json:"assignee"`
+	Tags        []string        `json:"tags"`
+	Tasks       []ChangelogTask `json:"tasks"`
+	Depth       int             `json:"depth,omitempty"`
+
+	SubFeatures []ChangelogEntry `json:"sub_features,omitempty"`
+}
+
+// ChangelogGroup holds the entries carrying one tag; untagged entries have an empty Tag
+type ChangelogGroup struct {
+	Tag     string           `json:"tag"`
+	Name    string           `json:"name"`
+	Entries []ChangelogEntry `json:"entries"`
+}
+
+// Changelog lists the features of a project that moved to done in a time window
+type Changelog struct {
+	ProjectID    int                    `json:"project_id"`
+	ProjectName  string                 `json:"project_name"`
+	Since        time.Time              `json:"since"`
+	Until        time.Time              `json:"until"`
+	Groups       []ChangelogGroup       `json:"groups"`
+	Contributors []ChangelogContributor `json:"contributors"`
+}
+
+// BuildChangelog collects the features that are done now and were completed in the
+// window, as given by completedAt. A shipped feature below another shipped feature
+// is listed under it rather than on its own. Entries with several tags appear in
+// each of their groups.
+func BuildChangelog(project Project, features []Feature, tasks []Task, completedAt map[uint]time.Time, workflow *Workflow, since time.Time, until time.Time) *Changelog {
+	byID := make(map[uint]*Feature, len(features))
+	children := map[uint][]*Feature{}
+	for i := range features {
+		byID[features[i].ID] = &features[i]
+		if parentID := features[i].ParentFeatureID; parentID != nil {
+			children[*parentID] = append(children[*parentID], &features[i])
+		}
+	}
+
+	tasksByFeature := map[uint][]ChangelogTask{}
+	for _, task := range tasks {
+		tasksByFeature[task.FeatureID] = append(tasksByFeature[task.FeatureID], ChangelogTask{ID: task.ID, Name: task.TaskName, Type: task.TaskType})
+	}
+
+	shipped := func(feature *Feature) bool {
+		_, ok := completedAt[feature.ID]
+		return ok && workflow.IsDone(feature.Status)
+	}
+
+	entry := func(feature *Feature, depth int) ChangelogEntry {
+		e := ChangelogEntry{
+			ID:          feature.ID,
+			Title:       feature.Title,
+			Description: feature.Description,
+			Status:      feature.Status,
+			Priority:    feature.Priority,
+			Tags:        []string{},
+			Tasks:       tasksByFeature[feature.ID],
+			Depth:       depth,
+		}
+		if at, ok := completedAt[feature.ID]; ok {
+			e.CompletedAt = &at
+		}
+		if feature.AssigneeID != 0 {
+			e.Assignee = &ChangelogUser{ID: feature.AssigneeID, Username: feature.Assignee.Username}
+		}
+		if e.Tasks == nil {
+			e.Tasks = []ChangelogTask{}
+		}
+		for _, tag := range feature.Tags {
+			e.Tags = append(e.Tags, tag.TagName)
+		}
+		return e
+	}
+
+	var finishedBelow func(feature *Feature, depth int) []ChangelogEntry
+	finishedBelow = func(feature *Feature, depth int) []ChangelogEntry {
+		var entries []ChangelogEntry
+		if depth > maxChangelogDepth {
+			return entries
+		}
+		for _, child := range children[feature.ID] {
+			if !workflow.IsDone(child.Status) {
+				continue
+			}
+			entries = append(entries, entry(child, depth))
+			entries = append(entries, finishedBelow(child, depth+1)...)
+		}
+		return entries
+	}
+
+	contributions := map[uint]*ChangelogContributor{}
+	credit := func(e ChangelogEntry) {
+		if e.Assignee == nil {
+			return
+		}
+		if contributions[e.Assignee.ID] == nil {
+			contributions[e.Assignee.ID] = &ChangelogContributor{ChangelogUser: *e.Assignee}
+		}
+		contributions[e.Assignee.ID].Count++
+	}
+
+	groups := map[string]*ChangelogGroup{}
+	var tagOrder []string
+	for i := range features {
+		feature := &features[i]
+		if !shipped(feature) || hasShippedAncestor(feature, byID, shipped, workflow) {
+			continue
+		}
+
+		e := entry(feature, 0)
+		e.SubFeatures = finishedBelow(feature, 1)
+		credit(e)
+		for _, sub := range e.SubFeatures {
+			credit(sub)
+		}
+
+		tags := e.Tags
+		if len(tags) == 0 {
+			tags = []string{""}
+		}
+		for _, tag := range tags {
+			group, ok := groups[tag]
+			if !ok {
+				group = &ChangelogGroup{Tag: tag, Name: tag, Entries: []ChangelogEntry{}}
+				if tag == "" {
+					group.Name = "Other"
+				}
+				groups[tag] = group
+				tagOrder = append(tagOrder, tag)
+			}
+			group.Entries = append(group.Entries, e)
+		}
+	}
+
+	// Tags sort alphabetically, with untagged entries last
+	sort.Slice(tagOrder, func(i, j int) bool {
+		if tagOrder[i] == "" || tagOrder[j] == "" {
+			return tagOrder[j] == ""
+		}
+		return tagOrder[i] < tagOrder[j]
+	})
+
+	changelog := &Changelog{
+		ProjectID:    project.ID,
+		ProjectName:  project.Name,
+		Since:        since,
+		Until:        until,
+		Groups:       []ChangelogGroup{},
+		Contributors: []ChangelogContributor{},
+	}
+	for _, tag := range tagOrder {
+		group := groups[tag]
+		sort.SliceStable(group.Entries, func(i, j int) bool {
+			return group.Entries[i].CompletedAt.Before(*group.Entries[j].CompletedAt)
+		})
+		changelog.Groups = append(changelog.Groups, *group)
+	}
+	for _, contributor := range contributions {
+		changelog.Contributors = append(changelog.Contributors, *contributor)
+	}
+	sort.Slice(changelog.Contributors, func(i, j int) bool {
+		a, b := changelog.Contributors[i], changelog.Contributors[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Username < b.Username
+	})
+	return changelog
+}
+
+// hasShippedAncestor reports whether the feature will be listed below a shipped
+// ancestor, which happens when every feature in between is done
+func hasShippedAncestor(feature *Feature, byID map[uint]*Feature, shipped func(*Feature) bool, workflow *Workflow) bool {
+	current := feature
+	for depth := 0; current.ParentFeatureID != nil && depth < maxChangelogDepth; depth++ {
+		parent, ok := byID[*current.ParentFeatureID]
+		if !ok || !workflow.IsDone(parent.Status) {
+			return false
+		}
+		if shipped(parent) {
+			return true
+		}
+		current = parent
+	}
+	return false
+}
+
+// maxChangelogDepth bounds walks over the feature tree should the data contain a cycle
+const maxChangelogDepth = 1000
+
+// maxChangelogIndent bounds indent so a template cannot build a huge string with it
+const maxChangelogIndent = 64
+
+// changelogFuncs are available to changelog templates
+var changelogFuncs = map[string]interface{}{
+	"indent": func(depth int) string { return strings.Repeat("  ", max(0, min(depth, maxChangelogIndent))) },
+	"date":   func(t *time.Time) string { return formatChangelogDate(t) },
+}
+
+func formatChangelogDate(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
+
+const defaultMarkdownChangelog = `# {{.ProjectName}} changelog
+
+{{.Since.Format "2006-01-02"}} to {{.Until.Format "2006-01-02"}}
+{{range .Groups}}
+## {{.Name}}
+{{range .Entries}}
+- **{{.Title}}** (#{{.ID}}){{if .Assignee}} by @{{.Assignee.Username}}{{end}}, done {{date .CompletedAt}}
+{{- range .SubFeatures}}
+{{indent .Depth}}- {{.Title}} (#{{.ID}}){{if .Assignee}} by @{{.Assignee.Username}}{{end}}
+{{- end}}
+{{- range .Tasks}}
+  - Task: {{.Name}}
+{{- end}}
+{{- end}}
+{{end}}
+{{- if .Contributors}}
+## Contributors
+{{range .Contributors}}
+- @{{.Username}} ({{.Count}})
+{{- end}}
+{{end}}`
+
+const defaultHTMLChangelog = `<h1>{{.ProjectName}} changelog</h1>
+<p>{{.Since.Format "2006-01-02"}} to {{.Until.Format "2006-01-02"}}</p>
+{{range .Groups}}<h2>{{.Name}}</h2>
+<ul>
+{{range .Entries}}<li><strong>{{.Title}}</strong> (#{{.ID}}){{if .Assignee}} by @{{.Assignee.Username}}{{end}}, done {{date .CompletedAt}}
+{{- if or .SubFeatures .Tasks}}
+<ul>
+{{- range .SubFeatures}}
+<li style="margin-left: {{.Depth}}em">{{.Title}} (#{{.ID}}){{if .Assignee}} by @{{.Assignee.Username}}{{end}}</li>
+{{- end}}
+{{- range .Tasks}}
+<li>Task: {{.Name}}</li>
+{{- end}}
+</ul>
+{{- end}}</li>
+{{end}}</ul>
+{{end}}
+{{- if .Contributors}}<h2>Contributors</h2>
+<ul>
+{{range .Contributors}}<li>@{{.Username}} ({{.Count}})</li>
+{{end}}</ul>
+{{end}}`
+
+// MaxChangelogTemplate limits the size of a stored changelog template
+const MaxChangelogTemplate = 64 * 1024
+
+// Templates are written by users, so rendering stops once the output passes
+// MaxChangelogOutput bytes or takes longer than ChangelogRenderTimeout
+const (
+	MaxChangelogOutput     = 4 << 20
+	ChangelogRenderTimeout = 5 * time.Second
+)
+
+var (
+	ErrChangelogTooLarge = errors.New("changelog output is too large")
+	ErrChangelogTimeout  = errors.New("changelog template took too long to run")
+)
+
+// changelogTemplate is what text/template and html/template have in common
+type changelogTemplate interface {
+	Execute(w io.Writer, data interface{}) error
+}
+
+func parseChangelogTemplate(format ChangelogFormat, source string) (changelogTemplate, error) {
+	if format == ChangelogHTML {
+		if source == "" {
+			source = defaultHTMLChangelog
+		}
+		return htmltemplate.New("changelog").Funcs(changelogFuncs).Parse(source)
+	}
+	if source == "" {
+		source = defaultMarkdownChangelog
+	}
+	return template.New("changelog").Funcs(changelogFuncs).Parse(source)
+}
+
+// CheckChangelogTemplate reports why a template cannot be stored for the format, or
+// nil when it can
+func CheckChangelogTemplate(format ChangelogFormat, source string) error {
+	if len(source) > MaxChangelogTemplate {
+		return errors.New("template is too large")
+	}
+	_, err := parseChangelogTemplate(format, source)
+	return err
+}
+
+// limitedBuffer collects template output and fails writes past its size or deadline,
+// which stops the template
+type limitedBuffer struct {
+	bytes.Buffer
+	deadline time.Time
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > MaxChangelogOutput {
+		return 0, ErrChangelogTooLarge
+	}
+	if time.Now().After(b.deadline) {
+		return 0, ErrChangelogTimeout
+	}
+	return b.Buffer.Write(p)
+}
+
+// Render executes the given template, or the default one when it is empty, against
+// the changelog. Markdown uses text/template and HTML uses html/template, so values
+// are escaped in HTML output. A template that writes too much or runs too long fails
+// with ErrChangelogTooLarge or ErrChangelogTimeout.
+func (c *Changelog) Render(format ChangelogFormat, source string) ([]byte, error) {
+	tmpl, err := parseChangelogTemplate(format, source)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &limitedBuffer{deadline: time.Now().Add(ChangelogRenderTimeout)}
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("changelog template failed: %v", r)
+			}
+		}()
+		done <- tmpl.Execute(out, c)
+	}()
+
+	// A template that loops without writing cannot be stopped, so it is left to
+	// finish on its own while the caller gets its answer
+	select {
+	case err := <-done:
+		if err != nil {
+			return nil, err
+		}
+		return out.Bytes(), nil
+	case <-time.After(ChangelogRenderTimeout):
+		return nil, ErrChangelogTimeout
+	}
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChangelogRenderLimits(t *testing.T) {
+	changelog := &Changelog{ProjectName: "P"}
+	tests := []struct {
+		name    string
+		format  ChangelogFormat
+		source  string
+		wantErr error
+	}{
+		{"markdown output too large", ChangelogMarkdown, `{{range 1000000000}}{{range 1000000000}}x{{end}}{{end}}`, ErrChangelogTooLarge},
+		{"html output too large", ChangelogHTML, `{{range 1000000000}}<p>{{.}}</p>{{end}}`, ErrChangelogTooLarge},
+		{"huge indent", ChangelogMarkdown, `{{range 100000}}{{indent 1000000000000}}{{end}}`, ErrChangelogTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := changelog.Render(tt.format, tt.source)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Render error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestChangelogRenderTimesOutWithoutOutput(t *testing.T) {
+	if testing.Short() {
+		t.Skip("waits for the render timeout")
+	}
+	start := time.Now()
+	_, err := (&Changelog{}).Render(ChangelogMarkdown, `{{range 1000000000}}{{range 1000000000}}{{end}}{{end}}`)
+	if !errors.Is(err, ErrChangelogTimeout) {
+		t.Fatalf("Render error = %v, want %v", err, ErrChangelogTimeout)
+	}
+	if elapsed := time.Since(start); elapsed > ChangelogRenderTimeout+time.Second {
+		t.Errorf("Render returned after %v", elapsed)
+	}
+}
+
+func TestChangelogRenderDefaults(t *testing.T) {
+	changelog := &Changelog{ProjectName: "Apollo <beta>"}
+	markdown, err := changelog.Render(ChangelogMarkdown, "")
+	if err != nil || !strings.Contains(string(markdown), "# Apollo <beta> changelog") {
+		t.Errorf("markdown = %q, %v", markdown, err)
+	}
+	html, err := changelog.Render(ChangelogHTML, "")
+	if err != nil || !strings.Contains(string(html), "Apollo &lt;beta&gt;") {
+		t.Errorf("html = %q, %v", html, err)
+	}
+}
+
+func TestCheckChangelogTemplate(t *testing.T) {
+	if err := CheckChangelogTemplate(ChangelogMarkdown, "{{.ProjectName}}"); err != nil {
+		t.Errorf("valid template rejected: %v", err)
+	}
+	if err := CheckChangelogTemplate(ChangelogHTML, "{{.ProjectName"); err == nil {
+		t.Error("template that does not parse was accepted")
+	}
+	if err := CheckChangelogTemplate(ChangelogMarkdown, strings.Repeat("x", MaxChangelogTemplate+1)); err == nil {
+		t.Error("oversized template was accepted")
+	}
+}
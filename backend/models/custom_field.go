@@ -0,0 +1,82 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+type CustomFieldType string
+
+const (
+	FieldText         CustomFieldType = "text"
+	FieldNumber       CustomFieldType = "number"
+	FieldDate         CustomFieldType = "date"
+	FieldSingleSelect CustomFieldType = "single_select"
+	FieldMultiSelect  CustomFieldType = "multi_select"
+	FieldUser         CustomFieldType = "user"
+)
+
+// StringList is a list of strings stored as a JSON array
+type StringList []string
+
+func (l StringList) Value() (driver.Value, error) {
+	if l == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal([]string(l))
+	return string(data), err
+}
+
+func (l *StringList) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*l = StringList{}
+		return nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return errors.New("unsupported type for StringList")
+	}
+	return json.Unmarshal(data, (*[]string)(l))
+}
+
+// CustomFieldRules are the optional validation rules of a custom field. MaxLength and
+// Pattern apply to text fields, Min and Max to number fields.
+type CustomFieldRules struct {
+	MaxLength *int     `json:"max_length,omitempty"`
+	Pattern   string   `gorm:"type:varchar(255)" json:"pattern,omitempty"`
+	Min       *float64 `json:"min,omitempty"`
+	Max       *float64 `json:"max,omitempty"`
+}
+
+// CustomField defines an extra piece of metadata the features of a project can carry
+type CustomField struct {
+	ID        uint             `gorm:"primaryKey" json:"id"`
+	ProjectID int              `gorm:"not null;index;uniqueIndex:idx_custom_field_key" json:"project_id"`
+	Key       string           `gorm:"type:varchar(50);not null;uniqueIndex:idx_custom_field_key" json:"key"`
+	Name      string           `gorm:"type:varchar(100);not null" json:"name"`
+	Type      CustomFieldType  `gorm:"type:varchar(20);not null" json:"type"`
+	Required  bool             `gorm:"not null;default:false" json:"required"`
+	Options   StringList       `gorm:"type:text" json:"options"`
+	Rules     CustomFieldRules `gorm:"embedded;embeddedPrefix:rule_" json:"rules"`
+	Position  int              `gorm:"not null;default:0" json:"position"`
+	CreatedAt time.Time        `json:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at"`
+}
+
+// CustomFieldValue is the value one feature has for one custom field. Value holds the
+// JSON the client sees; TextValue and NumberValue hold a copy for filtering and sorting.
+type CustomFieldValue struct {
+	ID          uint     `gorm:"primaryKey"`
+	FeatureID   uint     `gorm:"not null;uniqueIndex:idx_custom_field_value"`
+	FieldID     uint     `gorm:"not null;index;uniqueIndex:idx_custom_field_value"`
+	FieldKey    string   `gorm:"type:varchar(50);not null"`
+	Value       string   `gorm:"type:text;not null"`
+	TextValue   string   `gorm:"type:text;index"`
+	NumberValue *float64 `gorm:"index"`
+}
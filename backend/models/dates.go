@@ -0,0 +1,87 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// DateLayout is the format of every calendar date stored as a string
+const DateLayout = "2006-01-02"
+
+// DateWindow is an optional start and due date. Dates are YYYY-MM-DD strings, so they
+// compare correctly as plain strings.
+type DateWindow struct {
+	Start *string
+	Due   *string
+}
+
+// Validate checks the format of both dates and that the start is not after the due date
+func (w DateWindow) Validate() error {
+	if err := validateDate("start_date", w.Start); err != nil {
+		return err
+	}
+	if err := validateDate("due_date", w.Due); err != nil {
+		return err
+	}
+	if w.Start != nil && w.Due != nil && *w.Start > *w.Due {
+		return fmt.Errorf("start_date %s is after due_date %s", *w.Start, *w.Due)
+	}
+	return nil
+}
+
+func validateDate(name string, value *string) error {
+	if value == nil {
+		return nil
+	}
+	if _, err := time.Parse(DateLayout, *value); err != nil {
+		return fmt.Errorf("%s must be a date in YYYY-MM-DD format", name)
+	}
+	return nil
+}
+
+// Outside reports how inner falls outside the window, or "" if it fits. Open ends on
+// either side are not checked.
+func (w DateWindow) Outside(inner DateWindow) string {
+	for _, date := range []*string{inner.Start, inner.Due} {
+		if date == nil {
+			continue
+		}
+		if w.Start != nil && *date < *w.Start {
+			return fmt.Sprintf("%s is before the start date %s", *date, *w.Start)
+		}
+		if w.Due != nil && *date > *w.Due {
+			return fmt.Sprintf("%s is after the due date %s", *date, *w.Due)
+		}
+	}
+	return ""
+}
+
+// DueItems are the features and tasks in a due date view
+type DueItems struct {
+	Features []Feature `json:"features"`
+	Tasks    []Task    `json:"tasks"`
+}
+
+// Today is the current calendar date in the server's time zone
+func Today() string {
+	return time.Now().Format(DateLayout)
+}
+
+// EndOfWeek is the Sunday closing the week that contains the given date
+func EndOfWeek(date string) string {
+	day, err := time.Parse(DateLayout, date)
+	if err != nil {
+		return date
+	}
+	return day.AddDate(0, 0, (7-int(day.Weekday()))%7).Format(DateLayout)
+}
+
+// Window returns the feature's start and due dates
+func (f *Feature) Window() DateWindow {
+	return DateWindow{Start: f.StartDate, Due: f.DueDate}
+}
+
+// Window returns the task's start and due dates
+func (t *Task) Window() DateWindow {
+	return DateWindow{Start: t.StartDate, Due: t.DueDate}
+}
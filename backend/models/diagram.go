@@ -0,0 +1,320 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+type DiagramFormat string
+
+const (
+	DiagramDOT      DiagramFormat = "dot"
+	DiagramMermaid  DiagramFormat = "mermaid"
+	DiagramPlantUML DiagramFormat = "plantuml"
+)
+
+type DiagramNodeKind string
+
+const (
+	DiagramProject DiagramNodeKind = "project"
+	DiagramFeature DiagramNodeKind = "feature"
+	DiagramTask    DiagramNodeKind = "task"
+)
+
+// DiagramOptions controls which parts of a project a diagram shows
+type DiagramOptions struct {
+	// MaxDepth limits the levels drawn below the project, with tasks counting as a
+	// level below their feature. Zero means unlimited.
+	MaxDepth int
+	// CollapseDone draws a done feature whose whole subtree is done as a single node
+	CollapseDone bool
+	// Tag keeps only features with this tag, plus their ancestors
+	Tag          string
+	IncludeTasks bool
+}
+
+// DiagramNode is one box in a diagram. Category and Priority only apply to features.
+type DiagramNode struct {
+	ID       string
+	Kind     DiagramNodeKind
+	Lines    []string
+	Category StatusCategory
+	Priority FeaturePriority
+}
+
+// DiagramEdge connects a node to one of its children
+type DiagramEdge struct {
+	From string
+	To   string
+	Kind DiagramNodeKind
+}
+
+// Diagram is a project's hierarchy laid out as nodes and edges, ready to render
+type Diagram struct {
+	Nodes []DiagramNode
+	Edges []DiagramEdge
+}
+
+// Colours are plain colour names, which DOT, Mermaid and PlantUML all understand
+var diagramFill = map[StatusCategory]string{
+	CategoryNotStarted: "whitesmoke",
+	CategoryActive:     "lightblue",
+	CategoryDone:       "palegreen",
+}
+
+var diagramStroke = map[FeaturePriority]string{
+	PriorityLow:    "gray",
+	PriorityMedium: "darkorange",
+	PriorityHigh:   "red",
+}
+
+// BuildDiagram lays out the project's features below the project, and each feature's
+// tasks below it
+func BuildDiagram(project Project, features []Feature, tasks []Task, workflow *Workflow, options DiagramOptions) *Diagram {
+	if options.Tag != "" {
+		features = filterFeaturesByTag(features, options.Tag)
+	}
+
+	tasksByFeature := map[uint][]Task{}
+	if options.IncludeTasks {
+		for _, task := range tasks {
+			tasksByFeature[task.FeatureID] = append(tasksByFeature[task.FeatureID], task)
+		}
+	}
+
+	diagram := &Diagram{Nodes: []DiagramNode{{ID: "project", Kind: DiagramProject, Lines: []string{project.Name}}}}
+	builder := diagramBuilder{diagram: diagram, tasks: tasksByFeature, workflow: workflow, options: options}
+	for _, root := range BuildFeatureTree(features, 0) {
+		builder.addFeature(root, "project", 1)
+	}
+	return diagram
+}
+
+// filterFeaturesByTag keeps the features carrying the tag and every ancestor of them,
+// so the kept features stay connected to the project
+func filterFeaturesByTag(features []Feature, tag string) []Feature {
+	byID := make(map[uint]*Feature, len(features))
+	for i := range features {
+		byID[features[i].ID] = &features[i]
+	}
+
+	keep := map[uint]bool{}
+	for _, feature := range features {
+		for _, featureTag := range feature.Tags {
+			if !strings.EqualFold(featureTag.TagName, tag) {
+				continue
+			}
+			for current := byID[feature.ID]; current != nil && !keep[current.ID]; {
+				keep[current.ID] = true
+				if current.ParentFeatureID == nil {
+					break
+				}
+				current = byID[*current.ParentFeatureID]
+			}
+			break
+		}
+	}
+
+	filtered := []Feature{}
+	for _, feature := range features {
+		if keep[feature.ID] {
+			filtered = append(filtered, feature)
+		}
+	}
+	return filtered
+}
+
+type diagramBuilder struct {
+	diagram  *Diagram
+	tasks    map[uint][]Task
+	workflow *Workflow
+	options  DiagramOptions
+}
+
+func (b *diagramBuilder) addFeature(node *FeatureTreeNode, parentID string, depth int) {
+	statusName := string(node.Status)
+	category := CategoryNotStarted
+	if status, ok := b.workflow.Status(node.Status); ok {
+		statusName = status.Name
+		category = status.Category
+	}
+
+	id := fmt.Sprintf("f%d", node.ID)
+	lines := []string{node.Title, fmt.Sprintf("%s · %s", statusName, node.Priority)}
+	tasks := b.tasks[node.ID]
+
+	expand := true
+	if b.options.CollapseDone && (len(node.Children) > 0 || len(tasks) > 0) && b.subtreeDone(node) {
+		lines = append(lines, fmt.Sprintf("+%d done below", countDescendants(node)))
+		expand = false
+	} else if b.options.MaxDepth > 0 && depth >= b.options.MaxDepth {
+		if hidden := len(node.Children) + len(tasks); hidden > 0 {
+			lines = append(lines, fmt.Sprintf("+%d hidden", hidden))
+		}
+		expand = false
+	}
+
+	b.diagram.Nodes = append(b.diagram.Nodes, DiagramNode{ID: id, Kind: DiagramFeature, Lines: lines, Category: category, Priority: node.Priority})
+	b.diagram.Edges = append(b.diagram.Edges, DiagramEdge{From: parentID, To: id, Kind: DiagramFeature})
+	if !expand {
+		return
+	}
+
+	for _, task := range tasks {
+		taskID := fmt.Sprintf("t%d", task.ID)
+		b.diagram.Nodes = append(b.diagram.Nodes, DiagramNode{ID: taskID, Kind: DiagramTask, Lines: []string{task.TaskName, task.TaskType}})
+		b.diagram.Edges = append(b.diagram.Edges, DiagramEdge{From: id, To: taskID, Kind: DiagramTask})
+	}
+	for _, child := range node.Children {
+		b.addFeature(child, id, depth+1)
+	}
+}
+
+// subtreeDone reports whether the feature and everything below it is done. Tasks have
+// no status, so they do not keep a branch open.
+func (b *diagramBuilder) subtreeDone(node *FeatureTreeNode) bool {
+	if !b.workflow.IsDone(node.Status) {
+		return false
+	}
+	for _, child := range node.Children {
+		if !b.subtreeDone(child) {
+			return false
+		}
+	}
+	return true
+}
+
+func countDescendants(node *FeatureTreeNode) int {
+	count := len(node.Children)
+	for _, child := range node.Children {
+		count += countDescendants(child)
+	}
+	return count
+}
+
+// Render writes the diagram in the given format; ok is false for unknown formats
+func (d *Diagram) Render(format DiagramFormat) (output string, ok bool) {
+	switch format {
+	case DiagramDOT:
+		return d.renderDOT(), true
+	case DiagramMermaid:
+		return d.renderMermaid(), true
+	case DiagramPlantUML:
+		return d.renderPlantUML(), true
+	}
+	return "", false
+}
+
+func (d *Diagram) renderDOT() string {
+	var out strings.Builder
+	out.WriteString("digraph project {\n")
+	out.WriteString("  rankdir=TB;\n")
+	out.WriteString("  node [shape=box, style=\"rounded,filled\", fontname=\"Helvetica\"];\n")
+
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	for _, node := range d.Nodes {
+		label := escape.Replace(strings.Join(node.Lines, "\n"))
+		switch node.Kind {
+		case DiagramProject:
+			fmt.Fprintf(&out, "  %q [label=\"%s\", shape=folder, fillcolor=\"lavender\"];\n", node.ID, label)
+		case DiagramTask:
+			fmt.Fprintf(&out, "  %q [label=\"%s\", shape=note, style=filled, fillcolor=\"lightyellow\"];\n", node.ID, label)
+		default:
+			fmt.Fprintf(&out, "  %q [label=\"%s\", fillcolor=%q, color=%q, penwidth=%d];\n",
+				node.ID, label, diagramFill[node.Category], diagramStroke[node.Priority], priorityRank[node.Priority])
+		}
+	}
+	for _, edge := range d.Edges {
+		if edge.Kind == DiagramTask {
+			fmt.Fprintf(&out, "  %q -> %q [style=dashed];\n", edge.From, edge.To)
+		} else {
+			fmt.Fprintf(&out, "  %q -> %q;\n", edge.From, edge.To)
+		}
+	}
+	out.WriteString("}\n")
+	return out.String()
+}
+
+func (d *Diagram) renderMermaid() string {
+	var out strings.Builder
+	out.WriteString("flowchart TD\n")
+
+	// # goes first, since the other replacements introduce it
+	escape := strings.NewReplacer("#", "#35;", `"`, "#quot;", "<", "#lt;", ">", "#gt;")
+	classes := []string{}
+	seen := map[string]bool{}
+	for _, node := range d.Nodes {
+		lines := make([]string, len(node.Lines))
+		for i, line := range node.Lines {
+			lines[i] = escape.Replace(line)
+		}
+		label := strings.Join(lines, "<br/>")
+
+		class := string(node.Kind)
+		style := ""
+		switch node.Kind {
+		case DiagramProject:
+			style = "fill:lavender,stroke:slateblue"
+		case DiagramTask:
+			style = "fill:lightyellow,stroke:gray,stroke-dasharray:4 2"
+		default:
+			class = fmt.Sprintf("%s_%s", node.Category, node.Priority)
+			style = fmt.Sprintf("fill:%s,stroke:%s,stroke-width:%dpx", diagramFill[node.Category], diagramStroke[node.Priority], priorityRank[node.Priority])
+		}
+		if !seen[class] {
+			seen[class] = true
+			classes = append(classes, fmt.Sprintf("  classDef %s %s\n", class, style))
+		}
+
+		fmt.Fprintf(&out, "  %s[\"%s\"]:::%s\n", node.ID, label, class)
+	}
+	for _, edge := range d.Edges {
+		if edge.Kind == DiagramTask {
+			fmt.Fprintf(&out, "  %s -.-> %s\n", edge.From, edge.To)
+		} else {
+			fmt.Fprintf(&out, "  %s --> %s\n", edge.From, edge.To)
+		}
+	}
+	for _, class := range classes {
+		out.WriteString(class)
+	}
+	return out.String()
+}
+
+func (d *Diagram) renderPlantUML() string {
+	var out strings.Builder
+	out.WriteString("@startuml\n")
+	out.WriteString("skinparam defaultFontName Helvetica\n")
+
+	// PlantUML has no escape for a double quote inside a quoted label
+	escape := strings.NewReplacer(`\`, `\\`, `"`, "''")
+	for _, node := range d.Nodes {
+		lines := make([]string, len(node.Lines))
+		for i, line := range node.Lines {
+			lines[i] = escape.Replace(line)
+		}
+		label := strings.Join(lines, `\n`)
+
+		switch node.Kind {
+		case DiagramProject:
+			fmt.Fprintf(&out, "folder \"%s\" as %s #lavender\n", label, node.ID)
+		case DiagramTask:
+			fmt.Fprintf(&out, "card \"%s\" as %s #lightyellow\n", label, node.ID)
+		default:
+			style := fmt.Sprintf("#%s;line:%s", diagramFill[node.Category], diagramStroke[node.Priority])
+			if node.Priority == PriorityHigh {
+				style += ";line.bold"
+			}
+			fmt.Fprintf(&out, "rectangle \"%s\" as %s %s\n", label, node.ID, style)
+		}
+	}
+	for _, edge := range d.Edges {
+		if edge.Kind == DiagramTask {
+			fmt.Fprintf(&out, "%s ..> %s\n", edge.From, edge.To)
+		} else {
+			fmt.Fprintf(&out, "%s --> %s\n", edge.From, edge.To)
+		}
+	}
+	out.WriteString("@enduml\n")
+	return out.String()
+}
@@ -0,0 +1,146 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// EstimateUnit is what a project's estimates count
+type EstimateUnit string
+
+const (
+	EstimatePoints EstimateUnit = "points"
+	EstimateHours  EstimateUnit = "hours"
+)
+
+// EstimateScale lists the values an estimate may take in a project
+type EstimateScale string
+
+const (
+	ScaleFibonacci EstimateScale = "fibonacci"
+	ScaleTShirt    EstimateScale = "tshirt"
+	ScaleFree      EstimateScale = "free"
+)
+
+// fibonacciValues are the estimates allowed on the fibonacci scale
+var fibonacciValues = []float64{0, 1, 2, 3, 5, 8, 13, 21, 34, 55, 89}
+
+// TShirtSizes maps t-shirt sizes to the number stored for them
+var TShirtSizes = map[string]float64{"XS": 1, "S": 2, "M": 3, "L": 5, "XL": 8, "XXL": 13}
+
+func IsValidEstimateUnit(unit EstimateUnit) bool {
+	return unit == EstimatePoints || unit == EstimateHours
+}
+
+func IsValidEstimateScale(scale EstimateScale) bool {
+	return scale == ScaleFibonacci || scale == ScaleTShirt || scale == ScaleFree
+}
+
+// Estimate is an amount of effort in the project's unit. In JSON it is a number, or
+// a t-shirt size, which is stored as the number it maps to.
+type Estimate float64
+
+func (e *Estimate) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(`"`)) {
+		var size string
+		if err := json.Unmarshal(data, &size); err != nil {
+			return err
+		}
+		value, ok := TShirtSizes[strings.ToUpper(size)]
+		if !ok {
+			return fmt.Errorf("unknown estimate size %q", size)
+		}
+		*e = Estimate(value)
+		return nil
+	}
+
+	var value float64
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("estimate must be a number or a t-shirt size")
+	}
+	*e = Estimate(value)
+	return nil
+}
+
+// Check reports why the estimate is not allowed on the scale, or "" if it is
+func (s EstimateScale) Check(estimate Estimate) string {
+	value := float64(estimate)
+	if value < 0 || math.IsNaN(value) || math.IsInf(value, 0) {
+		return "estimate must not be negative"
+	}
+	switch s {
+	case ScaleFibonacci:
+		for _, allowed := range fibonacciValues {
+			if value == allowed {
+				return ""
+			}
+		}
+		return "estimate must be one of 0, 1, 2, 3, 5, 8, 13, 21, 34, 55, 89"
+	case ScaleTShirt:
+		for _, allowed := range TShirtSizes {
+			if value == allowed {
+				return ""
+			}
+		}
+		return "estimate must be a t-shirt size: XS, S, M, L, XL or XXL"
+	}
+	return ""
+}
+
+// Estimates are the effort fields shared by features and tasks. Remaining falls back
+// to the estimate while it has not been set.
+type Estimates struct {
+	Estimate          *Estimate
+	RemainingEstimate *Estimate
+}
+
+// Check validates the estimate against the scale. Remaining effort burns down freely,
+// so it only has to be a non-negative number.
+func (e Estimates) Check(scale EstimateScale) string {
+	if e.Estimate != nil {
+		if reason := scale.Check(*e.Estimate); reason != "" {
+			return reason
+		}
+	}
+	if e.RemainingEstimate != nil && (*e.RemainingEstimate < 0 || math.IsNaN(float64(*e.RemainingEstimate))) {
+		return "remaining_estimate must not be negative"
+	}
+	return ""
+}
+
+// Remaining is the effort still left, which is the estimate until remaining is set
+func (e Estimates) Remaining() float64 {
+	if e.RemainingEstimate != nil {
+		return float64(*e.RemainingEstimate)
+	}
+	if e.Estimate != nil {
+		return float64(*e.Estimate)
+	}
+	return 0
+}
+
+// EstimateTotals add up the estimates in a roll-up. Done features count as having no
+// remaining effort, and PercentDone weighs each feature by its estimate.
+type EstimateTotals struct {
+	Estimate         float64 `json:"estimate"`
+	Remaining        float64 `json:"remaining"`
+	DoneEstimate     float64 `json:"done_estimate"`
+	PercentDone      float64 `json:"percent_done"`
+	UnestimatedCount int     `json:"unestimated_count"`
+	TaskEstimate     float64 `json:"task_estimate"`
+	TaskRemaining    float64 `json:"task_remaining"`
+	UnestimatedTasks int     `json:"unestimated_tasks"`
+}
+
+// Estimates returns the feature's estimate fields
+func (f *Feature) Estimates() Estimates {
+	return Estimates{Estimate: f.Estimate, RemainingEstimate: f.RemainingEstimate}
+}
+
+// Estimates returns the task's estimate fields
+func (t *Task) Estimates() Estimates {
+	return Estimates{Estimate: t.Estimate, RemainingEstimate: t.RemainingEstimate}
+}
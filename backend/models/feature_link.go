@@ -0,0 +1,89 @@
+package models
+
+import "time"
+
+type LinkType string
+
+// Stored link types, read as "source <type> target"
+const (
+	LinkBlocks     LinkType = "blocks"
+	LinkRelatesTo  LinkType = "relates_to"
+	LinkDuplicates LinkType = "duplicates"
+	LinkParentOf   LinkType = "parent_of"
+)
+
+// Inverse names, accepted on input and shown when a link is seen from its target
+const (
+	LinkBlockedBy    LinkType = "blocked_by"
+	LinkDuplicatedBy LinkType = "duplicated_by"
+	LinkChildOf      LinkType = "child_of"
+)
+
+// inverseLinkTypes maps each stored type to how it reads from the target's side
+var inverseLinkTypes = map[LinkType]LinkType{
+	LinkBlocks:     LinkBlockedBy,
+	LinkRelatesTo:  LinkRelatesTo,
+	LinkDuplicates: LinkDuplicatedBy,
+	LinkParentOf:   LinkChildOf,
+}
+
+// FeatureLink is a typed relation between two features, possibly in different projects
+type FeatureLink struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	SourceID      uint      `gorm:"not null;index;uniqueIndex:idx_feature_link" json:"source_id"`
+	TargetID      uint      `gorm:"not null;index;uniqueIndex:idx_feature_link" json:"target_id"`
+	Type          LinkType  `gorm:"type:varchar(20);not null;uniqueIndex:idx_feature_link" json:"type"`
+	CreatedByUser uint      `json:"created_by_user"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// LinkedFeature is the feature on the other end of a link
+type LinkedFeature struct {
+	ID        uint          `json:"id"`
+	ProjectID int           `json:"project_id"`
+	Title     string        `json:"title"`
+	Status    FeatureStatus `json:"status"`
+}
+
+// FeatureLinkView is a link as seen from one of its features
+type FeatureLinkView struct {
+	ID        uint          `json:"id"`
+	Type      LinkType      `json:"type"`
+	Feature   LinkedFeature `json:"feature"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+// NormalizeLinkType turns any accepted link name into the stored type. swap reports
+// whether the name was an inverse, in which case source and target trade places.
+func NormalizeLinkType(name LinkType) (linkType LinkType, swap bool, ok bool) {
+	if _, stored := inverseLinkTypes[name]; stored {
+		return name, false, true
+	}
+	for stored, inverse := range inverseLinkTypes {
+		if inverse == name {
+			return stored, true, true
+		}
+	}
+	return "", false, false
+}
+
+// ViewFrom describes the link from featureID's side
+func (l FeatureLink) ViewFrom(featureID uint, other LinkedFeature) FeatureLinkView {
+	linkType := l.Type
+	if l.TargetID == featureID && l.SourceID != featureID {
+		linkType = inverseLinkTypes[l.Type]
+	}
+	return FeatureLinkView{ID: l.ID, Type: linkType, Feature: other, CreatedAt: l.CreatedAt}
+}
+
+// DependencyGraph is the set of linked features around a project
+type DependencyGraph struct {
+	Nodes []DependencyNode `json:"nodes"`
+	Edges []FeatureLink    `json:"edges"`
+}
+
+// DependencyNode is one feature in a dependency graph
+type DependencyNode struct {
+	LinkedFeature
+	Blocked bool `json:"blocked"`
+}
@@ -0,0 +1,48 @@
+package models
+
+// FeatureTreeNode is a feature with its children nested below it
+type FeatureTreeNode struct {
+	Feature
+	ChildCount int                `json:"child_count"`
+	Children   []*FeatureTreeNode `json:"children"`
+}
+
+// BuildFeatureTree nests a flat list of features by ParentFeatureID. Features whose
+// parent is not in the list become roots. A maxDepth above zero stops nesting after
+// that many levels; ChildCount still reports how many children a node has.
+func BuildFeatureTree(features []Feature, maxDepth int) []*FeatureTreeNode {
+	nodes := make(map[uint]*FeatureTreeNode, len(features))
+	for i := range features {
+		nodes[features[i].ID] = &FeatureTreeNode{Feature: features[i], Children: []*FeatureTreeNode{}}
+	}
+
+	roots := []*FeatureTreeNode{}
+	for i := range features {
+		node := nodes[features[i].ID]
+		if features[i].ParentFeatureID != nil {
+			if parent, ok := nodes[*features[i].ParentFeatureID]; ok && parent != node {
+				parent.Children = append(parent.Children, node)
+				parent.ChildCount++
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+
+	if maxDepth > 0 {
+		for _, root := range roots {
+			pruneFeatureTree(root, 1, maxDepth)
+		}
+	}
+	return roots
+}
+
+func pruneFeatureTree(node *FeatureTreeNode, depth int, maxDepth int) {
+	if depth >= maxDepth {
+		node.Children = []*FeatureTreeNode{}
+		return
+	}
+	for _, child := range node.Children {
+		pruneFeatureTree(child, depth+1, maxDepth)
+	}
+}
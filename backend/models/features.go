@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"gorm.io/gorm"
@@ -23,18 +24,77 @@ type Feature struct {
 	ID              uint            `gorm:"primaryKey" json:"id"`
 	ProjectID       int             `gorm:"not null;index" json:"project_id"`
 	ParentFeatureID *uint           `gorm:"index" json:"parent_feature_id"`
+	MilestoneID     *uint           `gorm:"index" json:"milestone_id"`
 	Title           string          `gorm:"type:varchar(255);not null" json:"title"`
 	Description     string          `gorm:"type:text" json:"description"`
 	Status          FeatureStatus   `gorm:"type:varchar(50);not null;default:'todo'" json:"status"`
 	Priority        FeaturePriority `gorm:"type:varchar(50);not null;default:'medium'" json:"priority"`
 	AssigneeID      uint            `gorm:"default:0" json:"assignee_id"`
-	CreatedAt       time.Time       `json:"created_at"`
-	UpdatedAt       time.Time       `json:"updated_at"`
-	DeletedAt       gorm.DeletedAt  `gorm:"index" json:"-"`
+	StartDate       *string         `gorm:"type:varchar(10)" json:"start_date"` // YYYY-MM-DD
+	DueDate         *string         `gorm:"type:varchar(10);index" json:"due_date"`
+
+	// SprintID only changes through the sprint endpoints, which track scope changes
+	SprintID *uint `gorm:"index" json:"sprint_id"`
+
+	// BoardRank orders the feature within its status column and only changes through
+	// the board endpoints. Empty ranks sort after all others.
+	BoardRank string `gorm:"type:varchar(255);not null;default:''" json:"board_rank"`
+
+	// BacklogRank is the feature's place in the project's backlog and only changes
+	// through the backlog endpoints. Empty ranks sort after all others.
+	BacklogRank string `gorm:"type:varchar(255);not null;default:''" json:"backlog_rank"`
+
+	// Estimates are in the project's estimate unit and must fit its scale
+	Estimate          *Estimate `json:"estimate"`
+	RemainingEstimate *Estimate `json:"remaining_estimate"`
+
+	// ScoringInputs feed the score of the project's scoring framework
+	ScoringInputs `gorm:"embedded"`
+
+	Version   uint           `gorm:"not null;default:1" json:"version"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// Overdue is maintained by the overdue job and is not covered by the version
+	Overdue      bool       `gorm:"not null;default:false" json:"overdue"`
+	OverdueSince *time.Time `json:"overdue_since"`
+
+	// LegacySubFeatureID is the feature's ID on the legacy sub-feature routes: the
+	// sub_features row it was migrated from, or one handed out when it was first served there
+	LegacySubFeatureID *uint `gorm:"uniqueIndex:idx_features_legacy_sub_feature" json:"-"`
 
 	// Associations
 	Project       Project      `gorm:"foreignKey:ProjectID" json:"-"`
 	ParentFeature *Feature     `gorm:"foreignKey:ParentFeatureID" json:"parent_feature,omitempty"`
 	Assignee      User         `gorm:"foreignKey:AssigneeID" json:"assignee"`
 	Tags          []FeatureTag `gorm:"foreignKey:FeatureID" json:"tags"`
+
+	// CustomFieldValues are exposed to clients through CustomFields, keyed by field
+	CustomFieldValues []CustomFieldValue         `gorm:"foreignKey:FeatureID" json:"-"`
+	CustomFields      map[string]json.RawMessage `gorm:"-" json:"custom_fields,omitempty"`
+
+	// Rollup, Score, Blocked and OpenBlockers are computed on read and never stored
+	Rollup       *Rollup  `gorm:"-" json:"rollup,omitempty"`
+	Score        *float64 `gorm:"-" json:"score,omitempty"`
+	Blocked      *bool    `gorm:"-" json:"blocked,omitempty"`
+	OpenBlockers []uint   `gorm:"-" json:"open_blockers,omitempty"`
+}
+
+// BeforeCreate starts every new feature at version 1
+func (f *Feature) BeforeCreate(tx *gorm.DB) (err error) {
+	f.Version = 1
+	return
+}
+
+// AfterFind exposes preloaded custom field values as CustomFields
+func (f *Feature) AfterFind(tx *gorm.DB) (err error) {
+	if len(f.CustomFieldValues) == 0 {
+		return
+	}
+	f.CustomFields = make(map[string]json.RawMessage, len(f.CustomFieldValues))
+	for _, value := range f.CustomFieldValues {
+		f.CustomFields[value.FieldKey] = json.RawMessage(value.Value)
+	}
+	return
 }
@@ -0,0 +1,105 @@
+package models
+
+import "time"
+
+type FeedbackVisibility string
+
+const (
+	FeedbackPublic   FeedbackVisibility = "public"
+	FeedbackInternal FeedbackVisibility = "internal"
+)
+
+func IsValidFeedbackVisibility(visibility FeedbackVisibility) bool {
+	return visibility == FeedbackPublic || visibility == FeedbackInternal
+}
+
+type FeedbackStatus string
+
+const (
+	FeedbackOpen     FeedbackStatus = "open"
+	FeedbackDeclined FeedbackStatus = "declined"
+	FeedbackMerged   FeedbackStatus = "merged"
+	FeedbackShipped  FeedbackStatus = "shipped"
+)
+
+// FeedbackRequest is something stakeholders ask for. Public requests may be shown to
+// customers, internal ones only to the team. A request is merged into another when it
+// turns out to be a duplicate, and ships when its linked feature reaches done.
+type FeedbackRequest struct {
+	ID           uint               `gorm:"primaryKey" json:"id"`
+	ProjectID    int                `gorm:"not null;index" json:"project_id"`
+	Title        string             `gorm:"size:255;not null" json:"title"`
+	Description  string             `gorm:"type:text" json:"description"`
+	Visibility   FeedbackVisibility `gorm:"type:varchar(10);not null;default:'public'" json:"visibility"`
+	Status       FeedbackStatus     `gorm:"type:varchar(10);not null;default:'open';index" json:"status"`
+	FeatureID    *uint              `gorm:"index" json:"feature_id"`
+	MergedIntoID *uint              `gorm:"index" json:"merged_into_id"`
+	VoteCount    int                `gorm:"not null;default:0" json:"vote_count"`
+	CreatedBy    uint               `gorm:"not null" json:"created_by"`
+	ShippedAt    *time.Time         `json:"shipped_at"`
+	CreatedAt    time.Time          `json:"created_at"`
+	UpdatedAt    time.Time          `json:"updated_at"`
+}
+
+// PublicFeedbackRequest is a public request as the portal shows it, without who
+// created it or which feature it is linked to. MergedIntoID is only set when the
+// request it was merged into is public as well.
+type PublicFeedbackRequest struct {
+	ID           uint           `json:"id"`
+	ProjectID    int            `json:"project_id"`
+	Title        string         `json:"title"`
+	Description  string         `json:"description"`
+	Status       FeedbackStatus `json:"status"`
+	MergedIntoID *uint          `json:"merged_into_id"`
+	VoteCount    int            `json:"vote_count"`
+	ShippedAt    *time.Time     `json:"shipped_at"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+}
+
+// NewPublicFeedbackRequest converts a public request into its portal shape
+func NewPublicFeedbackRequest(request FeedbackRequest) PublicFeedbackRequest {
+	return PublicFeedbackRequest{
+		ID:          request.ID,
+		ProjectID:   request.ProjectID,
+		Title:       request.Title,
+		Description: request.Description,
+		Status:      request.Status,
+		VoteCount:   request.VoteCount,
+		ShippedAt:   request.ShippedAt,
+		CreatedAt:   request.CreatedAt,
+		UpdatedAt:   request.UpdatedAt,
+	}
+}
+
+// FeedbackVote is an upvote on a request. Team members vote for themselves, or on
+// behalf of a customer by giving the customer's details. A user votes once per
+// request for themselves and once for each customer email.
+type FeedbackVote struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	RequestID     uint      `gorm:"not null;uniqueIndex:idx_feedback_voter" json:"request_id"`
+	UserID        uint      `gorm:"not null;uniqueIndex:idx_feedback_voter" json:"user_id"`
+	CustomerEmail string    `gorm:"size:255;not null;default:'';uniqueIndex:idx_feedback_voter" json:"customer_email"`
+	CustomerName  string    `gorm:"size:255" json:"customer_name"`
+	Organisation  string    `gorm:"size:255;index" json:"organisation"`
+	CreatedAt     time.Time `json:"created_at"`
+
+	// Username is filled in when votes are listed and never stored
+	Username string `gorm:"-" json:"username,omitempty"`
+}
+
+// FeedbackNotification tells a voter that a request they voted for has shipped. It
+// goes to the user who cast the vote, with the customer's details when the vote was
+// on a customer's behalf so the user can pass the news on.
+type FeedbackNotification struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	UserID        uint       `gorm:"not null;index" json:"user_id"`
+	RequestID     uint       `gorm:"not null;index" json:"request_id"`
+	FeatureID     uint       `gorm:"not null" json:"feature_id"`
+	CustomerEmail string     `gorm:"size:255" json:"customer_email"`
+	CustomerName  string     `gorm:"size:255" json:"customer_name"`
+	Organisation  string     `gorm:"size:255" json:"organisation"`
+	Message       string     `gorm:"type:text;not null" json:"message"`
+	ReadAt        *time.Time `json:"read_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
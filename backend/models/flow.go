@@ -0,0 +1,240 @@
+package models
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Percentiles summarises durations in days using the nearest-rank method. The
+// percentiles are nil when there is nothing to measure.
+type Percentiles struct {
+	Count int      `json:"count"`
+	P50   *float64 `json:"p50"`
+	P75   *float64 `json:"p75"`
+	P85   *float64 `json:"p85"`
+	P95   *float64 `json:"p95"`
+}
+
+// NewPercentiles computes the percentiles of a list of durations
+func NewPercentiles(durations []time.Duration) Percentiles {
+	result := Percentiles{Count: len(durations)}
+	if len(durations) == 0 {
+		return result
+	}
+
+	days := make([]float64, len(durations))
+	for i, duration := range durations {
+		days[i] = duration.Hours() / 24
+	}
+	sort.Float64s(days)
+
+	rank := func(p float64) *float64 {
+		index := int(math.Ceil(p/100*float64(len(days)))) - 1
+		if index < 0 {
+			index = 0
+		}
+		value := math.Round(days[index]*100) / 100
+		return &value
+	}
+	result.P50, result.P75, result.P85, result.P95 = rank(50), rank(75), rank(85), rank(95)
+	return result
+}
+
+// ThroughputWeek counts the features completed in the week starting on Week, a Monday
+type ThroughputWeek struct {
+	Week  string `json:"week"`
+	Count int    `json:"count"`
+}
+
+// FlowMetrics measures how work moves through a project. Lead time runs from creation
+// to done and cycle time from the first active status to done, both for features
+// completed in the window. WIP age is how long open active features have been active.
+type FlowMetrics struct {
+	LeadTime   Percentiles      `json:"lead_time"`
+	CycleTime  Percentiles      `json:"cycle_time"`
+	Throughput []ThroughputWeek `json:"throughput"`
+	WIPAge     Percentiles      `json:"wip_age"`
+}
+
+// FlowGroup holds the metrics of the features sharing a priority, tag or assignee.
+// Key is empty for untagged or unassigned features.
+type FlowGroup struct {
+	Key  string `json:"key"`
+	Name string `json:"name"`
+	FlowMetrics
+}
+
+// FlowReport is a project's flow metrics overall and broken down by priority, tag and
+// assignee
+type FlowReport struct {
+	ProjectID int       `json:"project_id"`
+	Since     time.Time `json:"since"`
+	Until     time.Time `json:"until"`
+	FlowMetrics
+	ByPriority []FlowGroup `json:"by_priority"`
+	ByTag      []FlowGroup `json:"by_tag"`
+	ByAssignee []FlowGroup `json:"by_assignee"`
+}
+
+// flowTimes are the measurements taken from one feature's status history
+type flowTimes struct {
+	completedAt *time.Time
+	leadTime    *time.Duration
+	cycleTime   *time.Duration
+	wipAge      *time.Duration
+}
+
+// measureFlow reads a feature's lead and cycle time, or its WIP age if it is active
+// now. changes must be the feature's status history, oldest first.
+func measureFlow(feature *Feature, changes []FeatureStatusChange, workflow *Workflow, since time.Time, until time.Time, now time.Time) flowTimes {
+	var firstActive, lastDone *time.Time
+	for i := range changes {
+		status, ok := workflow.Status(changes[i].ToStatus)
+		if !ok {
+			continue
+		}
+		switch status.Category {
+		case CategoryActive:
+			if firstActive == nil {
+				firstActive = &changes[i].ChangedAt
+			}
+		case CategoryDone:
+			lastDone = &changes[i].ChangedAt
+		}
+	}
+
+	times := flowTimes{}
+	status, ok := workflow.Status(feature.Status)
+	if !ok {
+		return times
+	}
+	switch status.Category {
+	case CategoryDone:
+		if lastDone == nil || lastDone.Before(since) || lastDone.After(until) {
+			return times
+		}
+		times.completedAt = lastDone
+		lead := lastDone.Sub(feature.CreatedAt)
+		times.leadTime = &lead
+		if firstActive != nil && !firstActive.After(*lastDone) {
+			cycle := lastDone.Sub(*firstActive)
+			times.cycleTime = &cycle
+		}
+	case CategoryActive:
+		if firstActive != nil {
+			age := now.Sub(*firstActive)
+			times.wipAge = &age
+		}
+	}
+	return times
+}
+
+// weekStart is the Monday of the week containing t
+func weekStart(t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+}
+
+// newFlowMetrics aggregates the measurements of a set of features, with a throughput
+// entry for every week of the window
+func newFlowMetrics(items []flowTimes, since time.Time, until time.Time) FlowMetrics {
+	var lead, cycle, wip []time.Duration
+	completed := map[string]int{}
+	for _, item := range items {
+		if item.leadTime != nil {
+			lead = append(lead, *item.leadTime)
+		}
+		if item.cycleTime != nil {
+			cycle = append(cycle, *item.cycleTime)
+		}
+		if item.wipAge != nil {
+			wip = append(wip, *item.wipAge)
+		}
+		if item.completedAt != nil {
+			completed[weekStart(item.completedAt.In(since.Location())).Format(DateLayout)]++
+		}
+	}
+
+	metrics := FlowMetrics{
+		LeadTime:   NewPercentiles(lead),
+		CycleTime:  NewPercentiles(cycle),
+		WIPAge:     NewPercentiles(wip),
+		Throughput: []ThroughputWeek{},
+	}
+	for week := weekStart(since); !week.After(until); week = week.AddDate(0, 0, 7) {
+		key := week.Format(DateLayout)
+		metrics.Throughput = append(metrics.Throughput, ThroughputWeek{Week: key, Count: completed[key]})
+	}
+	return metrics
+}
+
+// BuildFlowReport measures the features of a project from their status history.
+// history maps feature IDs to their status changes, oldest first. Features with
+// several tags count towards each of them.
+func BuildFlowReport(projectID int, features []Feature, history map[uint][]FeatureStatusChange, workflow *Workflow, since time.Time, until time.Time, now time.Time) *FlowReport {
+	var all []flowTimes
+	byPriority := map[string][]flowTimes{}
+	byTag := map[string][]flowTimes{}
+	byAssignee := map[string][]flowTimes{}
+	tagNames := map[string]string{"": "Untagged"}
+	assigneeNames := map[string]string{"": "Unassigned"}
+
+	for i := range features {
+		feature := &features[i]
+		times := measureFlow(feature, history[feature.ID], workflow, since, until, now)
+		all = append(all, times)
+
+		byPriority[string(feature.Priority)] = append(byPriority[string(feature.Priority)], times)
+
+		if len(feature.Tags) == 0 {
+			byTag[""] = append(byTag[""], times)
+		}
+		for _, tag := range feature.Tags {
+			byTag[tag.TagName] = append(byTag[tag.TagName], times)
+			tagNames[tag.TagName] = tag.TagName
+		}
+
+		assignee := ""
+		if feature.AssigneeID != 0 {
+			assignee = strconv.Itoa(int(feature.AssigneeID))
+			assigneeNames[assignee] = feature.Assignee.Username
+		}
+		byAssignee[assignee] = append(byAssignee[assignee], times)
+	}
+
+	report := &FlowReport{
+		ProjectID:   projectID,
+		Since:       since,
+		Until:       until,
+		FlowMetrics: newFlowMetrics(all, since, until),
+		ByPriority:  []FlowGroup{},
+		ByTag:       []FlowGroup{},
+		ByAssignee:  []FlowGroup{},
+	}
+
+	for _, priority := range []FeaturePriority{PriorityHigh, PriorityMedium, PriorityLow} {
+		if items, ok := byPriority[string(priority)]; ok {
+			report.ByPriority = append(report.ByPriority, FlowGroup{Key: string(priority), Name: string(priority), FlowMetrics: newFlowMetrics(items, since, until)})
+		}
+	}
+	report.ByTag = flowGroups(byTag, tagNames, since, until)
+	report.ByAssignee = flowGroups(byAssignee, assigneeNames, since, until)
+	return report
+}
+
+// flowGroups builds one group per key, sorted by name with the empty key last
+func flowGroups(items map[string][]flowTimes, names map[string]string, since time.Time, until time.Time) []FlowGroup {
+	groups := []FlowGroup{}
+	for key, group := range items {
+		groups = append(groups, FlowGroup{Key: key, Name: names[key], FlowMetrics: newFlowMetrics(group, since, until)})
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Key == "" || groups[j].Key == "" {
+			return groups[j].Key == ""
+		}
+		return groups[i].Name < groups[j].Name
+	})
+	return groups
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func days(values ...float64) []time.Duration {
+	durations := make([]time.Duration, len(values))
+	for i, value := range values {
+		durations[i] = time.Duration(value * 24 * float64(time.Hour))
+	}
+	return durations
+}
+
+func TestNewPercentiles(t *testing.T) {
+	tests := []struct {
+		name               string
+		durations          []time.Duration
+		p50, p75, p85, p95 float64
+	}{
+		{"single value", days(3), 3, 3, 3, 3},
+		{"ten values", days(1, 2, 3, 4, 5, 6, 7, 8, 9, 10), 5, 8, 9, 10},
+		{"unsorted", days(10, 1, 9, 2, 8, 3, 7, 4, 6, 5), 5, 8, 9, 10},
+		{"four values", days(1, 2, 3, 4), 2, 3, 4, 4},
+		{"twenty values", days(1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20), 10, 15, 17, 19},
+		{"rounded to hundredths", days(1.0/3, 2.0/3), 0.33, 0.67, 0.67, 0.67},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewPercentiles(tt.durations)
+			if got.Count != len(tt.durations) {
+				t.Errorf("Count = %d, want %d", got.Count, len(tt.durations))
+			}
+			checks := []struct {
+				label string
+				got   *float64
+				want  float64
+			}{
+				{"p50", got.P50, tt.p50},
+				{"p75", got.P75, tt.p75},
+				{"p85", got.P85, tt.p85},
+				{"p95", got.P95, tt.p95},
+			}
+			for _, check := range checks {
+				if check.got == nil {
+					t.Errorf("%s is nil, want %v", check.label, check.want)
+				} else if *check.got != check.want {
+					t.Errorf("%s = %v, want %v", check.label, *check.got, check.want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewPercentilesEmpty(t *testing.T) {
+	got := NewPercentiles(nil)
+	if got.Count != 0 || got.P50 != nil || got.P75 != nil || got.P85 != nil || got.P95 != nil {
+		t.Errorf("NewPercentiles(nil) = %+v, want a zero count and nil percentiles", got)
+	}
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// IdempotencyKey remembers the outcome of a POST request sent with an
+// Idempotency-Key header so that retries can be answered without re-running it
+type IdempotencyKey struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	UserID       uint      `gorm:"not null;uniqueIndex:idx_idempotency_user_key" json:"user_id"`
+	Key          string    `gorm:"column:idempotency_key;type:varchar(255);not null;uniqueIndex:idx_idempotency_user_key" json:"key"`
+	Fingerprint  string    `gorm:"type:varchar(64);not null" json:"fingerprint"`
+	Completed    bool      `gorm:"not null;default:false" json:"completed"`
+	StatusCode   int       `json:"status_code"`
+	ContentType  string    `gorm:"type:varchar(255)" json:"content_type"`
+	ETag         string    `gorm:"type:varchar(255)" json:"etag"`
+	ResponseBody []byte    `json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+	ExpiresAt    time.Time `gorm:"not null;index" json:"expires_at"`
+}
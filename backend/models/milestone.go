@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+type MilestoneState string
+
+const (
+	MilestoneOpen   MilestoneState = "open"
+	MilestoneClosed MilestoneState = "closed"
+)
+
+// Milestone is a planned release of a project that features are scheduled into
+type Milestone struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	ProjectID   int            `gorm:"not null;uniqueIndex:idx_milestone_name" json:"project_id"`
+	Name        string         `gorm:"size:255;not null;uniqueIndex:idx_milestone_name" json:"name"`
+	Description string         `gorm:"type:text" json:"description"`
+	TargetDate  *string        `gorm:"type:varchar(10)" json:"target_date"` // YYYY-MM-DD
+	State       MilestoneState `gorm:"type:varchar(20);not null;default:'open'" json:"state"`
+	ClosedAt    *time.Time     `json:"closed_at"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+
+	// Rollup is computed on read and never stored
+	Rollup *Rollup `gorm:"-" json:"rollup,omitempty"`
+}
@@ -2,6 +2,8 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type Project struct {
@@ -9,11 +11,39 @@ type Project struct {
 	Name        string    `gorm:"size:255;not null" json:"name"`
 	Description string    `gorm:"type:text" json:"description"`
 	OwnerID     int       `gorm:"not null;index" json:"owner_id"` // Foreign key
+	Version     uint      `gorm:"not null;default:1" json:"version"`
 	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
 
+	// AutoCompleteParents marks a parent feature done once all its children are done
+	AutoCompleteParents bool `gorm:"not null;default:false" json:"auto_complete_parents"`
+	// EnforceBlockers keeps features out of done statuses while a blocker is still open
+	EnforceBlockers bool `gorm:"not null;default:false" json:"enforce_blockers"`
+
+	// EstimateUnit and EstimateScale decide what feature and task estimates mean and
+	// which values they may take
+	EstimateUnit  EstimateUnit  `gorm:"type:varchar(10);not null;default:points" json:"estimate_unit"`
+	EstimateScale EstimateScale `gorm:"type:varchar(20);not null;default:fibonacci" json:"estimate_scale"`
+
+	// ScoringFramework decides how feature scores are computed
+	ScoringFramework ScoringFramework `gorm:"type:varchar(10);not null;default:rice" json:"scoring_framework"`
+
+	// ChangelogMarkdownTemplate and ChangelogHTMLTemplate replace the built-in changelog
+	// templates when set
+	ChangelogMarkdownTemplate string `gorm:"type:text" json:"changelog_markdown_template"`
+	ChangelogHTMLTemplate     string `gorm:"type:text" json:"changelog_html_template"`
+
 	// Association to User model (already in your models package)
 	Owner User `gorm:"foreignKey:OwnerID" json:"owner"`
 
 	Features []Feature `gorm:"foreignKey:ProjectID" json:"features,omitempty"`
+
+	// Rollup is computed on read and never stored
+	Rollup *Rollup `gorm:"-" json:"rollup,omitempty"`
+}
+
+// BeforeCreate starts every new project at version 1
+func (p *Project) BeforeCreate(tx *gorm.DB) (err error) {
+	p.Version = 1
+	return
 }
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// RollupItem points at the feature a roll-up metric was taken from
+type RollupItem struct {
+	ID        uint          `json:"id"`
+	Title     string        `json:"title"`
+	Status    FeatureStatus `json:"status"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+// Rollup summarises the progress of everything below a feature or inside a project
+type Rollup struct {
+	FeatureCount        int                   `json:"feature_count"`
+	DoneCount           int                   `json:"done_count"`
+	PercentDone         float64               `json:"percent_done"`
+	StatusCounts        map[FeatureStatus]int `json:"status_counts"`
+	HighestOpenPriority FeaturePriority       `json:"highest_open_priority,omitempty"`
+	OldestOpenItem      *RollupItem           `json:"oldest_open_item"`
+	TaskCount           int                   `json:"task_count"`
+	Estimates           EstimateTotals        `json:"estimates"`
+}
+
+// priorityRank orders priorities so the highest can be picked
+var priorityRank = map[FeaturePriority]int{
+	PriorityLow:    1,
+	PriorityMedium: 2,
+	PriorityHigh:   3,
+}
+
+// NewRollup aggregates a set of features and the tasks attached to them. Features
+// count as done when their status is in the workflow's done category.
+func NewRollup(features []Feature, tasks []Task, workflow *Workflow) *Rollup {
+	rollup := &Rollup{
+		FeatureCount: len(features),
+		StatusCounts: map[FeatureStatus]int{},
+		TaskCount:    len(tasks),
+	}
+
+	for _, task := range tasks {
+		if task.Estimate == nil {
+			rollup.Estimates.UnestimatedTasks++
+		} else {
+			rollup.Estimates.TaskEstimate += float64(*task.Estimate)
+		}
+		rollup.Estimates.TaskRemaining += task.Estimates().Remaining()
+	}
+
+	for _, feature := range features {
+		rollup.StatusCounts[feature.Status]++
+		if feature.Estimate != nil {
+			rollup.Estimates.Estimate += float64(*feature.Estimate)
+		}
+		if workflow.IsDone(feature.Status) {
+			rollup.DoneCount++
+			if feature.Estimate != nil {
+				rollup.Estimates.DoneEstimate += float64(*feature.Estimate)
+			}
+			continue
+		}
+
+		if feature.Estimate == nil {
+			rollup.Estimates.UnestimatedCount++
+		}
+		rollup.Estimates.Remaining += feature.Estimates().Remaining()
+
+		if priorityRank[feature.Priority] > priorityRank[rollup.HighestOpenPriority] {
+			rollup.HighestOpenPriority = feature.Priority
+		}
+		if rollup.OldestOpenItem == nil || feature.CreatedAt.Before(rollup.OldestOpenItem.CreatedAt) {
+			rollup.OldestOpenItem = &RollupItem{
+				ID:        feature.ID,
+				Title:     feature.Title,
+				Status:    feature.Status,
+				CreatedAt: feature.CreatedAt,
+			}
+		}
+	}
+
+	if rollup.FeatureCount > 0 {
+		rollup.PercentDone = math.Round(float64(rollup.DoneCount)*1000/float64(rollup.FeatureCount)) / 10
+	}
+	if rollup.Estimates.Estimate > 0 {
+		rollup.Estimates.PercentDone = math.Round(rollup.Estimates.DoneEstimate*1000/rollup.Estimates.Estimate) / 10
+	}
+	return rollup
+}
@@ -0,0 +1,199 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// ScoringFramework is the prioritisation method a project scores its features with
+type ScoringFramework string
+
+const (
+	ScoringRICE ScoringFramework = "rice"
+	ScoringWSJF ScoringFramework = "wsjf"
+)
+
+func IsValidScoringFramework(framework ScoringFramework) bool {
+	return framework == ScoringRICE || framework == ScoringWSJF
+}
+
+// RICEImpacts are the impact levels RICE uses, from massive down to minimal
+var RICEImpacts = []float64{3, 2, 1, 0.5, 0.25}
+
+// MaxScoringInput and MinScoringDivisor bound the scoring inputs, so every score stays
+// a finite number that can be written as JSON
+const (
+	MaxScoringInput   = 1e9
+	MinScoringDivisor = 0.01
+)
+
+// ScoringInputs are the optional inputs of both frameworks. Confidence is a percentage.
+type ScoringInputs struct {
+	Reach      *float64 `json:"reach"`
+	Impact     *float64 `json:"impact"`
+	Confidence *float64 `json:"confidence"`
+	Effort     *float64 `json:"effort"`
+
+	BusinessValue   *float64 `json:"business_value"`
+	TimeCriticality *float64 `json:"time_criticality"`
+	RiskReduction   *float64 `json:"risk_reduction"`
+	JobSize         *float64 `json:"job_size"`
+}
+
+// Check reports why the inputs are invalid, or "" if they are valid
+func (s ScoringInputs) Check() string {
+	named := []struct {
+		name  string
+		value *float64
+	}{
+		{"reach", s.Reach}, {"impact", s.Impact}, {"confidence", s.Confidence}, {"effort", s.Effort},
+		{"business_value", s.BusinessValue}, {"time_criticality", s.TimeCriticality},
+		{"risk_reduction", s.RiskReduction}, {"job_size", s.JobSize},
+	}
+	for _, input := range named {
+		if input.value == nil {
+			continue
+		}
+		if *input.value < 0 {
+			return input.name + " must not be negative"
+		}
+		if math.IsNaN(*input.value) || *input.value > MaxScoringInput {
+			return input.name + " must not be above " + formatScore(MaxScoringInput)
+		}
+	}
+
+	if s.Impact != nil {
+		valid := false
+		for _, impact := range RICEImpacts {
+			valid = valid || *s.Impact == impact
+		}
+		if !valid {
+			return "impact must be one of 3, 2, 1, 0.5 or 0.25"
+		}
+	}
+	if s.Confidence != nil && *s.Confidence > 100 {
+		return "confidence is a percentage and must not be above 100"
+	}
+	if s.Effort != nil && *s.Effort < MinScoringDivisor {
+		return "effort must be at least " + formatScore(MinScoringDivisor)
+	}
+	if s.JobSize != nil && *s.JobSize < MinScoringDivisor {
+		return "job_size must be at least " + formatScore(MinScoringDivisor)
+	}
+
+	for _, framework := range []ScoringFramework{ScoringRICE, ScoringWSJF} {
+		if score := s.rawScore(framework); score != nil && (math.IsNaN(*score) || math.IsInf(*score, 0)) {
+			return "the inputs give a " + string(framework) + " score that is not a finite number"
+		}
+	}
+	return ""
+}
+
+// rawScore computes the unrounded score, or nil while inputs are missing
+func (s ScoringInputs) rawScore(framework ScoringFramework) *float64 {
+	var score float64
+	if framework == ScoringWSJF {
+		if s.BusinessValue == nil || s.TimeCriticality == nil || s.RiskReduction == nil || s.JobSize == nil {
+			return nil
+		}
+		score = (*s.BusinessValue + *s.TimeCriticality + *s.RiskReduction) / *s.JobSize
+	} else {
+		if s.Reach == nil || s.Impact == nil || s.Confidence == nil || s.Effort == nil {
+			return nil
+		}
+		score = *s.Reach * *s.Impact * *s.Confidence / 100 / *s.Effort
+	}
+	return &score
+}
+
+// ScoreInput is one named input of a score
+type ScoreInput struct {
+	Name  string   `json:"name"`
+	Value *float64 `json:"value"`
+}
+
+// ScoreExplanation shows how a feature's score comes about. Score is nil, and Missing
+// lists what is needed, until every input of the framework is set.
+type ScoreExplanation struct {
+	Framework   ScoringFramework `json:"framework"`
+	Formula     string           `json:"formula"`
+	Inputs      []ScoreInput     `json:"inputs"`
+	Missing     []string         `json:"missing"`
+	Calculation string           `json:"calculation,omitempty"`
+	Score       *float64         `json:"score"`
+}
+
+// Explain scores the inputs under the framework and shows the working
+func (s ScoringInputs) Explain(framework ScoringFramework) *ScoreExplanation {
+	explanation := &ScoreExplanation{Framework: framework, Missing: []string{}}
+	if framework == ScoringWSJF {
+		explanation.Formula = "(business_value + time_criticality + risk_reduction) / job_size"
+		explanation.Inputs = []ScoreInput{
+			{"business_value", s.BusinessValue}, {"time_criticality", s.TimeCriticality},
+			{"risk_reduction", s.RiskReduction}, {"job_size", s.JobSize},
+		}
+	} else {
+		explanation.Framework = ScoringRICE
+		explanation.Formula = "reach * impact * confidence% / effort"
+		explanation.Inputs = []ScoreInput{
+			{"reach", s.Reach}, {"impact", s.Impact}, {"confidence", s.Confidence}, {"effort", s.Effort},
+		}
+	}
+
+	for _, input := range explanation.Inputs {
+		if input.Value == nil {
+			explanation.Missing = append(explanation.Missing, input.Name)
+		}
+	}
+	if len(explanation.Missing) > 0 {
+		return explanation
+	}
+
+	score := *s.rawScore(explanation.Framework)
+	if explanation.Framework == ScoringWSJF {
+		delay := *s.BusinessValue + *s.TimeCriticality + *s.RiskReduction
+		explanation.Calculation = fmt.Sprintf("(%s + %s + %s) / %s = %s / %s",
+			formatScore(*s.BusinessValue), formatScore(*s.TimeCriticality), formatScore(*s.RiskReduction),
+			formatScore(*s.JobSize), formatScore(delay), formatScore(*s.JobSize))
+	} else {
+		explanation.Calculation = fmt.Sprintf("%s * %s * %s%% / %s",
+			formatScore(*s.Reach), formatScore(*s.Impact), formatScore(*s.Confidence), formatScore(*s.Effort))
+	}
+	// Inputs stored before they were bounded may still overflow, and JSON has no
+	// way to write the result, so such a score is left out
+	if math.IsNaN(score) || math.IsInf(score, 0) {
+		explanation.Calculation += " is not a finite number"
+		return explanation
+	}
+	score = math.Round(score*100) / 100
+	explanation.Calculation += " = " + formatScore(score)
+	explanation.Score = &score
+	return explanation
+}
+
+// Score is the feature's score under the framework, or nil while inputs are missing
+func (s ScoringInputs) Score(framework ScoringFramework) *float64 {
+	return s.Explain(framework).Score
+}
+
+func formatScore(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// ScoredFeature is a feature's place in a ranked score list
+type ScoredFeature struct {
+	ID       uint            `json:"id"`
+	Title    string          `json:"title"`
+	Status   FeatureStatus   `json:"status"`
+	Priority FeaturePriority `json:"priority"`
+	Score    *float64        `json:"score"`
+	Missing  []string        `json:"missing"`
+}
+
+// ScoreRanking lists a project's features by score
+type ScoreRanking struct {
+	ProjectID int              `json:"project_id"`
+	Framework ScoringFramework `json:"framework"`
+	Features  []ScoredFeature  `json:"features"`
+}
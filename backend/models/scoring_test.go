@@ -0,0 +1,68 @@
+package models
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+)
+
+func num(v float64) *float64 { return &v }
+
+func TestScoringInputsCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		inputs ScoringInputs
+		want   string
+	}{
+		{"empty", ScoringInputs{}, ""},
+		{"full rice", ScoringInputs{Reach: num(1000), Impact: num(2), Confidence: num(80), Effort: num(4)}, ""},
+		{"largest inputs", ScoringInputs{Reach: num(MaxScoringInput), Impact: num(3), Confidence: num(100), Effort: num(MinScoringDivisor),
+			BusinessValue: num(MaxScoringInput), TimeCriticality: num(MaxScoringInput), RiskReduction: num(MaxScoringInput), JobSize: num(MinScoringDivisor)}, ""},
+		{"negative", ScoringInputs{Reach: num(-1)}, "reach must not be negative"},
+		{"huge reach", ScoringInputs{Reach: num(1e200), Impact: num(1), Confidence: num(100), Effort: num(1)}, "reach must not be above 1000000000"},
+		{"huge business value", ScoringInputs{BusinessValue: num(math.MaxFloat64)}, "business_value must not be above 1000000000"},
+		{"tiny effort", ScoringInputs{Effort: num(1e-200)}, "effort must be at least 0.01"},
+		{"zero effort", ScoringInputs{Effort: num(0)}, "effort must be at least 0.01"},
+		{"tiny job size", ScoringInputs{JobSize: num(0.001)}, "job_size must be at least 0.01"},
+		{"bad impact", ScoringInputs{Impact: num(4)}, "impact must be one of 3, 2, 1, 0.5 or 0.25"},
+		{"confidence above 100", ScoringInputs{Confidence: num(101)}, "confidence is a percentage and must not be above 100"},
+		{"not a number", ScoringInputs{Reach: num(math.NaN())}, "reach must not be above 1000000000"},
+	}
+	for _, tt := range tests {
+		if got := tt.inputs.Check(); got != tt.want {
+			t.Errorf("%s: Check() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestExplainScores(t *testing.T) {
+	inputs := ScoringInputs{Reach: num(1000), Impact: num(2), Confidence: num(80), Effort: num(3),
+		BusinessValue: num(8), TimeCriticality: num(5), RiskReduction: num(2), JobSize: num(5)}
+
+	rice := inputs.Explain(ScoringRICE)
+	if rice.Score == nil || *rice.Score != 533.33 || rice.Calculation != "1000 * 2 * 80% / 3 = 533.33" {
+		t.Errorf("rice = %v, %q", rice.Score, rice.Calculation)
+	}
+	wsjf := inputs.Explain(ScoringWSJF)
+	if wsjf.Score == nil || *wsjf.Score != 3 {
+		t.Errorf("wsjf = %v, %q", wsjf.Score, wsjf.Calculation)
+	}
+
+	missing := ScoringInputs{Reach: num(1)}.Explain(ScoringRICE)
+	if missing.Score != nil || strings.Join(missing.Missing, ",") != "impact,confidence,effort" {
+		t.Errorf("missing = %v, %v", missing.Score, missing.Missing)
+	}
+}
+
+func TestExplainLeavesOutNonFiniteScores(t *testing.T) {
+	// Inputs stored before they were bounded must not break every read of the feature
+	stored := ScoringInputs{Reach: num(1e200), Impact: num(3), Confidence: num(100), Effort: num(1e-200)}
+	explanation := stored.Explain(ScoringRICE)
+	if explanation.Score != nil {
+		t.Fatalf("score = %v, want nil", *explanation.Score)
+	}
+	if _, err := json.Marshal(explanation); err != nil {
+		t.Errorf("explanation cannot be written as JSON: %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package models
+
+import "time"
+
+type SprintState string
+
+const (
+	SprintPlanned SprintState = "planned"
+	SprintActive  SprintState = "active"
+	SprintClosed  SprintState = "closed"
+)
+
+// SprintTotals counts the work in a sprint. Feature and task estimates are kept apart
+// since tasks are often estimated in another unit.
+type SprintTotals struct {
+	Features     int     `gorm:"not null;default:0" json:"features"`
+	Tasks        int     `gorm:"not null;default:0" json:"tasks"`
+	Estimate     float64 `gorm:"not null;default:0" json:"estimate"`
+	TaskEstimate float64 `gorm:"not null;default:0" json:"task_estimate"`
+}
+
+// Add counts a feature or task with the given estimate
+func (t *SprintTotals) Add(isTask bool, estimate *Estimate) {
+	value := 0.0
+	if estimate != nil {
+		value = float64(*estimate)
+	}
+	if isTask {
+		t.Tasks++
+		t.TaskEstimate += value
+		return
+	}
+	t.Features++
+	t.Estimate += value
+}
+
+// Sprint is a time-boxed iteration of a project. Committed is the scope when the
+// sprint started, and Completed and CarriedOver are recorded when it closes.
+type Sprint struct {
+	ID        uint        `gorm:"primaryKey" json:"id"`
+	ProjectID int         `gorm:"not null;uniqueIndex:idx_sprint_name" json:"project_id"`
+	Name      string      `gorm:"size:255;not null;uniqueIndex:idx_sprint_name" json:"name"`
+	Goal      string      `gorm:"type:text" json:"goal"`
+	StartDate string      `gorm:"type:varchar(10);not null" json:"start_date"` // YYYY-MM-DD
+	EndDate   string      `gorm:"type:varchar(10);not null" json:"end_date"`
+	State     SprintState `gorm:"type:varchar(20);not null;default:'planned'" json:"state"`
+	StartedAt *time.Time  `json:"started_at"`
+	ClosedAt  *time.Time  `json:"closed_at"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+
+	Committed   SprintTotals `gorm:"embedded;embeddedPrefix:committed_" json:"committed"`
+	Completed   SprintTotals `gorm:"embedded;embeddedPrefix:completed_" json:"completed"`
+	CarriedOver SprintTotals `gorm:"embedded;embeddedPrefix:carried_over_" json:"carried_over"`
+
+	// Progress is computed on read and never stored
+	Progress *SprintProgress `gorm:"-" json:"progress,omitempty"`
+}
+
+// SprintProgress is the current scope of a sprint, how much of it is done and how
+// often the scope changed after the start
+type SprintProgress struct {
+	Scope   SprintTotals `json:"scope"`
+	Done    SprintTotals `json:"done"`
+	Added   int          `json:"added"`
+	Removed int          `json:"removed"`
+}
+
+type SprintScopeChangeKind string
+
+const (
+	SprintItemAdded   SprintScopeChangeKind = "added"
+	SprintItemRemoved SprintScopeChangeKind = "removed"
+)
+
+// SprintScopeChange records a feature or task entering or leaving a sprint after it started
+type SprintScopeChange struct {
+	ID        uint                  `gorm:"primaryKey" json:"id"`
+	SprintID  uint                  `gorm:"not null;index" json:"sprint_id"`
+	FeatureID *uint                 `json:"feature_id"`
+	TaskID    *uint                 `json:"task_id"`
+	Change    SprintScopeChangeKind `gorm:"type:varchar(10);not null" json:"change"`
+	UserID    uint                  `json:"user_id"`
+	ChangedAt time.Time             `gorm:"not null" json:"changed_at"`
+}
+
+// SprintItems are the features and tasks in a sprint
+type SprintItems struct {
+	Features []Feature `json:"features"`
+	Tasks    []Task    `json:"tasks"`
+}
+
+// SprintReport is the outcome of closing a sprint
+type SprintReport struct {
+	Sprint              *Sprint `json:"sprint"`
+	CompletedFeatureIDs []uint  `json:"completed_feature_ids"`
+	CompletedTaskIDs    []uint  `json:"completed_task_ids"`
+	CarriedFeatureIDs   []uint  `json:"carried_over_feature_ids"`
+	CarriedTaskIDs      []uint  `json:"carried_over_task_ids"`
+	NextSprintID        *uint   `json:"next_sprint_id"`
+}
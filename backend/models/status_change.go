@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// FeatureStatusChange records a feature entering a status. FromStatus is empty for
+// the status a feature was created with.
+type FeatureStatusChange struct {
+	ID         uint          `gorm:"primaryKey" json:"id"`
+	FeatureID  uint          `gorm:"not null;index" json:"feature_id"`
+	FromStatus FeatureStatus `gorm:"type:varchar(50)" json:"from_status"`
+	ToStatus   FeatureStatus `gorm:"type:varchar(50);not null" json:"to_status"`
+	ChangedAt  time.Time     `gorm:"not null;index" json:"changed_at"`
+}
@@ -4,14 +4,49 @@ import (
 	"time"
 )
 
+// SubFeature is the legacy shape served by the /api/sub-features compatibility
+// routes. Sub-features are stored as features with a ParentFeatureID, and
+// FeatureID here is that parent. ID is the feature's LegacySubFeatureID, which is
+// the old sub_features ID for migrated sub-features, so existing client URLs keep
+// working, and never a feature ID.
 type SubFeature struct {
-	ID          int       `json:"id" gorm:"primaryKey"`
-	FeatureID   int       `json:"feature_id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	Status      string    `json:"status"`
-	Priority    string    `json:"priority"`
-	AssigneeID  int       `json:"assignee_id"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          uint   `json:"id"`
+	FeatureID   uint   `json:"feature_id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Status      string `json:"status"`
+	Priority    string `json:"priority"`
+	AssigneeID  uint   `json:"assignee_id"`
+	Version     uint   `json:"version"`
+
+	Estimate          *Estimate `json:"estimate"`
+	RemainingEstimate *Estimate `json:"remaining_estimate"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NewSubFeature converts a child feature into its legacy sub-feature shape. The
+// feature must have been given a LegacySubFeatureID already.
+func NewSubFeature(feature Feature) SubFeature {
+	subFeature := SubFeature{
+		Title:       feature.Title,
+		Description: feature.Description,
+		Status:      string(feature.Status),
+		Priority:    string(feature.Priority),
+		AssigneeID:  feature.AssigneeID,
+		Version:     feature.Version,
+
+		Estimate:          feature.Estimate,
+		RemainingEstimate: feature.RemainingEstimate,
+		CreatedAt:         feature.CreatedAt,
+		UpdatedAt:         feature.UpdatedAt,
+	}
+	if feature.LegacySubFeatureID != nil {
+		subFeature.ID = *feature.LegacySubFeatureID
+	}
+	if feature.ParentFeatureID != nil {
+		subFeature.FeatureID = *feature.ParentFeatureID
+	}
+	return subFeature
 }
@@ -1,10 +1,59 @@
 package models
 
+import (
+	"regexp"
+	"time"
+)
+
+// Tag is a label within a project, carried by its features, sub-features, tasks and
+// the project itself. Archived tags stay where they are but cannot be added to more.
+type Tag struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	ProjectID   int       `gorm:"not null;uniqueIndex:idx_tag_name" json:"project_id"`
+	Name        string    `gorm:"type:varchar(50);not null;uniqueIndex:idx_tag_name" json:"name"`
+	Colour      string    `gorm:"type:varchar(7);not null;default:''" json:"colour"` // #rrggbb, or empty
+	Description string    `gorm:"type:text" json:"description"`
+	Archived    bool      `gorm:"not null;default:false" json:"archived"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	// The counts are filled in when tags are listed and never stored
+	FeatureCount int `gorm:"-" json:"feature_count"`
+	TaskCount    int `gorm:"-" json:"task_count"`
+	ProjectCount int `gorm:"-" json:"project_count"`
+}
+
+var tagColourPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// IsValidTagColour accepts a #rrggbb colour or no colour at all
+func IsValidTagColour(colour string) bool {
+	return colour == "" || tagColourPattern.MatchString(colour)
+}
+
+// FeatureTag puts a tag on a feature. TagName mirrors the tag's current name, so
+// features can be filtered by name without a join.
 type FeatureTag struct {
 	TagName       string `gorm:"primaryKey;type:varchar(50)" json:"tag_name"`
 	FeatureID     uint   `gorm:"primaryKey;not null;index" json:"feature_id"`
+	TagID         uint   `gorm:"not null;default:0;index" json:"tag_id"`
 	CreatedByUser uint   `gorm:"not null" json:"created_by_user"`
 
 	// Associations
 	Feature Feature `gorm:"foreignKey:FeatureID" json:"-"`
 }
+
+// TaskTag puts a tag on a task, drawn from the tags of the task's project
+type TaskTag struct {
+	TagName       string `gorm:"primaryKey;type:varchar(50)" json:"tag_name"`
+	TaskID        uint   `gorm:"primaryKey;not null;index" json:"task_id"`
+	TagID         uint   `gorm:"not null;index" json:"tag_id"`
+	CreatedByUser uint   `gorm:"not null" json:"created_by_user"`
+}
+
+// ProjectTag puts one of a project's tags on the project itself
+type ProjectTag struct {
+	TagName       string `gorm:"primaryKey;type:varchar(50)" json:"tag_name"`
+	ProjectID     uint   `gorm:"primaryKey;not null;index" json:"project_id"`
+	TagID         uint   `gorm:"not null;index" json:"tag_id"`
+	CreatedByUser uint   `gorm:"not null" json:"created_by_user"`
+}
@@ -1,6 +1,10 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Task struct {
 	gorm.Model
@@ -8,6 +12,27 @@ type Task struct {
 	TaskName      string `json:"task_name" binding:"required"`
 	Description   string `json:"description"`
 	FeatureID     uint   `json:"feature_id"`
-	SubFeatureID  uint   `json:"sub_feature_id"`
 	CreatedByUser uint   `json:"created_by_user"`
+	Version       uint   `gorm:"not null;default:1" json:"version"`
+
+	// Dates must fall inside the feature's start and due dates, when it has them
+	StartDate *string `gorm:"type:varchar(10)" json:"start_date"` // YYYY-MM-DD
+	DueDate   *string `gorm:"type:varchar(10);index" json:"due_date"`
+
+	// SprintID only changes through the sprint endpoints, which track scope changes
+	SprintID *uint `gorm:"index" json:"sprint_id"`
+
+	// Estimates are in the feature's project unit and must fit its scale
+	Estimate          *Estimate `json:"estimate"`
+	RemainingEstimate *Estimate `json:"remaining_estimate"`
+
+	// Overdue is maintained by the overdue job and is not covered by the version
+	Overdue      bool       `gorm:"not null;default:false" json:"overdue"`
+	OverdueSince *time.Time `json:"overdue_since"`
+}
+
+// BeforeCreate starts every new task at version 1
+func (t *Task) BeforeCreate(tx *gorm.DB) (err error) {
+	t.Version = 1
+	return
 }
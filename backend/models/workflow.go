@@ -0,0 +1,112 @@
+package models
+
+// StatusCategory groups workflow statuses so reports can tell open work from finished work
+type StatusCategory string
+
+const (
+	CategoryNotStarted StatusCategory = "not_started"
+	CategoryActive     StatusCategory = "active"
+	CategoryDone       StatusCategory = "done"
+)
+
+// WIPPolicy says what happens when a status goes over its WIP limit
+type WIPPolicy string
+
+const (
+	WIPWarn  WIPPolicy = "warn"
+	WIPBlock WIPPolicy = "block"
+)
+
+// WorkflowStatus is one status a project's features can be in. It is shown as a
+// column on the board, and a WIPLimit above zero caps the features it may hold.
+type WorkflowStatus struct {
+	ID        uint           `gorm:"primaryKey" json:"-"`
+	ProjectID int            `gorm:"not null;index;uniqueIndex:idx_workflow_status_key" json:"-"`
+	Key       FeatureStatus  `gorm:"type:varchar(50);not null;uniqueIndex:idx_workflow_status_key" json:"key"`
+	Name      string         `gorm:"type:varchar(100);not null" json:"name"`
+	Category  StatusCategory `gorm:"type:varchar(20);not null" json:"category"`
+	Position  int            `gorm:"not null" json:"position"`
+	WIPLimit  int            `gorm:"not null;default:0" json:"wip_limit"`
+	WIPPolicy WIPPolicy      `gorm:"type:varchar(10);not null;default:'warn'" json:"wip_policy"`
+}
+
+// WorkflowTransition allows features to move from one status to another
+type WorkflowTransition struct {
+	ID               uint          `gorm:"primaryKey" json:"-"`
+	ProjectID        int           `gorm:"not null;index" json:"-"`
+	From             FeatureStatus `gorm:"column:from_status;type:varchar(50);not null" json:"from"`
+	To               FeatureStatus `gorm:"column:to_status;type:varchar(50);not null" json:"to"`
+	RequiresAssignee bool          `gorm:"not null;default:false" json:"requires_assignee"`
+}
+
+// Workflow is the set of statuses and transitions a project uses. A workflow without
+// transitions lets features move freely between its statuses.
+type Workflow struct {
+	ProjectID   int                  `json:"project_id"`
+	Statuses    []WorkflowStatus     `json:"statuses"`
+	Transitions []WorkflowTransition `json:"transitions"`
+	IsDefault   bool                 `json:"is_default"`
+}
+
+// DefaultWorkflow is used by projects that have not defined their own
+func DefaultWorkflow(projectID int) *Workflow {
+	return &Workflow{
+		ProjectID: projectID,
+		Statuses: []WorkflowStatus{
+			{ProjectID: projectID, Key: StatusTodo, Name: "To do", Category: CategoryNotStarted, Position: 0, WIPPolicy: WIPWarn},
+			{ProjectID: projectID, Key: StatusInProgress, Name: "In progress", Category: CategoryActive, Position: 1, WIPPolicy: WIPWarn},
+			{ProjectID: projectID, Key: StatusDone, Name: "Done", Category: CategoryDone, Position: 2, WIPPolicy: WIPWarn},
+		},
+		Transitions: []WorkflowTransition{},
+		IsDefault:   true,
+	}
+}
+
+// Status looks up a status by key
+func (w *Workflow) Status(key FeatureStatus) (*WorkflowStatus, bool) {
+	for i := range w.Statuses {
+		if w.Statuses[i].Key == key {
+			return &w.Statuses[i], true
+		}
+	}
+	return nil, false
+}
+
+// IsDone reports whether a status belongs to the done category
+func (w *Workflow) IsDone(key FeatureStatus) bool {
+	status, ok := w.Status(key)
+	return ok && status.Category == CategoryDone
+}
+
+// DoneStatuses lists every status in the done category
+func (w *Workflow) DoneStatuses() []FeatureStatus {
+	keys := []FeatureStatus{}
+	for _, status := range w.Statuses {
+		if status.Category == CategoryDone {
+			keys = append(keys, status.Key)
+		}
+	}
+	return keys
+}
+
+// InitialStatus is the status new features get when none is given
+func (w *Workflow) InitialStatus() FeatureStatus {
+	if len(w.Statuses) == 0 {
+		return StatusTodo
+	}
+	return w.Statuses[0].Key
+}
+
+// Transition finds the transition between two statuses. Workflows without
+// transitions allow every move, which is reported as a transition with no guards.
+func (w *Workflow) Transition(from FeatureStatus, to FeatureStatus) (*WorkflowTransition, bool) {
+	if len(w.Transitions) == 0 {
+		return &WorkflowTransition{ProjectID: w.ProjectID, From: from, To: to}, true
+	}
+	for i := range w.Transitions {
+		if w.Transitions[i].From == from && w.Transitions[i].To == to {
+			return &w.Transitions[i], true
+		}
+	}
+	return nil, false
+}
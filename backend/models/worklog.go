@@ -0,0 +1,167 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// MaxWorklogMinutes caps a single worklog at one day
+const MaxWorklogMinutes = 24 * 60
+
+// Worklog is time a user spent on a feature or a task. ProjectID and FeatureID are
+// taken from the task when it is logged against one, so the entry stays with the
+// project it was billed to if the task moves or is deleted later. A timer is a
+// worklog with StartedAt set and StoppedAt empty until it is stopped; a user has at
+// most one running timer.
+type Worklog struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"not null;index;uniqueIndex:idx_running_timer,where:started_at IS NOT NULL AND stopped_at IS NULL" json:"user_id"`
+	ProjectID int        `gorm:"not null;index" json:"project_id"`
+	FeatureID *uint      `gorm:"index" json:"feature_id"`
+	TaskID    *uint      `gorm:"index" json:"task_id"`
+	Date      string     `gorm:"type:varchar(10);not null;index" json:"date"` // YYYY-MM-DD
+	Minutes   int        `gorm:"not null" json:"minutes"`
+	Note      string     `gorm:"type:text" json:"note"`
+	StartedAt *time.Time `json:"started_at"`
+	StoppedAt *time.Time `json:"stopped_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+
+	// Names are filled in when worklogs are listed and never stored
+	Username     string `gorm:"-" json:"username,omitempty"`
+	FeatureTitle string `gorm:"-" json:"feature_title,omitempty"`
+	TaskName     string `gorm:"-" json:"task_name,omitempty"`
+}
+
+// Running reports whether the worklog is a timer that has not been stopped
+func (w *Worklog) Running() bool {
+	return w.StartedAt != nil && w.StoppedAt == nil
+}
+
+// Stop ends a running timer, rounding the time spent up to whole minutes. A timer
+// left running for longer than a day logs MaxWorklogMinutes.
+func (w *Worklog) Stop(now time.Time) {
+	w.StoppedAt = &now
+	elapsed := now.Sub(*w.StartedAt)
+	minutes := MaxWorklogMinutes
+	if elapsed < time.Duration(MaxWorklogMinutes)*time.Minute {
+		minutes = int((elapsed + time.Minute - 1) / time.Minute)
+	}
+	if minutes < 1 {
+		minutes = 1
+	}
+	w.Minutes = minutes
+}
+
+// TimesheetDay is one day of a user's timesheet
+type TimesheetDay struct {
+	Date         string    `json:"date"`
+	TotalMinutes int       `json:"total_minutes"`
+	Entries      []Worklog `json:"entries"`
+}
+
+// Timesheet is a user's logged time in a date range, day by day
+type Timesheet struct {
+	UserID       uint           `json:"user_id"`
+	From         string         `json:"from"`
+	To           string         `json:"to"`
+	TotalMinutes int            `json:"total_minutes"`
+	Days         []TimesheetDay `json:"days"`
+}
+
+// BuildTimesheet groups worklogs by date. Days without entries are left out.
+func BuildTimesheet(userID uint, from string, to string, worklogs []Worklog) *Timesheet {
+	timesheet := &Timesheet{UserID: userID, From: from, To: to, Days: []TimesheetDay{}}
+	byDate := map[string]*TimesheetDay{}
+	var dates []string
+	for _, worklog := range worklogs {
+		day, ok := byDate[worklog.Date]
+		if !ok {
+			day = &TimesheetDay{Date: worklog.Date, Entries: []Worklog{}}
+			byDate[worklog.Date] = day
+			dates = append(dates, worklog.Date)
+		}
+		day.Entries = append(day.Entries, worklog)
+		day.TotalMinutes += worklog.Minutes
+		timesheet.TotalMinutes += worklog.Minutes
+	}
+
+	sort.Strings(dates)
+	for _, date := range dates {
+		timesheet.Days = append(timesheet.Days, *byDate[date])
+	}
+	return timesheet
+}
+
+// TimeTotal is the time logged against one user, feature or date
+type TimeTotal struct {
+	ID      uint   `json:"id,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Date    string `json:"date,omitempty"`
+	Minutes int    `json:"minutes"`
+}
+
+// ProjectTime totals the time logged on a project in a date range
+type ProjectTime struct {
+	ProjectID    int         `json:"project_id"`
+	From         string      `json:"from"`
+	To           string      `json:"to"`
+	TotalMinutes int         `json:"total_minutes"`
+	ByUser       []TimeTotal `json:"by_user"`
+	ByFeature    []TimeTotal `json:"by_feature"`
+	ByDate       []TimeTotal `json:"by_date"`
+}
+
+// BuildProjectTime adds up worklogs per user, per feature and per date. Users and
+// features are sorted by time spent, dates in calendar order.
+func BuildProjectTime(projectID int, from string, to string, worklogs []Worklog) *ProjectTime {
+	projectTime := &ProjectTime{ProjectID: projectID, From: from, To: to}
+	users := map[uint]*TimeTotal{}
+	features := map[uint]*TimeTotal{}
+	dates := map[string]*TimeTotal{}
+	add := func(totals map[uint]*TimeTotal, id uint, name string, minutes int) {
+		if totals[id] == nil {
+			totals[id] = &TimeTotal{ID: id, Name: name}
+		}
+		totals[id].Minutes += minutes
+	}
+
+	for _, worklog := range worklogs {
+		projectTime.TotalMinutes += worklog.Minutes
+
+		add(users, worklog.UserID, worklog.Username, worklog.Minutes)
+		if worklog.FeatureID != nil {
+			add(features, *worklog.FeatureID, worklog.FeatureTitle, worklog.Minutes)
+		}
+
+		if dates[worklog.Date] == nil {
+			dates[worklog.Date] = &TimeTotal{Date: worklog.Date}
+		}
+		dates[worklog.Date].Minutes += worklog.Minutes
+	}
+
+	projectTime.ByUser = sortedTotals(users)
+	projectTime.ByFeature = sortedTotals(features)
+	projectTime.ByDate = []TimeTotal{}
+	for _, total := range dates {
+		projectTime.ByDate = append(projectTime.ByDate, *total)
+	}
+	sort.Slice(projectTime.ByDate, func(i, j int) bool {
+		return projectTime.ByDate[i].Date < projectTime.ByDate[j].Date
+	})
+	return projectTime
+}
+
+func sortedTotals(totals map[uint]*TimeTotal) []TimeTotal {
+	sorted := make([]TimeTotal, 0, len(totals))
+	for _, total := range totals {
+		sorted = append(sorted, *total)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Minutes != sorted[j].Minutes {
+			return sorted[i].Minutes > sorted[j].Minutes
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+	return sorted
+}
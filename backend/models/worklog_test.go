@@ -0,0 +1,31 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWorklogStop(t *testing.T) {
+	tests := []struct {
+		name    string
+		elapsed time.Duration
+		want    int
+	}{
+		{"under a minute", 10 * time.Second, 1},
+		{"rounds up", 90 * time.Second, 2},
+		{"whole minutes", 30 * time.Minute, 30},
+		{"a full day", 24 * time.Hour, MaxWorklogMinutes},
+		{"left running for days", 72*time.Hour + 30*time.Second, MaxWorklogMinutes},
+	}
+	for _, tt := range tests {
+		started := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+		worklog := Worklog{StartedAt: &started}
+		worklog.Stop(started.Add(tt.elapsed))
+		if worklog.Minutes != tt.want {
+			t.Errorf("%s: Minutes = %d, want %d", tt.name, worklog.Minutes, tt.want)
+		}
+		if worklog.Running() {
+			t.Errorf("%s: timer still running", tt.name)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package repositories
+
+import (
+	"errors"
+
+	"FeaturePlus/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrNotInBacklog is returned when a backlog request names a feature of another project
+var ErrNotInBacklog = errors.New("feature is not in the project's backlog")
+
+// BacklogTop and BacklogBottom are the positions a feature can be sent to directly
+const (
+	BacklogTop    = "top"
+	BacklogBottom = "bottom"
+)
+
+// MoveInBacklog places a feature at the top or bottom of its project's backlog, ahead
+// of beforeID or after afterID. Ranks are not covered by the version.
+func (r *FeatureRepository) MoveInBacklog(feature *models.Feature, position string, beforeID *uint, afterID *uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		items, err := loadRanking(tx.Where("project_id = ? AND id <> ?", feature.ProjectID, feature.ID), "backlog_rank")
+		if err != nil {
+			return err
+		}
+
+		index := rankIndex(items, beforeID, afterID)
+		if position == BacklogTop {
+			index = 0
+		}
+		if index < 0 {
+			return ErrNotInBacklog
+		}
+		rank, err := placeRank(tx, "backlog_rank", items, index)
+		if err != nil {
+			return err
+		}
+		return tx.Model(&models.Feature{}).Where("id = ?", feature.ID).UpdateColumn("backlog_rank", rank).Error
+	})
+}
+
+// ReorderBacklog puts the given features in the given order. They take the places in
+// the backlog that they held between them, so the rest of the backlog stays put and
+// listing every feature reorders the whole backlog. It returns the new order.
+func (r *FeatureRepository) ReorderBacklog(projectID int, ids []uint) ([]uint, error) {
+	var order []uint
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		items, err := loadRanking(tx.Where("project_id = ?", projectID), "backlog_rank")
+		if err != nil {
+			return err
+		}
+
+		listed := make(map[uint]bool, len(ids))
+		for _, id := range ids {
+			listed[id] = true
+		}
+		next := 0
+		order = make([]uint, len(items))
+		for i, item := range items {
+			order[i] = item.ID
+			if listed[item.ID] {
+				order[i] = ids[next]
+				next++
+			}
+		}
+		if next != len(ids) {
+			return ErrNotInBacklog
+		}
+
+		ranks := models.SpreadRanks(len(order))
+		for i, id := range order {
+			if err := tx.Model(&models.Feature{}).Where("id = ?", id).UpdateColumn("backlog_rank", ranks[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
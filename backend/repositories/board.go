@@ -0,0 +1,94 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+
+	"FeaturePlus/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrCardNotInColumn is returned when a move is placed next to a feature that is not
+// in the target column
+var ErrCardNotInColumn = errors.New("the feature to place the card next to is not in the target column")
+
+// GetBoardFeatures lists a project's features by board rank, unranked ones last
+func (r *FeatureRepository) GetBoardFeatures(projectID int) ([]models.Feature, error) {
+	var features []models.Feature
+	err := r.db.Where("project_id = ?", projectID).
+		Preload("Assignee").Preload("Tags").
+		Order("board_rank = '', board_rank, id").
+		Find(&features).Error
+	if err != nil {
+		return nil, err
+	}
+	return features, nil
+}
+
+// CheckWIPLimit checks whether featureID may enter status without going over the
+// status's WIP limit. Pass 0 for a feature that does not exist yet. It returns a
+// warning when the status only warns and a WIPLimitError when it blocks.
+func (r *FeatureRepository) CheckWIPLimit(workflow *models.Workflow, featureID uint, status models.FeatureStatus) (string, error) {
+	column, ok := workflow.Status(status)
+	if !ok || column.WIPLimit <= 0 {
+		return "", nil
+	}
+
+	var count int64
+	err := r.db.Model(&models.Feature{}).
+		Where("project_id = ? AND status = ? AND id <> ?", workflow.ProjectID, status, featureID).
+		Count(&count).Error
+	if err != nil {
+		return "", err
+	}
+	if int(count) < column.WIPLimit {
+		return "", nil
+	}
+
+	message := fmt.Sprintf("%s is at its WIP limit of %d", column.Name, column.WIPLimit)
+	if column.WIPPolicy == models.WIPBlock {
+		return "", &WIPLimitError{Message: message}
+	}
+	return message, nil
+}
+
+// MoveCard puts a feature into status and places it ahead of beforeID, after afterID,
+// or at the bottom of the column when neither is given. A status change bumps the
+// version and is recorded in the status history; a rank change alone does neither.
+// When ranks run out of room the column is spread out again. A status change is
+// checked against the workflow's WIP limit in the same transaction as the move, so
+// concurrent moves cannot both slip under it; the returned warning is set when the
+// limit only warns. workflow is only needed for a status change.
+func (r *FeatureRepository) MoveCard(feature *models.Feature, status models.FeatureStatus, workflow *models.Workflow, beforeID *uint, afterID *uint) (string, error) {
+	warning := ""
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if status != feature.Status {
+			var err error
+			if warning, err = (&FeatureRepository{db: tx}).CheckWIPLimit(workflow, feature.ID, status); err != nil {
+				return err
+			}
+		}
+
+		column, err := loadRanking(tx.Where("project_id = ? AND status = ? AND id <> ?", feature.ProjectID, status, feature.ID), "board_rank")
+		if err != nil {
+			return err
+		}
+
+		index := rankIndex(column, beforeID, afterID)
+		if index < 0 {
+			return ErrCardNotInColumn
+		}
+		rank, err := placeRank(tx, "board_rank", column, index)
+		if err != nil {
+			return err
+		}
+
+		if status == feature.Status {
+			return tx.Model(&models.Feature{}).Where("id = ?", feature.ID).UpdateColumn("board_rank", rank).Error
+		}
+		updates := map[string]interface{}{"status": status, "board_rank": rank}
+		return (&FeatureRepository{db: tx}).PatchFeature(int(feature.ID), feature.Version, updates)
+	})
+	return warning, err
+}
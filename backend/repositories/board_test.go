@@ -0,0 +1,68 @@
+package repositories
+
+import (
+	"errors"
+	"testing"
+
+	"FeaturePlus/models"
+)
+
+func TestMoveCardChecksWIPLimit(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      models.WIPPolicy
+		wantErr     bool
+		wantWarning bool
+	}{
+		{"block", models.WIPBlock, true, false},
+		{"warn", models.WIPWarn, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t, &models.Feature{}, &models.FeatureStatusChange{}, &models.FeedbackRequest{})
+			busy := models.Feature{ProjectID: 1, Title: "busy", Status: models.StatusInProgress, BoardRank: "i"}
+			card := models.Feature{ProjectID: 1, Title: "card", Status: models.StatusTodo, BoardRank: "i"}
+			for _, feature := range []*models.Feature{&busy, &card} {
+				if err := db.Create(feature).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+			workflow := models.DefaultWorkflow(1)
+			workflow.Statuses[1].WIPLimit = 1
+			workflow.Statuses[1].WIPPolicy = tt.policy
+
+			repo := NewFeatureRepository(db)
+			warning, err := repo.MoveCard(&card, models.StatusInProgress, workflow, nil, nil)
+			var wipErr *WIPLimitError
+			if errors.As(err, &wipErr) != tt.wantErr {
+				t.Fatalf("MoveCard error = %v, want WIP limit error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatal(err)
+			}
+			if (warning != "") != tt.wantWarning {
+				t.Errorf("MoveCard warning = %q", warning)
+			}
+
+			var stored models.Feature
+			db.First(&stored, card.ID)
+			moved := stored.Status == models.StatusInProgress
+			if moved == tt.wantErr {
+				t.Errorf("card status = %q after the move", stored.Status)
+			}
+		})
+	}
+}
+
+func TestMoveCardRerankSkipsWIPLimit(t *testing.T) {
+	db := openTestDB(t, &models.Feature{}, &models.FeatureStatusChange{}, &models.FeedbackRequest{})
+	seedRanked(t, db, "c", "m")
+	var card models.Feature
+	db.First(&card, 2)
+
+	// A move within the column needs no workflow
+	warning, err := NewFeatureRepository(db).MoveCard(&card, card.Status, nil, nil, nil)
+	if err != nil || warning != "" {
+		t.Fatalf("MoveCard = %q, %v", warning, err)
+	}
+}
@@ -0,0 +1,116 @@
+package repositories
+
+import (
+	"encoding/json"
+	"strings"
+
+	"FeaturePlus/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type CustomFieldRepository struct {
+	db *gorm.DB
+}
+
+func NewCustomFieldRepository(db *gorm.DB) *CustomFieldRepository {
+	return &CustomFieldRepository{db: db}
+}
+
+// GetFieldsByProject lists a project's custom fields in display order
+func (r *CustomFieldRepository) GetFieldsByProject(projectID int) ([]models.CustomField, error) {
+	fields := []models.CustomField{}
+	if err := r.db.Where("project_id = ?", projectID).Order("position, id").Find(&fields).Error; err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+func (r *CustomFieldRepository) GetField(projectID int, fieldID int) (*models.CustomField, error) {
+	var field models.CustomField
+	if err := r.db.Where("project_id = ?", projectID).First(&field, fieldID).Error; err != nil {
+		return nil, err
+	}
+	return &field, nil
+}
+
+// GetFieldByKey looks a field up by its key within a project
+func (r *CustomFieldRepository) GetFieldByKey(projectID int, key string) (*models.CustomField, error) {
+	var field models.CustomField
+	if err := r.db.Where("project_id = ? AND key = ?", projectID, key).First(&field).Error; err != nil {
+		return nil, err
+	}
+	return &field, nil
+}
+
+func (r *CustomFieldRepository) CreateField(field *models.CustomField) error {
+	return r.db.Create(field).Error
+}
+
+func (r *CustomFieldRepository) UpdateField(field *models.CustomField) error {
+	return r.db.Select("*").Omit("CreatedAt").Save(field).Error
+}
+
+// DeleteField removes a field together with every value stored for it
+func (r *CustomFieldRepository) DeleteField(field *models.CustomField) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("field_id = ?", field.ID).Delete(&models.CustomFieldValue{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(field).Error
+	})
+}
+
+// OptionsInUse returns which of the given select options features still use
+func (r *CustomFieldRepository) OptionsInUse(field *models.CustomField, options []string) ([]string, error) {
+	inUse := []string{}
+	for _, option := range options {
+		query := r.db.Model(&models.CustomFieldValue{}).Where("field_id = ?", field.ID)
+		if field.Type == models.FieldMultiSelect {
+			query = query.Where("value LIKE ? ESCAPE '\\'", jsonContainsPattern(option))
+		} else {
+			query = query.Where("text_value = ?", option)
+		}
+
+		var count int64
+		if err := query.Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			inUse = append(inUse, option)
+		}
+	}
+	return inUse, nil
+}
+
+// SetFeatureValues stores the given values for a feature, replacing earlier values of
+// the same fields, and removes the values of the fields in removeFieldIDs
+func (r *CustomFieldRepository) SetFeatureValues(featureID uint, values []models.CustomFieldValue, removeFieldIDs []uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if len(removeFieldIDs) > 0 {
+			if err := tx.Where("feature_id = ? AND field_id IN ?", featureID, removeFieldIDs).Delete(&models.CustomFieldValue{}).Error; err != nil {
+				return err
+			}
+		}
+		if len(values) == 0 {
+			return nil
+		}
+
+		for i := range values {
+			values[i].ID = 0
+			values[i].FeatureID = featureID
+		}
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "feature_id"}, {Name: "field_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"field_key", "value", "text_value", "number_value"}),
+		}).Create(&values).Error
+	})
+}
+
+// jsonContainsPattern builds a LIKE pattern matching a JSON array that contains option
+func jsonContainsPattern(option string) string {
+	encoded, _ := json.Marshal(option)
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(string(encoded))
+	return "%" + escaped + "%"
+}
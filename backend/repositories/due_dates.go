@@ -0,0 +1,252 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"FeaturePlus/models"
+
+	"gorm.io/gorm"
+)
+
+// DueFilter selects the open features and tasks due in a date range for a due date
+// view. Either ProjectID or AssigneeID scopes the view; an empty From leaves the range
+// open at the start.
+type DueFilter struct {
+	ProjectID  int
+	AssigneeID uint
+	From       string
+	To         string
+}
+
+// CheckDateWindow checks that a feature's dates are valid and fall within its parent's
+// dates. For an existing feature the dates of its children and tasks must also fall
+// within the new window. Problems with the dates are returned as a DateRangeError.
+func (r *FeatureRepository) CheckDateWindow(featureID uint, parentID *uint, window models.DateWindow) error {
+	if err := window.Validate(); err != nil {
+		return &DateRangeError{Message: err.Error()}
+	}
+
+	if parentID != nil {
+		var parent models.Feature
+		if err := r.db.Select("id", "start_date", "due_date").First(&parent, *parentID).Error; err != nil {
+			return err
+		}
+		if reason := parent.Window().Outside(window); reason != "" {
+			return &DateRangeError{Message: "dates must fall within the parent feature's dates: " + reason}
+		}
+	}
+
+	if featureID == 0 {
+		return nil
+	}
+
+	var children []models.Feature
+	if err := r.db.Select("id", "start_date", "due_date").Where("parent_feature_id = ?", featureID).Order("id").Find(&children).Error; err != nil {
+		return err
+	}
+	for _, child := range children {
+		if reason := window.Outside(child.Window()); reason != "" {
+			return &DateRangeError{Message: fmt.Sprintf("sub-feature %d falls outside the new dates: %s", child.ID, reason)}
+		}
+	}
+
+	var tasks []models.Task
+	if err := r.db.Select("id", "start_date", "due_date").Where("feature_id = ?", featureID).Order("id").Find(&tasks).Error; err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		if reason := window.Outside(task.Window()); reason != "" {
+			return &DateRangeError{Message: fmt.Sprintf("task %d falls outside the new dates: %s", task.ID, reason)}
+		}
+	}
+	return nil
+}
+
+// CheckTaskDates checks that a task's dates are valid and fall within the dates of the
+// feature it belongs to. Tasks without a feature only have their format checked.
+func (r *FeatureRepository) CheckTaskDates(featureID uint, window models.DateWindow) error {
+	if err := window.Validate(); err != nil {
+		return &DateRangeError{Message: err.Error()}
+	}
+	if featureID == 0 {
+		return nil
+	}
+
+	var feature models.Feature
+	if err := r.db.Select("id", "start_date", "due_date").First(&feature, featureID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if reason := feature.Window().Outside(window); reason != "" {
+		return &DateRangeError{Message: "dates must fall within the feature's dates: " + reason}
+	}
+	return nil
+}
+
+// GetDueItems lists the features and tasks due between filter.From and filter.To,
+// earliest first. Features in a done status of their project's workflow are left out,
+// and so are the tasks of those features.
+func (r *FeatureRepository) GetDueItems(filter DueFilter) (*models.DueItems, error) {
+	features := r.db.Model(&models.Feature{}).Where("features.due_date IS NOT NULL AND features.due_date <= ?", filter.To)
+	tasks := r.db.Model(&models.Task{}).
+		Joins("JOIN features ON features.id = tasks.feature_id AND features.deleted_at IS NULL").
+		Where("tasks.due_date IS NOT NULL AND tasks.due_date <= ?", filter.To)
+	if filter.From != "" {
+		features = features.Where("features.due_date >= ?", filter.From)
+		tasks = tasks.Where("tasks.due_date >= ?", filter.From)
+	}
+	if filter.ProjectID != 0 {
+		features = features.Where("features.project_id = ?", filter.ProjectID)
+		tasks = tasks.Where("features.project_id = ?", filter.ProjectID)
+	}
+	if filter.AssigneeID != 0 {
+		features = features.Where("features.assignee_id = ?", filter.AssigneeID)
+		tasks = tasks.Where("features.assignee_id = ?", filter.AssigneeID)
+	}
+
+	var dueFeatures []models.Feature
+	if err := features.Preload("Assignee").Preload("Tags").Order("features.due_date, features.id").Find(&dueFeatures).Error; err != nil {
+		return nil, err
+	}
+	var dueTasks []models.Task
+	if err := tasks.Select("tasks.*").Order("tasks.due_date, tasks.id").Find(&dueTasks).Error; err != nil {
+		return nil, err
+	}
+
+	featureIDs := make([]uint, 0, len(dueTasks))
+	for _, task := range dueTasks {
+		featureIDs = append(featureIDs, task.FeatureID)
+	}
+	done, err := r.doneFeatures(dueFeatures, featureIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	items := &models.DueItems{Features: []models.Feature{}, Tasks: []models.Task{}}
+	for _, feature := range dueFeatures {
+		if !done[feature.ID] {
+			items.Features = append(items.Features, feature)
+		}
+	}
+	for _, task := range dueTasks {
+		if !done[task.FeatureID] {
+			items.Tasks = append(items.Tasks, task)
+		}
+	}
+	return items, nil
+}
+
+// doneFeatures reports which of the given features, plus the features with the extra
+// IDs, are in a done status of their project's workflow
+func (r *FeatureRepository) doneFeatures(features []models.Feature, extraIDs []uint) (map[uint]bool, error) {
+	if len(extraIDs) > 0 {
+		var extra []models.Feature
+		if err := r.db.Select("id", "project_id", "status").Where("id IN ?", extraIDs).Find(&extra).Error; err != nil {
+			return nil, err
+		}
+		features = append(append([]models.Feature{}, features...), extra...)
+	}
+
+	workflows := NewWorkflowRepository(r.db)
+	byProject := map[int]*models.Workflow{}
+	done := make(map[uint]bool, len(features))
+	for _, feature := range features {
+		workflow, ok := byProject[feature.ProjectID]
+		if !ok {
+			var err error
+			if workflow, err = workflows.GetWorkflow(feature.ProjectID); err != nil {
+				return nil, err
+			}
+			byProject[feature.ProjectID] = workflow
+		}
+		done[feature.ID] = workflow.IsDone(feature.Status)
+	}
+	return done, nil
+}
+
+// FlagOverdue sets the overdue flag on open features and tasks whose due date is
+// before today and clears it from items that are done, no longer late or no longer
+// dated. A task counts as open until its feature is done. Overdue changes do not bump
+// versions. It returns how many items were flagged and cleared.
+func (r *FeatureRepository) FlagOverdue(today string) (int, int, error) {
+	var flagged, cleared int
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		repo := NewFeatureRepository(tx)
+
+		var features []models.Feature
+		err := tx.Select("id", "project_id", "status", "due_date", "overdue").
+			Where("(due_date IS NOT NULL AND due_date < ?) OR overdue = ?", today, true).
+			Find(&features).Error
+		if err != nil {
+			return err
+		}
+
+		var tasks []models.Task
+		err = tx.Select("id", "feature_id", "due_date", "overdue").
+			Where("(due_date IS NOT NULL AND due_date < ?) OR overdue = ?", today, true).
+			Find(&tasks).Error
+		if err != nil {
+			return err
+		}
+
+		var taskFeatureIDs []uint
+		for _, task := range tasks {
+			if task.FeatureID != 0 {
+				taskFeatureIDs = append(taskFeatureIDs, task.FeatureID)
+			}
+		}
+		done, err := repo.doneFeatures(features, taskFeatureIDs)
+		if err != nil {
+			return err
+		}
+
+		late := func(due *string) bool { return due != nil && *due < today }
+		var flagFeatures, clearFeatures, flagTasks, clearTasks []uint
+		for _, feature := range features {
+			overdue := late(feature.DueDate) && !done[feature.ID]
+			if overdue && !feature.Overdue {
+				flagFeatures = append(flagFeatures, feature.ID)
+			} else if !overdue && feature.Overdue {
+				clearFeatures = append(clearFeatures, feature.ID)
+			}
+		}
+		for _, task := range tasks {
+			overdue := late(task.DueDate) && !done[task.FeatureID]
+			if overdue && !task.Overdue {
+				flagTasks = append(flagTasks, task.ID)
+			} else if !overdue && task.Overdue {
+				clearTasks = append(clearTasks, task.ID)
+			}
+		}
+
+		now := time.Now()
+		set := map[string]interface{}{"overdue": true, "overdue_since": now}
+		unset := map[string]interface{}{"overdue": false, "overdue_since": nil}
+		for _, change := range []struct {
+			model   interface{}
+			ids     []uint
+			columns map[string]interface{}
+		}{
+			{&models.Feature{}, flagFeatures, set},
+			{&models.Feature{}, clearFeatures, unset},
+			{&models.Task{}, flagTasks, set},
+			{&models.Task{}, clearTasks, unset},
+		} {
+			if len(change.ids) == 0 {
+				continue
+			}
+			if err := tx.Model(change.model).Where("id IN ?", change.ids).UpdateColumns(change.columns).Error; err != nil {
+				return err
+			}
+		}
+
+		flagged = len(flagFeatures) + len(flagTasks)
+		cleared = len(clearFeatures) + len(clearTasks)
+		return nil
+	})
+	return flagged, cleared, err
+}
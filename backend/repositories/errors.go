@@ -0,0 +1,58 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrVersionConflict is returned when a write targets a version that is no longer current
+var ErrVersionConflict = errors.New("version conflict")
+
+// StatusInUseError is returned when a workflow change would drop statuses that features still use
+type StatusInUseError struct {
+	Statuses []string
+}
+
+func (e *StatusInUseError) Error() string {
+	return fmt.Sprintf("statuses still in use by features: %s", strings.Join(e.Statuses, ", "))
+}
+
+// DateRangeError is returned when start and due dates are invalid or do not fit the
+// dates of the parent feature, the children or the tasks
+type DateRangeError struct {
+	Message string
+}
+
+func (e *DateRangeError) Error() string {
+	return e.Message
+}
+
+// EstimateError is returned when an estimate does not fit the project's estimation scale
+type EstimateError struct {
+	Message string
+}
+
+func (e *EstimateError) Error() string {
+	return e.Message
+}
+
+// WIPLimitError is returned when a feature would go over the WIP limit of a status
+// whose policy is to block
+type WIPLimitError struct {
+	Message string
+}
+
+func (e *WIPLimitError) Error() string {
+	return e.Message
+}
+
+// TagError is returned when a tag cannot be used, such as an archived tag being added
+// to a feature
+type TagError struct {
+	Message string
+}
+
+func (e *TagError) Error() string {
+	return e.Message
+}
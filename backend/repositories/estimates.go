@@ -0,0 +1,47 @@
+package repositories
+
+import (
+	"errors"
+
+	"FeaturePlus/models"
+
+	"gorm.io/gorm"
+)
+
+// CheckEstimates checks a feature's estimates against its project's estimation scale.
+// Problems with the values are returned as an EstimateError.
+func (r *FeatureRepository) CheckEstimates(projectID int, estimates models.Estimates) error {
+	if estimates.Estimate == nil && estimates.RemainingEstimate == nil {
+		return nil
+	}
+
+	scale := models.ScaleFree
+	var project models.Project
+	err := r.db.Select("id", "estimate_scale").First(&project, projectID).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if err == nil {
+		scale = project.EstimateScale
+	}
+
+	if reason := estimates.Check(scale); reason != "" {
+		return &EstimateError{Message: reason}
+	}
+	return nil
+}
+
+// CheckTaskEstimates checks a task's estimates against the scale of its feature's
+// project. Tasks without a feature only need non-negative values.
+func (r *FeatureRepository) CheckTaskEstimates(featureID uint, estimates models.Estimates) error {
+	if estimates.Estimate == nil && estimates.RemainingEstimate == nil {
+		return nil
+	}
+
+	var feature models.Feature
+	err := r.db.Select("id", "project_id").First(&feature, featureID).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	return r.CheckEstimates(feature.ProjectID, estimates)
+}
@@ -1,11 +1,47 @@
 package repositories
 
 import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
 	"FeaturePlus/models"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// maxTreeDepth bounds how deep recursive hierarchy queries may go
+const maxTreeDepth = 1000
+
+// FeatureFilter narrows a feature query; empty fields are ignored
+type FeatureFilter struct {
+	ProjectID       int    `json:"project_id"`
+	Status          string `json:"status"`
+	Priority        string `json:"priority"`
+	AssigneeID      *uint  `json:"assignee_id"`
+	ParentFeatureID *uint  `json:"parent_feature_id"`
+	MilestoneID     *uint  `json:"milestone_id"`
+	Tag             string `json:"tag"`
+	RootOnly        bool   `json:"root_only"`
+
+	// CustomFields matches custom field values by key; multi-select fields match
+	// features that have the given option selected
+	CustomFields map[string]string `json:"custom_fields"`
+}
+
+// FeatureSort orders a feature query by id, title, created_at, updated_at, backlog
+// rank or by a custom field written as cf.<key>. An empty Field keeps ID order.
+type FeatureSort struct {
+	Field string
+	Desc  bool
+}
+
+// ErrInvalidFeatureQuery is returned when a filter or sort names something that does not exist
+var ErrInvalidFeatureQuery = errors.New("invalid feature query")
+
 type FeatureRepository struct {
 	db *gorm.DB
 }
@@ -14,30 +50,95 @@ func NewFeatureRepository(db *gorm.DB) *FeatureRepository {
 	return &FeatureRepository{db: db}
 }
 
+// CreateFeature stores a new feature and records the status it starts in
 func (r *FeatureRepository) CreateFeature(feature *models.Feature) error {
-	return r.db.Create(feature).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(feature).Error; err != nil {
+			return err
+		}
+		return recordStatusChange(tx, feature.ID, "", feature.Status)
+	})
 }
 
 func (r *FeatureRepository) GetFeatureByID(id int) (*models.Feature, error) {
 	var feature models.Feature
-	if err := r.db.Preload("Project").Preload("Assignee").Preload("Tags").Preload("ParentFeature").First(&feature, id).Error; err != nil {
+	if err := r.db.Preload("Project").Preload("Assignee").Preload("Tags").Preload("CustomFieldValues").Preload("ParentFeature").First(&feature, id).Error; err != nil {
 		return nil, err
 	}
 	return &feature, nil
 }
 
+// GetFeaturesByProject lists a project's features in backlog order, unranked ones last
 func (r *FeatureRepository) GetFeaturesByProject(projectID int) ([]models.Feature, error) {
 	var features []models.Feature
-	if err := r.db.Where("project_id = ?", projectID).Preload("Assignee").Preload("Tags").Preload("ParentFeature").Find(&features).Error; err != nil {
+	if err := r.db.Where("project_id = ?", projectID).Preload("Assignee").Preload("Tags").Preload("CustomFieldValues").Preload("ParentFeature").Order("backlog_rank = '', backlog_rank, id").Find(&features).Error; err != nil {
 		return nil, err
 	}
 	return features, nil
 }
 
+// ResolveLegacySubFeatureID maps an ID used by the legacy sub-feature routes onto a
+// feature ID. Those routes only know features by their legacy sub-feature ID, so the
+// ID is never read as a feature ID.
+func (r *FeatureRepository) ResolveLegacySubFeatureID(id uint) (uint, error) {
+	var ids []uint
+	if err := r.db.Model(&models.Feature{}).Where("legacy_sub_feature_id = ?", id).Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, gorm.ErrRecordNotFound
+	}
+	return ids[0], nil
+}
+
+// AssignLegacySubFeatureIDs gives every feature in features that has no legacy
+// sub-feature ID the next unused one, so it can be served by the legacy routes. IDs
+// continue after the old sub_features table, including rows still left in it.
+func (r *FeatureRepository) AssignLegacySubFeatureIDs(features []models.Feature) error {
+	missing := false
+	for _, feature := range features {
+		if feature.LegacySubFeatureID == nil {
+			missing = true
+		}
+	}
+	if !missing {
+		return nil
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var last uint
+		if err := tx.Unscoped().Model(&models.Feature{}).Select("COALESCE(MAX(legacy_sub_feature_id), 0)").Scan(&last).Error; err != nil {
+			return err
+		}
+		if tx.Migrator().HasTable("sub_features") {
+			var reserved uint
+			if err := tx.Table("sub_features").Select("COALESCE(MAX(id), 0)").Scan(&reserved).Error; err != nil {
+				return err
+			}
+			if reserved > last {
+				last = reserved
+			}
+		}
+
+		for i := range features {
+			if features[i].LegacySubFeatureID != nil {
+				continue
+			}
+			last++
+			id := last
+			if err := tx.Model(&models.Feature{}).Where("id = ?", features[i].ID).UpdateColumn("legacy_sub_feature_id", id).Error; err != nil {
+				return err
+			}
+			features[i].LegacySubFeatureID = &id
+		}
+		return nil
+	})
+}
+
 // GetSubfeaturesByParentID gets all features that have a specific parent feature ID
 func (r *FeatureRepository) GetSubfeaturesByParentID(parentID uint) ([]models.Feature, error) {
 	var features []models.Feature
-	if err := r.db.Where("parent_feature_id = ?", parentID).Preload("Assignee").Preload("Tags").Find(&features).Error; err != nil {
+	if err := r.db.Where("parent_feature_id = ?", parentID).Preload("Assignee").Preload("Tags").Preload("CustomFieldValues").Find(&features).Error; err != nil {
 		return nil, err
 	}
 	return features, nil
@@ -46,24 +147,717 @@ func (r *FeatureRepository) GetSubfeaturesByParentID(parentID uint) ([]models.Fe
 // GetRootFeaturesByProject gets all top-level features (without parent) for a project
 func (r *FeatureRepository) GetRootFeaturesByProject(projectID int) ([]models.Feature, error) {
 	var features []models.Feature
-	if err := r.db.Where("project_id = ? AND parent_feature_id IS NULL", projectID).Preload("Assignee").Preload("Tags").Find(&features).Error; err != nil {
+	if err := r.db.Where("project_id = ? AND parent_feature_id IS NULL", projectID).Preload("Assignee").Preload("Tags").Preload("CustomFieldValues").Find(&features).Error; err != nil {
 		return nil, err
 	}
 	return features, nil
 }
 
+// UpdateFeature saves every column of the feature as long as the stored version
+// still equals feature.Version, and bumps the version on success. The overdue flag
+// and the sprint are managed elsewhere and are left alone.
 func (r *FeatureRepository) UpdateFeature(feature *models.Feature) error {
-	return r.db.Save(feature).Error
+	expected := feature.Version
+	feature.Version = expected + 1
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		previous, err := storedStatus(tx, int(feature.ID))
+		if err != nil {
+			return err
+		}
+
+		result := tx.Model(feature).
+			Where("version = ?", expected).
+			Select("*").
+			Omit(clause.Associations, "CreatedAt", "Overdue", "OverdueSince", "SprintID", "BoardRank", "BacklogRank").
+			Updates(feature)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrVersionConflict
+		}
+
+		if feature.Status != previous {
+			return recordStatusChange(tx, feature.ID, previous, feature.Status)
+		}
+		return nil
+	})
+	if err != nil {
+		feature.Version = expected
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrVersionConflict
+		}
+	}
+	return err
+}
+
+// PatchFeature updates only the given columns of a feature at the expected version
+func (r *FeatureRepository) PatchFeature(id int, version uint, updates map[string]interface{}) error {
+	if len(updates) == 0 {
+		return nil
+	}
+	updates["version"] = gorm.Expr("version + 1")
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		status, statusChanged := updates["status"].(models.FeatureStatus)
+		var previous models.FeatureStatus
+		if statusChanged {
+			var err error
+			if previous, err = storedStatus(tx, id); err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return ErrVersionConflict
+				}
+				return err
+			}
+		}
+
+		result := tx.Model(&models.Feature{}).Where("id = ? AND version = ?", id, version).Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrVersionConflict
+		}
+
+		if statusChanged && status != previous {
+			return recordStatusChange(tx, uint(id), previous, status)
+		}
+		return nil
+	})
+}
+
+// storedStatus reads a feature's current status
+func storedStatus(tx *gorm.DB, id int) (models.FeatureStatus, error) {
+	var feature models.Feature
+	if err := tx.Select("id", "status").First(&feature, id).Error; err != nil {
+		return "", err
+	}
+	return feature.Status, nil
+}
+
+// recordStatusChange adds a feature's move into a status to its history and ships the
+// feedback requests waiting on it once it is done
+func recordStatusChange(tx *gorm.DB, featureID uint, from models.FeatureStatus, to models.FeatureStatus) error {
+	if err := tx.Create(&models.FeatureStatusChange{FeatureID: featureID, FromStatus: from, ToStatus: to, ChangedAt: time.Now()}).Error; err != nil {
+		return err
+	}
+	return shipFeedback(tx, featureID, to)
+}
+
+// GetStatusHistory lists a feature's status changes, oldest first
+func (r *FeatureRepository) GetStatusHistory(featureID uint) ([]models.FeatureStatusChange, error) {
+	changes := []models.FeatureStatusChange{}
+	if err := r.db.Where("feature_id = ?", featureID).Order("changed_at, id").Find(&changes).Error; err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// GetProjectStatusHistory returns the status changes of every feature in the
+// project, oldest first, keyed by feature
+func (r *FeatureRepository) GetProjectStatusHistory(projectID int) (map[uint][]models.FeatureStatusChange, error) {
+	var changes []models.FeatureStatusChange
+	err := r.db.Model(&models.FeatureStatusChange{}).
+		Joins("JOIN features ON features.id = feature_status_changes.feature_id AND features.deleted_at IS NULL").
+		Where("features.project_id = ?", projectID).
+		Order("feature_status_changes.changed_at, feature_status_changes.id").
+		Find(&changes).Error
+	if err != nil {
+		return nil, err
+	}
+
+	history := map[uint][]models.FeatureStatusChange{}
+	for _, change := range changes {
+		history[change.FeatureID] = append(history[change.FeatureID], change)
+	}
+	return history, nil
+}
+
+// GetCompletionTimes returns, for each feature of the project that moved into one of
+// the done statuses between since and until (both inclusive), the last time it did
+func (r *FeatureRepository) GetCompletionTimes(projectID int, doneStatuses []models.FeatureStatus, since time.Time, until time.Time) (map[uint]time.Time, error) {
+	var changes []models.FeatureStatusChange
+	err := r.db.Model(&models.FeatureStatusChange{}).
+		Joins("JOIN features ON features.id = feature_status_changes.feature_id AND features.deleted_at IS NULL").
+		Where("features.project_id = ? AND feature_status_changes.to_status IN ?", projectID, doneStatuses).
+		Where("feature_status_changes.changed_at >= ? AND feature_status_changes.changed_at <= ?", since, until).
+		Order("feature_status_changes.changed_at, feature_status_changes.id").
+		Find(&changes).Error
+	if err != nil {
+		return nil, err
+	}
+
+	completed := make(map[uint]time.Time, len(changes))
+	for _, change := range changes {
+		completed[change.FeatureID] = change.ChangedAt
+	}
+	return completed, nil
+}
+
+// DeleteFeatureTree deletes a feature at the expected version together with its tags,
+// tasks, custom field values and links. With cascade the whole subtree goes too;
+// otherwise the children are moved up to the deleted feature's parent. It returns
+// the IDs of every deleted feature.
+func (r *FeatureRepository) DeleteFeatureTree(id uint, version uint, cascade bool) ([]uint, error) {
+	var deleted []uint
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var root models.Feature
+		if err := tx.Select("id", "parent_feature_id", "version").First(&root, id).Error; err != nil {
+			return err
+		}
+		if root.Version != version {
+			return ErrVersionConflict
+		}
+
+		deleted = []uint{id}
+		if cascade {
+			ids, err := (&FeatureRepository{db: tx}).GetSubtreeIDs(id)
+			if err != nil {
+				return err
+			}
+			deleted = ids
+		} else if err := tx.Model(&models.Feature{}).Where("parent_feature_id = ?", id).Updates(map[string]interface{}{
+			"parent_feature_id": root.ParentFeatureID,
+			"version":           gorm.Expr("version + 1"),
+		}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("feature_id IN ?", deleted).Delete(&models.FeatureTag{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("feature_id IN ?", deleted).Delete(&models.CustomFieldValue{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("feature_id IN ?", deleted).Delete(&models.FeatureStatusChange{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("source_id IN ? OR target_id IN ?", deleted, deleted).Delete(&models.FeatureLink{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.FeedbackRequest{}).Where("feature_id IN ? AND status <> ?", deleted, models.FeedbackShipped).UpdateColumn("feature_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("task_id IN (?)", tx.Unscoped().Model(&models.Task{}).Select("id").Where("feature_id IN ?", deleted)).Delete(&models.TaskTag{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("feature_id IN ?", deleted).Delete(&models.Task{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id IN ?", deleted).Delete(&models.Feature{}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deleted, nil
+}
+
+// GetSubtreeIDs returns rootID followed by the IDs of all its descendants, parents before children
+func (r *FeatureRepository) GetSubtreeIDs(rootID uint) ([]uint, error) {
+	var ids []uint
+	// The depth cap stops the recursion should the stored data ever contain a cycle
+	err := r.db.Raw(`
+		WITH RECURSIVE subtree(id, depth) AS (
+			SELECT id, 0 FROM features WHERE id = ? AND deleted_at IS NULL
+			UNION
+			SELECT f.id, s.depth + 1 FROM features f
+			JOIN subtree s ON f.parent_feature_id = s.id
+			WHERE f.deleted_at IS NULL AND s.depth < ?
+		)
+		SELECT id FROM subtree GROUP BY id ORDER BY MIN(depth), id`, rootID, maxTreeDepth).
+		Scan(&ids).Error
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// GetFeaturesByIDs gets the features with the given IDs, skipping unknown ones
+func (r *FeatureRepository) GetFeaturesByIDs(ids []uint) ([]models.Feature, error) {
+	var features []models.Feature
+	if err := r.db.Where("id IN ?", ids).Preload("Tags").Preload("CustomFieldValues").Find(&features).Error; err != nil {
+		return nil, err
+	}
+	return features, nil
 }
 
-func (r *FeatureRepository) DeleteFeature(id int) error {
-	return r.db.Delete(&models.Feature{}, id).Error
+// CopyFeatureTree duplicates a feature and all its descendants below newParentID in
+// projectID, copying tags and optionally tasks. Custom field values are copied when the
+// copy stays in the same project. Statuses the project's workflow lacks become its
+// initial status, and estimates that do not fit its scale are cleared. It returns the
+// copy of the root, or gorm.ErrRecordNotFound when the project does not exist.
+func (r *FeatureRepository) CopyFeatureTree(rootID uint, projectID int, newParentID *uint, includeTasks bool, userID uint) (*models.Feature, error) {
+	var rootCopy models.Feature
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var project models.Project
+		if err := tx.Select("id", "estimate_scale").First(&project, projectID).Error; err != nil {
+			return err
+		}
+		workflow, err := NewWorkflowRepository(tx).GetWorkflow(projectID)
+		if err != nil {
+			return err
+		}
+		fitStatus := func(status models.FeatureStatus) models.FeatureStatus {
+			if _, ok := workflow.Status(status); ok {
+				return status
+			}
+			return workflow.InitialStatus()
+		}
+		fitEstimates := func(estimates models.Estimates) models.Estimates {
+			if estimates.Check(project.EstimateScale) != "" {
+				return models.Estimates{}
+			}
+			return estimates
+		}
+
+		ids, err := (&FeatureRepository{db: tx}).GetSubtreeIDs(rootID)
+		if err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		var features []models.Feature
+		if err := tx.Where("id IN ?", ids).Preload("Tags").Preload("CustomFieldValues").Find(&features).Error; err != nil {
+			return err
+		}
+		byID := make(map[uint]models.Feature, len(features))
+		for _, feature := range features {
+			byID[feature.ID] = feature
+		}
+
+		// ids is ordered parents first, so every parent is copied before its children
+		copies := make(map[uint]uint, len(ids))
+		for _, id := range ids {
+			source := byID[id]
+			parentID := newParentID
+			if id != rootID {
+				mapped := copies[*source.ParentFeatureID]
+				parentID = &mapped
+			}
+
+			estimates := fitEstimates(source.Estimates())
+			feature := models.Feature{
+				ProjectID:       projectID,
+				ParentFeatureID: parentID,
+				Title:           source.Title,
+				Description:     source.Description,
+				Status:          fitStatus(source.Status),
+				Priority:        source.Priority,
+				AssigneeID:      source.AssigneeID,
+				StartDate:       source.StartDate,
+				DueDate:         source.DueDate,
+
+				Estimate:          estimates.Estimate,
+				RemainingEstimate: estimates.RemainingEstimate,
+				ScoringInputs:     source.ScoringInputs,
+			}
+			// Milestones belong to a project, so copies elsewhere start unscheduled
+			if source.ProjectID == projectID {
+				feature.MilestoneID = source.MilestoneID
+			}
+			if err := tx.Omit(clause.Associations).Create(&feature).Error; err != nil {
+				return err
+			}
+			if err := recordStatusChange(tx, feature.ID, "", feature.Status); err != nil {
+				return err
+			}
+			copies[id] = feature.ID
+			if id == rootID {
+				rootCopy = feature
+			}
+
+			// Tags are per project, so copies elsewhere carry tags of the same name there
+			if len(source.Tags) > 0 {
+				names := make([]string, 0, len(source.Tags))
+				for _, tag := range source.Tags {
+					names = append(names, tag.TagName)
+				}
+				tags, err := resolveTags(tx, projectID, names)
+				if err != nil {
+					return err
+				}
+				if err := tx.Omit(clause.Associations).Create(featureTagRows(feature.ID, userID, tags)).Error; err != nil {
+					return err
+				}
+			}
+
+			// Custom fields are defined per project, so values only carry over within one
+			if source.ProjectID == projectID {
+				for _, value := range source.CustomFieldValues {
+					value.ID = 0
+					value.FeatureID = feature.ID
+					if err := tx.Create(&value).Error; err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		if !includeTasks {
+			return nil
+		}
+
+		var tasks []models.Task
+		if err := tx.Where("feature_id IN ?", ids).Find(&tasks).Error; err != nil {
+			return err
+		}
+		for _, task := range tasks {
+			estimates := fitEstimates(task.Estimates())
+			copiedTask := models.Task{
+				TaskType:      task.TaskType,
+				TaskName:      task.TaskName,
+				Description:   task.Description,
+				FeatureID:     copies[task.FeatureID],
+				CreatedByUser: userID,
+				StartDate:     task.StartDate,
+				DueDate:       task.DueDate,
+
+				Estimate:          estimates.Estimate,
+				RemainingEstimate: estimates.RemainingEstimate,
+			}
+			if err := tx.Create(&copiedTask).Error; err != nil {
+				return err
+			}
+
+			var names []string
+			if err := tx.Model(&models.TaskTag{}).Where("task_id = ?", task.ID).Pluck("tag_name", &names).Error; err != nil {
+				return err
+			}
+			tags, err := resolveTags(tx, projectID, names)
+			if err != nil {
+				return err
+			}
+			if len(tags) > 0 {
+				if err := tx.Create(taskKind.rows(copiedTask.ID, userID, tags)).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rootCopy, nil
 }
 
 func (r *FeatureRepository) GetAllFeatures() ([]models.Feature, error) {
 	var features []models.Feature
-	if err := r.db.Preload("Assignee").Preload("Tags").Preload("ParentFeature").Find(&features).Error; err != nil {
+	if err := r.db.Preload("Assignee").Preload("Tags").Preload("CustomFieldValues").Preload("ParentFeature").Find(&features).Error; err != nil {
+		return nil, err
+	}
+	return features, nil
+}
+
+// FindFeatures gets all features matching the filter in ID order
+func (r *FeatureRepository) FindFeatures(filter FeatureFilter) ([]models.Feature, error) {
+	return r.ListFeatures(filter, FeatureSort{})
+}
+
+// ListFeatures gets all features matching the filter in the given order. Custom field
+// filters and sorting need filter.ProjectID, since fields are defined per project.
+func (r *FeatureRepository) ListFeatures(filter FeatureFilter, sort FeatureSort) ([]models.Feature, error) {
+	query := r.db.Model(&models.Feature{})
+	if filter.ProjectID != 0 {
+		query = query.Where("features.project_id = ?", filter.ProjectID)
+	}
+	if filter.Status != "" {
+		query = query.Where("features.status = ?", filter.Status)
+	}
+	if filter.Priority != "" {
+		query = query.Where("features.priority = ?", filter.Priority)
+	}
+	if filter.AssigneeID != nil {
+		query = query.Where("features.assignee_id = ?", *filter.AssigneeID)
+	}
+	if filter.ParentFeatureID != nil {
+		query = query.Where("features.parent_feature_id = ?", *filter.ParentFeatureID)
+	}
+	if filter.MilestoneID != nil {
+		query = query.Where("features.milestone_id = ?", *filter.MilestoneID)
+	}
+	if filter.RootOnly {
+		query = query.Where("features.parent_feature_id IS NULL")
+	}
+	if filter.Tag != "" {
+		query = query.Where("EXISTS (SELECT 1 FROM feature_tags WHERE feature_tags.feature_id = features.id AND feature_tags.tag_name = ?)", filter.Tag)
+	}
+
+	fields := NewCustomFieldRepository(r.db)
+	for key, value := range filter.CustomFields {
+		field, err := r.customField(fields, filter.ProjectID, key)
+		if err != nil {
+			return nil, err
+		}
+
+		exists := "EXISTS (SELECT 1 FROM custom_field_values v WHERE v.feature_id = features.id AND v.field_id = ? AND "
+		switch field.Type {
+		case models.FieldNumber, models.FieldUser:
+			number, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w: custom field %q needs a number", ErrInvalidFeatureQuery, key)
+			}
+			query = query.Where(exists+"v.number_value = ?)", field.ID, number)
+		case models.FieldMultiSelect:
+			query = query.Where(exists+"v.value LIKE ? ESCAPE '\\')", field.ID, jsonContainsPattern(value))
+		default:
+			query = query.Where(exists+"v.text_value = ?)", field.ID, value)
+		}
+	}
+
+	order := "features.id"
+	switch sort.Field {
+	case "", "id":
+	case "title", "created_at", "updated_at":
+		order = "features." + sort.Field
+	case "rank":
+		// Features never ranked always go last
+		order = "features.backlog_rank = '', features.backlog_rank"
+	default:
+		key, ok := strings.CutPrefix(sort.Field, "cf.")
+		if !ok {
+			return nil, fmt.Errorf("%w: cannot sort by %q", ErrInvalidFeatureQuery, sort.Field)
+		}
+		field, err := r.customField(fields, filter.ProjectID, key)
+		if err != nil {
+			return nil, err
+		}
+
+		column := "sort_value.text_value"
+		if field.Type == models.FieldNumber || field.Type == models.FieldUser {
+			column = "sort_value.number_value"
+		}
+		query = query.Joins("LEFT JOIN custom_field_values sort_value ON sort_value.feature_id = features.id AND sort_value.field_id = ?", field.ID)
+		// Features without a value always go last
+		order = column + " IS NULL, " + column
+	}
+	if sort.Desc {
+		order += " DESC"
+	}
+	if order != "features.id" && order != "features.id DESC" {
+		order += ", features.id"
+	}
+
+	var features []models.Feature
+	if err := query.Preload("Assignee").Preload("Tags").Preload("CustomFieldValues").Preload("ParentFeature").Order(order).Find(&features).Error; err != nil {
 		return nil, err
 	}
 	return features, nil
 }
+
+// customField resolves a custom field key used in a query
+func (r *FeatureRepository) customField(fields *CustomFieldRepository, projectID int, key string) (*models.CustomField, error) {
+	if projectID == 0 {
+		return nil, fmt.Errorf("%w: custom fields need a project", ErrInvalidFeatureQuery)
+	}
+	field, err := fields.GetFieldByKey(projectID, key)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: unknown custom field %q", ErrInvalidFeatureQuery, key)
+		}
+		return nil, err
+	}
+	return field, nil
+}
+
+// IsDescendant reports whether featureID sits somewhere below ancestorID in the hierarchy
+func (r *FeatureRepository) IsDescendant(ancestorID uint, featureID uint) (bool, error) {
+	current := featureID
+	visited := map[uint]bool{}
+	for !visited[current] {
+		visited[current] = true
+
+		var feature models.Feature
+		if err := r.db.Select("id", "parent_feature_id").First(&feature, current).Error; err != nil {
+			return false, err
+		}
+		if feature.ParentFeatureID == nil {
+			return false, nil
+		}
+		if *feature.ParentFeatureID == ancestorID {
+			return true, nil
+		}
+		current = *feature.ParentFeatureID
+	}
+	return false, nil
+}
+
+// rollupColumns are the only feature columns a roll-up needs
+var rollupColumns = []string{"id", "title", "status", "priority", "created_at", "estimate", "remaining_estimate"}
+
+// rollupTaskColumns are the only task columns a roll-up needs
+var rollupTaskColumns = []string{"id", "estimate", "remaining_estimate"}
+
+// GetFeatureRollup aggregates every descendant of a feature along with the tasks on
+// the feature and its descendants
+func (r *FeatureRepository) GetFeatureRollup(featureID uint) (*models.Rollup, error) {
+	ids, err := r.GetSubtreeIDs(featureID)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	var root models.Feature
+	if err := r.db.Select("id", "project_id").First(&root, featureID).Error; err != nil {
+		return nil, err
+	}
+	workflow, err := NewWorkflowRepository(r.db).GetWorkflow(root.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The first ID is the feature itself, which is not part of its own roll-up
+	var descendants []models.Feature
+	if len(ids) > 1 {
+		if err := r.db.Select(rollupColumns).Where("id IN ?", ids[1:]).Find(&descendants).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	var tasks []models.Task
+	if err := r.db.Select(rollupTaskColumns).Where("feature_id IN ?", ids).Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+
+	return models.NewRollup(descendants, tasks, workflow), nil
+}
+
+// GetProjectRollup aggregates every feature and task of a project
+func (r *FeatureRepository) GetProjectRollup(projectID int) (*models.Rollup, error) {
+	var features []models.Feature
+	if err := r.db.Select(rollupColumns).Where("project_id = ?", projectID).Find(&features).Error; err != nil {
+		return nil, err
+	}
+
+	workflow, err := NewWorkflowRepository(r.db).GetWorkflow(projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []models.Task
+	projectFeatures := r.db.Model(&models.Feature{}).Select("id").Where("project_id = ?", projectID)
+	if err := r.db.Select(rollupTaskColumns).Where("feature_id IN (?)", projectFeatures).Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+
+	return models.NewRollup(features, tasks, workflow), nil
+}
+
+// GetProjectTasks gets the tasks attached to any feature of the project
+func (r *FeatureRepository) GetProjectTasks(projectID int) ([]models.Task, error) {
+	var tasks []models.Task
+	projectFeatures := r.db.Model(&models.Feature{}).Select("id").Where("project_id = ?", projectID)
+	if err := r.db.Where("feature_id IN (?)", projectFeatures).Order("id").Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// CompleteAncestors applies the project's auto-complete rule starting at startID: a
+// feature whose children are all done is moved to the workflow's first done status,
+// and the check moves on to its parent until a feature with open children is found.
+// It does nothing unless the project has auto_complete_parents enabled, and returns
+// the IDs it marked done. Workflow transitions are not enforced for these moves, but a
+// parent with open blockers stays open when the project enforces blockers.
+func (r *FeatureRepository) CompleteAncestors(startID uint) ([]uint, error) {
+	var completed []uint
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var start models.Feature
+		if err := tx.Select("id", "project_id").First(&start, startID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+
+		var project models.Project
+		if err := tx.Select("id", "auto_complete_parents", "enforce_blockers").First(&project, start.ProjectID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+		if !project.AutoCompleteParents {
+			return nil
+		}
+
+		// Any done-category status counts as finished; parents get the first of them
+		workflow, err := NewWorkflowRepository(tx).GetWorkflow(start.ProjectID)
+		if err != nil {
+			return err
+		}
+		doneStatuses := workflow.DoneStatuses()
+		if len(doneStatuses) == 0 {
+			return nil
+		}
+
+		currentID := &start.ID
+		for depth := 0; currentID != nil && depth < maxTreeDepth; depth++ {
+			var children, open int64
+			if err := tx.Model(&models.Feature{}).Where("parent_feature_id = ?", *currentID).Count(&children).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.Feature{}).Where("parent_feature_id = ? AND status NOT IN ?", *currentID, doneStatuses).Count(&open).Error; err != nil {
+				return err
+			}
+			if children == 0 || open > 0 {
+				return nil
+			}
+
+			var current models.Feature
+			if err := tx.Select("id", "parent_feature_id", "status").First(&current, *currentID).Error; err != nil {
+				return err
+			}
+			if !workflow.IsDone(current.Status) {
+				if project.EnforceBlockers {
+					blockers, err := NewLinkRepository(tx).OpenBlockers([]uint{current.ID})
+					if err != nil {
+						return err
+					}
+					if len(blockers[current.ID]) > 0 {
+						return nil
+					}
+				}
+				err := tx.Model(&models.Feature{}).Where("id = ?", current.ID).Updates(map[string]interface{}{
+					"status":  doneStatuses[0],
+					"version": gorm.Expr("version + 1"),
+				}).Error
+				if err != nil {
+					return err
+				}
+				if err := recordStatusChange(tx, current.ID, current.Status, doneStatuses[0]); err != nil {
+					return err
+				}
+				completed = append(completed, current.ID)
+			}
+			currentID = current.ParentFeatureID
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return completed, nil
+}
+
+// SetBlockedStatus fills in Blocked and OpenBlockers on each feature
+func (r *FeatureRepository) SetBlockedStatus(features []models.Feature) error {
+	ids := make([]uint, 0, len(features))
+	for _, feature := range features {
+		ids = append(ids, feature.ID)
+	}
+
+	blockers, err := NewLinkRepository(r.db).OpenBlockers(ids)
+	if err != nil {
+		return err
+	}
+	for i := range features {
+		blocked := len(blockers[features[i].ID]) > 0
+		features[i].Blocked = &blocked
+		features[i].OpenBlockers = blockers[features[i].ID]
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package repositories
+
+import (
+	"testing"
+
+	"FeaturePlus/models"
+
+	"gorm.io/gorm"
+)
+
+// seedTree creates features 1..len(parents), where parents[i] is the parent of
+// feature i+1 and zero means a root
+func seedTree(t *testing.T, db *gorm.DB, parents ...uint) {
+	t.Helper()
+	for _, parent := range parents {
+		feature := models.Feature{ProjectID: 1, Title: "f"}
+		if parent != 0 {
+			parentID := parent
+			feature.ParentFeatureID = &parentID
+		}
+		if err := db.Omit("Project", "Assignee", "ParentFeature", "Tags").Create(&feature).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestIsDescendant(t *testing.T) {
+	db := openTestDB(t, &models.Feature{})
+	// 1 ─ 2 ─ 3 ─ 4, 1 ─ 5, and 6 on its own
+	seedTree(t, db, 0, 1, 2, 3, 1, 0)
+	repo := NewFeatureRepository(db)
+
+	tests := []struct {
+		ancestor, feature uint
+		want              bool
+	}{
+		{1, 2, true},
+		{1, 4, true},
+		{2, 4, true},
+		{1, 5, true},
+		{4, 1, false},
+		{2, 5, false},
+		{5, 4, false},
+		{6, 4, false},
+		{1, 6, false},
+		{1, 1, false},
+	}
+	for _, tt := range tests {
+		got, err := repo.IsDescendant(tt.ancestor, tt.feature)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("IsDescendant(%d, %d) = %v, want %v", tt.ancestor, tt.feature, got, tt.want)
+		}
+	}
+}
+
+func TestIsDescendantStopsOnCorruptLoop(t *testing.T) {
+	db := openTestDB(t, &models.Feature{})
+	seedTree(t, db, 0, 1, 2)
+	// 1 → 2 → 3 → 1, which the API never allows but must not hang on
+	if err := db.Model(&models.Feature{}).Where("id = 1").UpdateColumn("parent_feature_id", 3).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := NewFeatureRepository(db).IsDescendant(9, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got {
+		t.Error("IsDescendant(9, 3) = true, want false")
+	}
+}
+
+func TestLegacySubFeatureIDsDoNotCollideWithFeatureIDs(t *testing.T) {
+	db := openTestDB(t, &models.Feature{})
+	// Feature 2 is a native child, feature 3 was migrated from sub-feature 2
+	seedTree(t, db, 0, 1, 1)
+	if err := db.Model(&models.Feature{}).Where("id = 3").UpdateColumn("legacy_sub_feature_id", 2).Error; err != nil {
+		t.Fatal(err)
+	}
+	repo := NewFeatureRepository(db)
+
+	if id, err := repo.ResolveLegacySubFeatureID(2); err != nil || id != 3 {
+		t.Fatalf("ResolveLegacySubFeatureID(2) = %d, %v, want the migrated feature 3", id, err)
+	}
+
+	var native models.Feature
+	if err := db.First(&native, 2).Error; err != nil {
+		t.Fatal(err)
+	}
+	children := []models.Feature{native}
+	if err := repo.AssignLegacySubFeatureIDs(children); err != nil {
+		t.Fatal(err)
+	}
+	legacyID := children[0].LegacySubFeatureID
+	if legacyID == nil || *legacyID != 3 {
+		t.Fatalf("native child got legacy ID %v, want 3, the next unused one", legacyID)
+	}
+	if id, err := repo.ResolveLegacySubFeatureID(3); err != nil || id != 2 {
+		t.Errorf("ResolveLegacySubFeatureID(3) = %d, %v, want the native child 2", id, err)
+	}
+	if id, err := repo.ResolveLegacySubFeatureID(2); err != nil || id != 3 {
+		t.Errorf("ResolveLegacySubFeatureID(2) = %d, %v, want the migrated feature 3", id, err)
+	}
+
+	// Assigning again keeps the ID the client already knows
+	if err := repo.AssignLegacySubFeatureIDs(children); err != nil {
+		t.Fatal(err)
+	}
+	if *children[0].LegacySubFeatureID != 3 {
+		t.Errorf("legacy ID changed to %d on a second assignment", *children[0].LegacySubFeatureID)
+	}
+	if _, err := repo.ResolveLegacySubFeatureID(1); err != gorm.ErrRecordNotFound {
+		t.Errorf("ResolveLegacySubFeatureID(1) = %v, want not found for an ID that only exists as a feature ID", err)
+	}
+}
+
+func TestAssignLegacySubFeatureIDsSkipsUnmigratedRows(t *testing.T) {
+	db := openTestDB(t, &models.Feature{})
+	seedTree(t, db, 0, 1)
+	// A sub-feature left behind by the migration still owns its ID
+	if err := db.Exec("CREATE TABLE sub_features (id integer PRIMARY KEY)").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec("INSERT INTO sub_features (id) VALUES (5)").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var child models.Feature
+	if err := db.First(&child, 2).Error; err != nil {
+		t.Fatal(err)
+	}
+	children := []models.Feature{child}
+	if err := NewFeatureRepository(db).AssignLegacySubFeatureIDs(children); err != nil {
+		t.Fatal(err)
+	}
+	if *children[0].LegacySubFeatureID != 6 {
+		t.Errorf("child got legacy ID %d, want 6 after the unmigrated row", *children[0].LegacySubFeatureID)
+	}
+}
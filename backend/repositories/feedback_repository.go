@@ -0,0 +1,367 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"FeaturePlus/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrDuplicateVote is returned when a user votes twice on a request for themselves
+// or for the same customer
+var ErrDuplicateVote = errors.New("already voted on this request")
+
+type FeedbackRepository struct {
+	db *gorm.DB
+}
+
+func NewFeedbackRepository(db *gorm.DB) *FeedbackRepository {
+	return &FeedbackRepository{db: db}
+}
+
+// FeedbackFilter narrows the requests of a project. Merged requests are only listed
+// when asked for by status. Organisation keeps requests with a vote from it.
+type FeedbackFilter struct {
+	ProjectID    int
+	Status       models.FeedbackStatus
+	Visibility   models.FeedbackVisibility
+	Organisation string
+	Newest       bool
+}
+
+// ListRequests returns the matching requests, most voted first unless Newest is set
+func (r *FeedbackRepository) ListRequests(filter FeedbackFilter) ([]models.FeedbackRequest, error) {
+	query := r.db.Where("project_id = ?", filter.ProjectID)
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	} else {
+		query = query.Where("status <> ?", models.FeedbackMerged)
+	}
+	if filter.Visibility != "" {
+		query = query.Where("visibility = ?", filter.Visibility)
+	}
+	if filter.Organisation != "" {
+		query = query.Where("id IN (?)", r.db.Model(&models.FeedbackVote{}).Select("request_id").Where("organisation = ?", filter.Organisation))
+	}
+	if filter.Newest {
+		query = query.Order("created_at DESC, id DESC")
+	} else {
+		query = query.Order("vote_count DESC, id")
+	}
+
+	requests := []models.FeedbackRequest{}
+	if err := query.Find(&requests).Error; err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+func (r *FeedbackRepository) GetRequest(id int) (*models.FeedbackRequest, error) {
+	var request models.FeedbackRequest
+	if err := r.db.First(&request, id).Error; err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+func (r *FeedbackRepository) CreateRequest(request *models.FeedbackRequest) error {
+	return r.db.Create(request).Error
+}
+
+func (r *FeedbackRepository) UpdateRequest(request *models.FeedbackRequest) error {
+	return r.db.Select("*").Omit("CreatedAt").Save(request).Error
+}
+
+// CountMergedInto counts the requests merged into a request
+func (r *FeedbackRepository) CountMergedInto(id uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.FeedbackRequest{}).Where("merged_into_id = ?", id).Count(&count).Error
+	return count, err
+}
+
+// DeleteRequest removes a request with its votes and notifications
+func (r *FeedbackRepository) DeleteRequest(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("request_id = ?", id).Delete(&models.FeedbackVote{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("request_id = ?", id).Delete(&models.FeedbackNotification{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.FeedbackRequest{}, id).Error
+	})
+}
+
+// voteRow is a vote joined with the voter's username
+type voteRow struct {
+	models.FeedbackVote
+	Username string
+}
+
+// GetVotes lists the votes on a request, oldest first
+func (r *FeedbackRepository) GetVotes(requestID uint) ([]models.FeedbackVote, error) {
+	var rows []voteRow
+	err := r.db.Table("feedback_votes").
+		Select("feedback_votes.*, users.username").
+		Joins("LEFT JOIN users ON users.id = feedback_votes.user_id").
+		Where("feedback_votes.request_id = ?", requestID).
+		Order("feedback_votes.created_at, feedback_votes.id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	votes := make([]models.FeedbackVote, 0, len(rows))
+	for _, row := range rows {
+		vote := row.FeedbackVote
+		vote.Username = row.Username
+		votes = append(votes, vote)
+	}
+	return votes, nil
+}
+
+// AddVote records a vote and counts it on its request. Votes on a shipped request are
+// notified at once.
+func (r *FeedbackRepository) AddVote(request *models.FeedbackRequest, vote *models.FeedbackVote) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var existing int64
+		err := tx.Model(&models.FeedbackVote{}).
+			Where("request_id = ? AND user_id = ? AND customer_email = ?", request.ID, vote.UserID, vote.CustomerEmail).
+			Count(&existing).Error
+		if err != nil {
+			return err
+		}
+		if existing > 0 {
+			return ErrDuplicateVote
+		}
+
+		vote.RequestID = request.ID
+		if err := tx.Create(vote).Error; err != nil {
+			return err
+		}
+		if err := countVotes(tx, request); err != nil {
+			return err
+		}
+
+		if request.Status == models.FeedbackShipped && request.FeatureID != nil {
+			var feature models.Feature
+			if err := tx.Select("id", "title").First(&feature, *request.FeatureID).Error; err != nil {
+				return err
+			}
+			return notifyVoters(tx, request, &feature, []models.FeedbackVote{*vote})
+		}
+		return nil
+	})
+}
+
+// RemoveVote withdraws a vote from its request
+func (r *FeedbackRepository) RemoveVote(request *models.FeedbackRequest, voteID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("request_id = ?", request.ID).Delete(&models.FeedbackVote{}, voteID).Error; err != nil {
+			return err
+		}
+		return countVotes(tx, request)
+	})
+}
+
+// GetVote looks a vote up within a request
+func (r *FeedbackRepository) GetVote(requestID uint, voteID int) (*models.FeedbackVote, error) {
+	var vote models.FeedbackVote
+	if err := r.db.Where("request_id = ?", requestID).First(&vote, voteID).Error; err != nil {
+		return nil, err
+	}
+	return &vote, nil
+}
+
+// countVotes stores the number of votes on a request
+func countVotes(tx *gorm.DB, request *models.FeedbackRequest) error {
+	var count int64
+	if err := tx.Model(&models.FeedbackVote{}).Where("request_id = ?", request.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	request.VoteCount = int(count)
+	return tx.Model(request).UpdateColumn("vote_count", request.VoteCount).Error
+}
+
+// MergeRequest folds a duplicate into target. The duplicate's votes move over, except
+// those of voters who already voted on target, and requests merged into the duplicate
+// now point at target. Target takes the duplicate's feature if it has none.
+func (r *FeedbackRepository) MergeRequest(duplicate *models.FeedbackRequest, target *models.FeedbackRequest) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var votes []models.FeedbackVote
+		if err := tx.Where("request_id = ?", duplicate.ID).Find(&votes).Error; err != nil {
+			return err
+		}
+		for _, vote := range votes {
+			var existing int64
+			err := tx.Model(&models.FeedbackVote{}).
+				Where("request_id = ? AND user_id = ? AND customer_email = ?", target.ID, vote.UserID, vote.CustomerEmail).
+				Count(&existing).Error
+			if err != nil {
+				return err
+			}
+			if existing > 0 {
+				err = tx.Delete(&models.FeedbackVote{}, vote.ID).Error
+			} else {
+				err = tx.Model(&models.FeedbackVote{}).Where("id = ?", vote.ID).UpdateColumn("request_id", target.ID).Error
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Model(&models.FeedbackRequest{}).Where("merged_into_id = ?", duplicate.ID).UpdateColumn("merged_into_id", target.ID).Error; err != nil {
+			return err
+		}
+
+		duplicate.Status, duplicate.MergedIntoID = models.FeedbackMerged, &target.ID
+		if err := tx.Select("*").Omit("CreatedAt").Save(duplicate).Error; err != nil {
+			return err
+		}
+		if err := countVotes(tx, duplicate); err != nil {
+			return err
+		}
+		if err := countVotes(tx, target); err != nil {
+			return err
+		}
+
+		if target.FeatureID == nil && duplicate.FeatureID != nil {
+			return linkFeature(tx, target, duplicate.FeatureID)
+		}
+		return nil
+	})
+}
+
+// LinkFeature links a request to a feature, or unlinks it when featureID is nil. A
+// request linked to a feature that is already done ships at once.
+func (r *FeedbackRepository) LinkFeature(request *models.FeedbackRequest, featureID *uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return linkFeature(tx, request, featureID)
+	})
+}
+
+func linkFeature(tx *gorm.DB, request *models.FeedbackRequest, featureID *uint) error {
+	request.FeatureID = featureID
+	if err := tx.Model(request).UpdateColumn("feature_id", featureID).Error; err != nil {
+		return err
+	}
+	if featureID == nil {
+		return nil
+	}
+
+	var feature models.Feature
+	if err := tx.Select("id", "project_id", "title", "status").First(&feature, *featureID).Error; err != nil {
+		return err
+	}
+	workflow, err := NewWorkflowRepository(tx).GetWorkflow(feature.ProjectID)
+	if err != nil {
+		return err
+	}
+	if !workflow.IsDone(feature.Status) {
+		return nil
+	}
+	return shipRequest(tx, request, &feature)
+}
+
+// shipFeedback ships the open requests linked to a feature that has just entered a
+// done status. It runs with every status change, so it returns early when no
+// request is waiting on the feature.
+func shipFeedback(tx *gorm.DB, featureID uint, status models.FeatureStatus) error {
+	var requests []models.FeedbackRequest
+	if err := tx.Where("feature_id = ? AND status = ?", featureID, models.FeedbackOpen).Find(&requests).Error; err != nil {
+		return err
+	}
+	if len(requests) == 0 {
+		return nil
+	}
+
+	var feature models.Feature
+	if err := tx.Select("id", "project_id", "title").First(&feature, featureID).Error; err != nil {
+		return err
+	}
+	workflow, err := NewWorkflowRepository(tx).GetWorkflow(feature.ProjectID)
+	if err != nil {
+		return err
+	}
+	if !workflow.IsDone(status) {
+		return nil
+	}
+
+	for i := range requests {
+		if err := shipRequest(tx, &requests[i], &feature); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shipRequest marks an open request as shipped and notifies everyone who voted for it
+func shipRequest(tx *gorm.DB, request *models.FeedbackRequest, feature *models.Feature) error {
+	if request.Status != models.FeedbackOpen {
+		return nil
+	}
+
+	now := time.Now()
+	request.Status, request.ShippedAt = models.FeedbackShipped, &now
+	err := tx.Model(request).UpdateColumns(map[string]interface{}{"status": request.Status, "shipped_at": now}).Error
+	if err != nil {
+		return err
+	}
+
+	var votes []models.FeedbackVote
+	if err := tx.Where("request_id = ?", request.ID).Find(&votes).Error; err != nil {
+		return err
+	}
+	return notifyVoters(tx, request, feature, votes)
+}
+
+func notifyVoters(tx *gorm.DB, request *models.FeedbackRequest, feature *models.Feature, votes []models.FeedbackVote) error {
+	if len(votes) == 0 {
+		return nil
+	}
+	notifications := make([]models.FeedbackNotification, 0, len(votes))
+	for _, vote := range votes {
+		notifications = append(notifications, models.FeedbackNotification{
+			UserID:        vote.UserID,
+			RequestID:     request.ID,
+			FeatureID:     feature.ID,
+			CustomerEmail: vote.CustomerEmail,
+			CustomerName:  vote.CustomerName,
+			Organisation:  vote.Organisation,
+			Message:       fmt.Sprintf("%q has shipped with %q", request.Title, feature.Title),
+		})
+	}
+	return tx.Create(&notifications).Error
+}
+
+// GetNotifications lists a user's notifications, newest first
+func (r *FeedbackRepository) GetNotifications(userID uint, unreadOnly bool) ([]models.FeedbackNotification, error) {
+	query := r.db.Where("user_id = ?", userID)
+	if unreadOnly {
+		query = query.Where("read_at IS NULL")
+	}
+	notifications := []models.FeedbackNotification{}
+	if err := query.Order("created_at DESC, id DESC").Find(&notifications).Error; err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+// MarkNotificationRead marks one of a user's notifications as read
+func (r *FeedbackRepository) MarkNotificationRead(userID uint, id int) (*models.FeedbackNotification, error) {
+	var notification models.FeedbackNotification
+	if err := r.db.Where("user_id = ?", userID).First(&notification, id).Error; err != nil {
+		return nil, err
+	}
+	if notification.ReadAt == nil {
+		now := time.Now()
+		notification.ReadAt = &now
+		if err := r.db.Model(&notification).UpdateColumn("read_at", now).Error; err != nil {
+			return nil, err
+		}
+	}
+	return &notification, nil
+}
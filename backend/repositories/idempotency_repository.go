@@ -0,0 +1,54 @@
+package repositories
+
+import (
+	"FeaturePlus/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IdempotencyRepository struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyRepository(db *gorm.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{db: db}
+}
+
+// FindActive returns the unexpired record for a user's key, or nil if there is none
+func (r *IdempotencyRepository) FindActive(userID uint, key string, now time.Time) (*models.IdempotencyKey, error) {
+	var records []models.IdempotencyKey
+	if err := r.db.Where("user_id = ? AND idempotency_key = ? AND expires_at > ?", userID, key, now).Limit(1).Find(&records).Error; err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	return &records[0], nil
+}
+
+// Reserve inserts an in-flight record. It returns false when the key is already taken.
+func (r *IdempotencyRepository) Reserve(record *models.IdempotencyKey) (bool, error) {
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// Complete stores the response that was produced for a reserved key
+func (r *IdempotencyRepository) Complete(record *models.IdempotencyKey) error {
+	record.Completed = true
+	return r.db.Save(record).Error
+}
+
+// Release drops a reserved key so the request can be retried
+func (r *IdempotencyRepository) Release(id uint) error {
+	return r.db.Delete(&models.IdempotencyKey{}, id).Error
+}
+
+// DeleteExpired removes every record whose replay window has passed
+func (r *IdempotencyRepository) DeleteExpired(now time.Time) error {
+	return r.db.Where("expires_at <= ?", now).Delete(&models.IdempotencyKey{}).Error
+}
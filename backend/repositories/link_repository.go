@@ -0,0 +1,213 @@
+package repositories
+
+import (
+	"FeaturePlus/models"
+
+	"gorm.io/gorm"
+)
+
+type LinkRepository struct {
+	db *gorm.DB
+}
+
+func NewLinkRepository(db *gorm.DB) *LinkRepository {
+	return &LinkRepository{db: db}
+}
+
+func (r *LinkRepository) CreateLink(link *models.FeatureLink) error {
+	return r.db.Create(link).Error
+}
+
+func (r *LinkRepository) GetLinkByID(id int) (*models.FeatureLink, error) {
+	var link models.FeatureLink
+	if err := r.db.First(&link, id).Error; err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+func (r *LinkRepository) DeleteLink(id uint) error {
+	return r.db.Delete(&models.FeatureLink{}, id).Error
+}
+
+// LinkExists reports whether the link is already stored. relates_to has no direction,
+// so it also matches the reverse link.
+func (r *LinkRepository) LinkExists(sourceID uint, targetID uint, linkType models.LinkType) (bool, error) {
+	query := r.db.Model(&models.FeatureLink{}).Where("type = ?", linkType)
+	if linkType == models.LinkRelatesTo {
+		query = query.Where("(source_id = ? AND target_id = ?) OR (source_id = ? AND target_id = ?)", sourceID, targetID, targetID, sourceID)
+	} else {
+		query = query.Where("source_id = ? AND target_id = ?", sourceID, targetID)
+	}
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// WouldCycle reports whether linking sourceID to targetID would close a loop of
+// links of the same type, i.e. whether targetID already reaches sourceID
+func (r *LinkRepository) WouldCycle(sourceID uint, targetID uint, linkType models.LinkType) (bool, error) {
+	var count int64
+	err := r.db.Raw(`
+		WITH RECURSIVE reachable(id, depth) AS (
+			SELECT ?, 0
+			UNION
+			SELECT l.target_id, r.depth + 1 FROM feature_links l
+			JOIN reachable r ON l.source_id = r.id
+			WHERE l.type = ? AND r.depth < ?
+		)
+		SELECT COUNT(*) FROM reachable WHERE id = ?`, targetID, linkType, maxTreeDepth, sourceID).
+		Scan(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// GetLinksForFeature lists every link of a feature as seen from that feature
+func (r *LinkRepository) GetLinksForFeature(featureID uint) ([]models.FeatureLinkView, error) {
+	var links []models.FeatureLink
+	if err := r.db.Where("source_id = ? OR target_id = ?", featureID, featureID).Order("id").Find(&links).Error; err != nil {
+		return nil, err
+	}
+
+	otherIDs := make([]uint, 0, len(links))
+	for _, link := range links {
+		if link.SourceID == featureID {
+			otherIDs = append(otherIDs, link.TargetID)
+		} else {
+			otherIDs = append(otherIDs, link.SourceID)
+		}
+	}
+	others, err := r.linkedFeatures(otherIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	views := []models.FeatureLinkView{}
+	for i, link := range links {
+		other, ok := others[otherIDs[i]]
+		if !ok {
+			continue
+		}
+		views = append(views, link.ViewFrom(featureID, other))
+	}
+	return views, nil
+}
+
+// OpenBlockers returns, for each of the given features, the IDs of the features that
+// block it and are not yet in a done status of their own project's workflow
+func (r *LinkRepository) OpenBlockers(featureIDs []uint) (map[uint][]uint, error) {
+	blockers := map[uint][]uint{}
+	if len(featureIDs) == 0 {
+		return blockers, nil
+	}
+
+	var rows []struct {
+		TargetID  uint
+		SourceID  uint
+		ProjectID int
+		Status    models.FeatureStatus
+	}
+	err := r.db.Table("feature_links").
+		Select("feature_links.target_id, feature_links.source_id, features.project_id, features.status").
+		Joins("JOIN features ON features.id = feature_links.source_id AND features.deleted_at IS NULL").
+		Where("feature_links.type = ? AND feature_links.target_id IN ?", models.LinkBlocks, featureIDs).
+		Order("feature_links.source_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	workflows := NewWorkflowRepository(r.db)
+	byProject := map[int]*models.Workflow{}
+	for _, row := range rows {
+		workflow, ok := byProject[row.ProjectID]
+		if !ok {
+			workflow, err = workflows.GetWorkflow(row.ProjectID)
+			if err != nil {
+				return nil, err
+			}
+			byProject[row.ProjectID] = workflow
+		}
+		if !workflow.IsDone(row.Status) {
+			blockers[row.TargetID] = append(blockers[row.TargetID], row.SourceID)
+		}
+	}
+	return blockers, nil
+}
+
+// GetProjectGraph returns every link touching a feature of the project, along with
+// the features on both ends, including those in other projects
+func (r *LinkRepository) GetProjectGraph(projectID int) (*models.DependencyGraph, error) {
+	projectFeatures := r.db.Model(&models.Feature{}).Select("id").Where("project_id = ?", projectID)
+
+	edges := []models.FeatureLink{}
+	if err := r.db.Where("source_id IN (?) OR target_id IN (?)", projectFeatures, projectFeatures).Order("id").Find(&edges).Error; err != nil {
+		return nil, err
+	}
+
+	var ids []uint
+	if err := r.db.Model(&models.Feature{}).Where("project_id = ?", projectID).Order("id").Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	seen := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		seen[id] = true
+	}
+	for _, edge := range edges {
+		for _, id := range []uint{edge.SourceID, edge.TargetID} {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	features, err := r.linkedFeatures(ids)
+	if err != nil {
+		return nil, err
+	}
+	blockers, err := r.OpenBlockers(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	graph := &models.DependencyGraph{Nodes: []models.DependencyNode{}, Edges: []models.FeatureLink{}}
+	for _, id := range ids {
+		if feature, ok := features[id]; ok {
+			graph.Nodes = append(graph.Nodes, models.DependencyNode{LinkedFeature: feature, Blocked: len(blockers[id]) > 0})
+		}
+	}
+	for _, edge := range edges {
+		// Links to deleted features are left out
+		if _, ok := features[edge.SourceID]; !ok {
+			continue
+		}
+		if _, ok := features[edge.TargetID]; !ok {
+			continue
+		}
+		graph.Edges = append(graph.Edges, edge)
+	}
+	return graph, nil
+}
+
+// linkedFeatures loads the summary of each feature by ID
+func (r *LinkRepository) linkedFeatures(ids []uint) (map[uint]models.LinkedFeature, error) {
+	features := map[uint]models.LinkedFeature{}
+	if len(ids) == 0 {
+		return features, nil
+	}
+
+	var rows []models.LinkedFeature
+	if err := r.db.Model(&models.Feature{}).Select("id", "project_id", "title", "status").Where("id IN ?", ids).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		features[row.ID] = row
+	}
+	return features, nil
+}
@@ -0,0 +1,46 @@
+package repositories
+
+import (
+	"testing"
+
+	"FeaturePlus/models"
+)
+
+func TestWouldCycle(t *testing.T) {
+	db := openTestDB(t, &models.Feature{}, &models.FeatureLink{})
+	seedTree(t, db, 0, 0, 0, 0)
+	// 1 blocks 2 blocks 3, and 4 duplicates 1
+	links := []models.FeatureLink{
+		{SourceID: 1, TargetID: 2, Type: models.LinkBlocks},
+		{SourceID: 2, TargetID: 3, Type: models.LinkBlocks},
+		{SourceID: 4, TargetID: 1, Type: models.LinkDuplicates},
+	}
+	if err := db.Create(&links).Error; err != nil {
+		t.Fatal(err)
+	}
+	repo := NewLinkRepository(db)
+
+	tests := []struct {
+		name           string
+		source, target uint
+		linkType       models.LinkType
+		want           bool
+	}{
+		{"closes a direct loop", 2, 1, models.LinkBlocks, true},
+		{"closes a longer loop", 3, 1, models.LinkBlocks, true},
+		{"self link", 1, 1, models.LinkBlocks, true},
+		{"same direction", 1, 3, models.LinkBlocks, false},
+		{"unrelated feature", 3, 4, models.LinkBlocks, false},
+		{"other link types do not count", 3, 1, models.LinkDuplicates, false},
+		{"loop of another type", 1, 4, models.LinkDuplicates, true},
+	}
+	for _, tt := range tests {
+		got, err := repo.WouldCycle(tt.source, tt.target, tt.linkType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: WouldCycle(%d, %d, %s) = %v, want %v", tt.name, tt.source, tt.target, tt.linkType, got, tt.want)
+		}
+	}
+}
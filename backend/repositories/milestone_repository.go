@@ -0,0 +1,149 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"FeaturePlus/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrNoNextMilestone is returned when a release with unfinished features is closed
+// and there is no open milestone to move them to
+var ErrNoNextMilestone = errors.New("no open milestone to move unfinished features to")
+
+type MilestoneRepository struct {
+	db *gorm.DB
+}
+
+func NewMilestoneRepository(db *gorm.DB) *MilestoneRepository {
+	return &MilestoneRepository{db: db}
+}
+
+// GetMilestonesByProject lists a project's milestones by target date, undated ones last
+func (r *MilestoneRepository) GetMilestonesByProject(projectID int) ([]models.Milestone, error) {
+	milestones := []models.Milestone{}
+	if err := r.db.Where("project_id = ?", projectID).Order("target_date IS NULL, target_date, id").Find(&milestones).Error; err != nil {
+		return nil, err
+	}
+	return milestones, nil
+}
+
+func (r *MilestoneRepository) GetMilestone(id int) (*models.Milestone, error) {
+	var milestone models.Milestone
+	if err := r.db.First(&milestone, id).Error; err != nil {
+		return nil, err
+	}
+	return &milestone, nil
+}
+
+// GetMilestoneByName looks a milestone up by its name within a project
+func (r *MilestoneRepository) GetMilestoneByName(projectID int, name string) (*models.Milestone, error) {
+	var milestone models.Milestone
+	if err := r.db.Where("project_id = ? AND name = ?", projectID, name).First(&milestone).Error; err != nil {
+		return nil, err
+	}
+	return &milestone, nil
+}
+
+func (r *MilestoneRepository) CreateMilestone(milestone *models.Milestone) error {
+	return r.db.Create(milestone).Error
+}
+
+func (r *MilestoneRepository) UpdateMilestone(milestone *models.Milestone) error {
+	return r.db.Select("*").Omit("CreatedAt").Save(milestone).Error
+}
+
+// DeleteMilestone removes a milestone and unschedules its features
+func (r *MilestoneRepository) DeleteMilestone(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Feature{}).Where("milestone_id = ?", id).Updates(map[string]interface{}{
+			"milestone_id": nil,
+			"version":      gorm.Expr("version + 1"),
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Milestone{}, id).Error
+	})
+}
+
+// GetMilestoneRollup aggregates the features scheduled into a milestone
+func (r *MilestoneRepository) GetMilestoneRollup(milestone *models.Milestone) (*models.Rollup, error) {
+	var features []models.Feature
+	if err := r.db.Select(rollupColumns).Where("milestone_id = ?", milestone.ID).Find(&features).Error; err != nil {
+		return nil, err
+	}
+
+	workflow, err := NewWorkflowRepository(r.db).GetWorkflow(milestone.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []models.Task
+	scope := r.db.Model(&models.Feature{}).Select("id").Where("milestone_id = ?", milestone.ID)
+	if err := r.db.Select(rollupTaskColumns).Where("feature_id IN (?)", scope).Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+
+	return models.NewRollup(features, tasks, workflow), nil
+}
+
+// NextMilestone returns the first open milestone of the project that comes after the
+// given one in target date order, or nil if there is none
+func (r *MilestoneRepository) NextMilestone(milestone *models.Milestone) (*models.Milestone, error) {
+	milestones, err := r.GetMilestonesByProject(milestone.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+
+	after := false
+	for i := range milestones {
+		if milestones[i].ID == milestone.ID {
+			after = true
+			continue
+		}
+		if after && milestones[i].State == models.MilestoneOpen {
+			return &milestones[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// CloseMilestone closes a milestone and moves its features that are not done to
+// nextID. It returns the IDs of the moved features, and ErrNoNextMilestone if some
+// features are unfinished but nextID is nil.
+func (r *MilestoneRepository) CloseMilestone(milestone *models.Milestone, nextID *uint) ([]uint, error) {
+	moved := []uint{}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		workflow, err := NewWorkflowRepository(tx).GetWorkflow(milestone.ProjectID)
+		if err != nil {
+			return err
+		}
+
+		unfinished := tx.Model(&models.Feature{}).Where("milestone_id = ? AND status NOT IN ?", milestone.ID, workflow.DoneStatuses())
+		if err := unfinished.Order("id").Pluck("id", &moved).Error; err != nil {
+			return err
+		}
+		if len(moved) > 0 {
+			if nextID == nil {
+				return ErrNoNextMilestone
+			}
+			if err := tx.Model(&models.Feature{}).Where("id IN ?", moved).Updates(map[string]interface{}{
+				"milestone_id": *nextID,
+				"version":      gorm.Expr("version + 1"),
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		milestone.State = models.MilestoneClosed
+		milestone.ClosedAt = &now
+		return tx.Model(milestone).Updates(map[string]interface{}{"state": milestone.State, "closed_at": milestone.ClosedAt}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return moved, nil
+}
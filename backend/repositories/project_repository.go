@@ -4,6 +4,7 @@ import (
 	"FeaturePlus/models"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ProjectRepository struct {
@@ -37,14 +38,52 @@ func (r *ProjectRepository) GetAllProjects() ([]models.Project, error) {
 	return projects, nil
 }
 
-// UpdateProject updates an existing project
+// UpdateProject updates an existing project if project.Version is still current
 func (r *ProjectRepository) UpdateProject(project *models.Project) error {
-	return r.db.Save(project).Error
+	expected := project.Version
+	project.Version = expected + 1
+	result := r.db.Model(project).
+		Where("version = ?", expected).
+		Select("*").
+		Omit(clause.Associations, "CreatedAt").
+		Updates(project)
+	if result.Error != nil {
+		project.Version = expected
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		project.Version = expected
+		return ErrVersionConflict
+	}
+	return nil
 }
 
-// DeleteProject deletes a project by ID
-func (r *ProjectRepository) DeleteProject(id int) error {
-	return r.db.Delete(&models.Project{}, id).Error
+// PatchProject updates only the given columns of a project at the expected version
+func (r *ProjectRepository) PatchProject(id int, version uint, updates map[string]interface{}) error {
+	if len(updates) == 0 {
+		return nil
+	}
+	updates["version"] = gorm.Expr("version + 1")
+	result := r.db.Model(&models.Project{}).Where("id = ? AND version = ?", id, version).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+// DeleteProject deletes a project by ID at the expected version
+func (r *ProjectRepository) DeleteProject(id int, version uint) error {
+	result := r.db.Where("version = ?", version).Delete(&models.Project{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrVersionConflict
+	}
+	return nil
 }
 
 // GetProjectsByUser gets all projects for a specific user
@@ -0,0 +1,74 @@
+package repositories
+
+import (
+	"FeaturePlus/models"
+
+	"gorm.io/gorm"
+)
+
+// rankedItem is a feature's place in one ordering, such as a board column or the backlog
+type rankedItem struct {
+	ID   uint
+	Rank string `gorm:"column:item_rank"`
+}
+
+// loadRanking reads the features matched by query with their rank in column, in rank
+// order with unranked ones last
+func loadRanking(query *gorm.DB, column string) ([]rankedItem, error) {
+	var items []rankedItem
+	err := query.Model(&models.Feature{}).
+		Select("id, " + column + " AS item_rank").
+		Order(column + " = '', " + column + ", id").
+		Scan(&items).Error
+	return items, err
+}
+
+// rankIndex finds where an item goes: ahead of beforeID, after afterID, or at the end
+// when neither is given. It returns -1 when the named item is not in items.
+func rankIndex(items []rankedItem, beforeID *uint, afterID *uint) int {
+	if beforeID == nil && afterID == nil {
+		return len(items)
+	}
+	for i, item := range items {
+		if beforeID != nil && item.ID == *beforeID {
+			return i
+		}
+		if afterID != nil && item.ID == *afterID {
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// placeRank returns the rank for an item inserted at index among items. When there is
+// no room left there, or an unranked item comes before it, the ranks of items are
+// spread out again and written to column.
+func placeRank(tx *gorm.DB, column string, items []rankedItem, index int) (string, error) {
+	before, after := "", ""
+	if index > 0 {
+		before = items[index-1].Rank
+	}
+	if index < len(items) {
+		after = items[index].Rank
+	}
+
+	// Unranked items sort last, so an item placed after one needs them all ranked
+	crowded := (index > 0 && before == "") || (after != "" && before >= after)
+	if !crowded {
+		if rank := models.RankBetween(before, after); len(rank) <= models.MaxRankLength {
+			return rank, nil
+		}
+	}
+
+	ranks := models.SpreadRanks(len(items) + 1)
+	for i, item := range items {
+		spread := ranks[i]
+		if i >= index {
+			spread = ranks[i+1]
+		}
+		if err := tx.Model(&models.Feature{}).Where("id = ?", item.ID).UpdateColumn(column, spread).Error; err != nil {
+			return "", err
+		}
+	}
+	return ranks[index], nil
+}
@@ -0,0 +1,161 @@
+package repositories
+
+import (
+	"testing"
+
+	"FeaturePlus/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// openTestDB returns an empty in-memory database holding the given models
+func openTestDB(t *testing.T, models ...interface{}) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every connection to :memory: is its own database
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func seedRanked(t *testing.T, db *gorm.DB, ranks ...string) {
+	t.Helper()
+	for _, rank := range ranks {
+		feature := models.Feature{ProjectID: 1, Title: "f", BoardRank: rank}
+		if err := db.Create(&feature).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLoadRankingPutsUnrankedLast(t *testing.T) {
+	db := openTestDB(t, &models.Feature{})
+	seedRanked(t, db, "", "m", "", "c")
+
+	items, err := loadRanking(db, "board_rank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []uint{4, 2, 1, 3}
+	for i, item := range items {
+		if item.ID != want[i] {
+			t.Fatalf("loadRanking order = %v, want %v", items, want)
+		}
+	}
+}
+
+func TestRankIndex(t *testing.T) {
+	items := []rankedItem{{ID: 1, Rank: "a"}, {ID: 2, Rank: "b"}, {ID: 3, Rank: "c"}}
+	id := func(v uint) *uint { return &v }
+	tests := []struct {
+		name     string
+		beforeID *uint
+		afterID  *uint
+		want     int
+	}{
+		{"end", nil, nil, 3},
+		{"before first", id(1), nil, 0},
+		{"after first", nil, id(1), 1},
+		{"after last", nil, id(3), 3},
+		{"missing", id(9), nil, -1},
+	}
+	for _, tt := range tests {
+		if got := rankIndex(items, tt.beforeID, tt.afterID); got != tt.want {
+			t.Errorf("%s: rankIndex = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+// insertAt places a new feature at index in the board ranking and reports whether
+// the column had to be spread out again
+func insertAt(t *testing.T, db *gorm.DB, index int) bool {
+	t.Helper()
+	items, err := loadRanking(db.Model(&models.Feature{}), "board_rank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rank, err := placeRank(db, "board_rank", items, index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rank) > models.MaxRankLength {
+		t.Fatalf("placeRank returned %d digits", len(rank))
+	}
+	seedRanked(t, db, rank)
+
+	spread := false
+	for _, item := range items {
+		var current models.Feature
+		db.First(&current, item.ID)
+		if current.BoardRank != item.Rank {
+			spread = true
+		}
+	}
+	return spread
+}
+
+func checkRanking(t *testing.T, db *gorm.DB, count int) {
+	t.Helper()
+	items, err := loadRanking(db.Model(&models.Feature{}), "board_rank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != count {
+		t.Fatalf("got %d items, want %d", len(items), count)
+	}
+	for i, item := range items {
+		if item.Rank == "" {
+			t.Fatalf("item %d is unranked", item.ID)
+		}
+		if i > 0 && item.Rank <= items[i-1].Rank {
+			t.Fatalf("rank %q does not sort after %q", item.Rank, items[i-1].Rank)
+		}
+	}
+}
+
+func TestPlaceRankSpreadsWhenRanksGrowTooLong(t *testing.T) {
+	tests := []struct {
+		name  string
+		index func(count int) int
+	}{
+		{"at the start", func(int) int { return 0 }},
+		{"after the first", func(int) int { return 1 }},
+		{"before the last", func(count int) int { return count - 1 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t, &models.Feature{})
+			seedRanked(t, db, models.SpreadRanks(2)...)
+
+			spread := false
+			for count := 2; count < 200 && !spread; count++ {
+				spread = insertAt(t, db, tt.index(count))
+				checkRanking(t, db, count+1)
+			}
+			if !spread {
+				t.Fatal("column was never spread out again")
+			}
+		})
+	}
+}
+
+func TestPlaceRankRanksAfterUnranked(t *testing.T) {
+	db := openTestDB(t, &models.Feature{})
+	seedRanked(t, db, "c", "", "")
+
+	if !insertAt(t, db, 3) {
+		t.Fatal("unranked items were not given ranks")
+	}
+	checkRanking(t, db, 4)
+}
@@ -0,0 +1,80 @@
+package repositories
+
+import (
+	"FeaturePlus/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SnapshotRepository struct {
+	db *gorm.DB
+}
+
+func NewSnapshotRepository(db *gorm.DB) *SnapshotRepository {
+	return &SnapshotRepository{db: db}
+}
+
+// TakeSnapshot counts the project's work as it stands now and files it under date
+func (r *SnapshotRepository) TakeSnapshot(projectID int, date string) (*models.ProjectSnapshot, error) {
+	workflow, err := NewWorkflowRepository(r.db).GetWorkflow(projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	var features []models.Feature
+	if err := r.db.Select("id", "status", "estimate", "remaining_estimate").Where("project_id = ?", projectID).Find(&features).Error; err != nil {
+		return nil, err
+	}
+	var tasks []models.Task
+	projectFeatures := r.db.Model(&models.Feature{}).Select("id").Where("project_id = ?", projectID)
+	if err := r.db.Select("id", "feature_id").Where("feature_id IN (?)", projectFeatures).Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+
+	return models.NewProjectSnapshot(projectID, date, features, tasks, workflow), nil
+}
+
+// SaveSnapshots takes a snapshot of every project for date, replacing any taken
+// earlier that day, and returns how many were stored
+func (r *SnapshotRepository) SaveSnapshots(date string) (int, error) {
+	var projectIDs []int
+	if err := r.db.Model(&models.Project{}).Pluck("id", &projectIDs).Error; err != nil {
+		return 0, err
+	}
+
+	for _, projectID := range projectIDs {
+		snapshot, err := r.TakeSnapshot(projectID, date)
+		if err != nil {
+			return 0, err
+		}
+		err = r.db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "project_id"}, {Name: "date"}},
+			DoUpdates: clause.AssignmentColumns([]string{"features", "done_features", "tasks", "done_tasks", "done_points", "remaining_points", "taken_at"}),
+		}).Create(snapshot).Error
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(projectIDs), nil
+}
+
+// GetSnapshots returns a project's snapshots from from to to by date, preceded by the
+// latest one before from if there is one
+func (r *SnapshotRepository) GetSnapshots(projectID int, from string, to string) ([]models.ProjectSnapshot, error) {
+	snapshots := []models.ProjectSnapshot{}
+	var before models.ProjectSnapshot
+	err := r.db.Where("project_id = ? AND date < ?", projectID, from).Order("date DESC").Limit(1).Find(&before).Error
+	if err != nil {
+		return nil, err
+	}
+	if before.ID != 0 {
+		snapshots = append(snapshots, before)
+	}
+
+	var inRange []models.ProjectSnapshot
+	if err := r.db.Where("project_id = ? AND date >= ? AND date <= ?", projectID, from, to).Order("date").Find(&inRange).Error; err != nil {
+		return nil, err
+	}
+	return append(snapshots, inRange...), nil
+}
@@ -0,0 +1,371 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"FeaturePlus/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrNoNextSprint is returned when a sprint with unfinished work is closed and there
+// is no planned sprint to carry the work over to
+var ErrNoNextSprint = errors.New("no planned sprint to carry unfinished work over to")
+
+type SprintRepository struct {
+	db *gorm.DB
+}
+
+func NewSprintRepository(db *gorm.DB) *SprintRepository {
+	return &SprintRepository{db: db}
+}
+
+// GetSprintsByProject lists a project's sprints by start date
+func (r *SprintRepository) GetSprintsByProject(projectID int) ([]models.Sprint, error) {
+	sprints := []models.Sprint{}
+	if err := r.db.Where("project_id = ?", projectID).Order("start_date, id").Find(&sprints).Error; err != nil {
+		return nil, err
+	}
+	return sprints, nil
+}
+
+func (r *SprintRepository) GetSprint(id int) (*models.Sprint, error) {
+	var sprint models.Sprint
+	if err := r.db.First(&sprint, id).Error; err != nil {
+		return nil, err
+	}
+	return &sprint, nil
+}
+
+// GetSprintByName looks a sprint up by its name within a project
+func (r *SprintRepository) GetSprintByName(projectID int, name string) (*models.Sprint, error) {
+	var sprint models.Sprint
+	if err := r.db.Where("project_id = ? AND name = ?", projectID, name).First(&sprint).Error; err != nil {
+		return nil, err
+	}
+	return &sprint, nil
+}
+
+// GetActiveSprint returns the project's active sprint, or nil if none is running
+func (r *SprintRepository) GetActiveSprint(projectID int) (*models.Sprint, error) {
+	var sprint models.Sprint
+	err := r.db.Where("project_id = ? AND state = ?", projectID, models.SprintActive).First(&sprint).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sprint, nil
+}
+
+func (r *SprintRepository) CreateSprint(sprint *models.Sprint) error {
+	return r.db.Create(sprint).Error
+}
+
+func (r *SprintRepository) UpdateSprint(sprint *models.Sprint) error {
+	return r.db.Select("*").Omit("CreatedAt").Save(sprint).Error
+}
+
+// DeleteSprint removes a sprint, takes its features and tasks out of it and drops its
+// scope history
+func (r *SprintRepository) DeleteSprint(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		clear := map[string]interface{}{"sprint_id": nil, "version": gorm.Expr("version + 1")}
+		if err := tx.Model(&models.Feature{}).Where("sprint_id = ?", id).Updates(clear).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&models.Task{}).Where("sprint_id = ?", id).Updates(clear).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("sprint_id = ?", id).Delete(&models.SprintScopeChange{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Sprint{}, id).Error
+	})
+}
+
+// GetSprintItems lists the features and tasks in a sprint
+func (r *SprintRepository) GetSprintItems(sprintID uint) (*models.SprintItems, error) {
+	items := &models.SprintItems{Features: []models.Feature{}, Tasks: []models.Task{}}
+	if err := r.db.Where("sprint_id = ?", sprintID).Preload("Assignee").Preload("Tags").Order("id").Find(&items.Features).Error; err != nil {
+		return nil, err
+	}
+	if err := r.db.Where("sprint_id = ?", sprintID).Order("id").Find(&items.Tasks).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// MoveItem puts a feature or task into the sprint, or takes it out of its sprint when
+// sprint is nil. Changes to sprints that already started are recorded as scope changes
+// for the sprint it leaves and the one it joins.
+func (r *SprintRepository) MoveItem(featureID *uint, taskID *uint, sprint *models.Sprint, userID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var current *uint
+		var query *gorm.DB
+		if featureID != nil {
+			var feature models.Feature
+			if err := tx.Select("id", "sprint_id").First(&feature, *featureID).Error; err != nil {
+				return err
+			}
+			current = feature.SprintID
+			query = tx.Model(&models.Feature{}).Where("id = ?", *featureID)
+		} else {
+			var task models.Task
+			if err := tx.Select("id", "sprint_id").First(&task, *taskID).Error; err != nil {
+				return err
+			}
+			current = task.SprintID
+			query = tx.Model(&models.Task{}).Where("id = ?", *taskID)
+		}
+
+		var target *uint
+		if sprint != nil {
+			target = &sprint.ID
+		}
+		if (current == nil && target == nil) || (current != nil && target != nil && *current == *target) {
+			return nil
+		}
+
+		if err := query.Updates(map[string]interface{}{"sprint_id": target, "version": gorm.Expr("version + 1")}).Error; err != nil {
+			return err
+		}
+
+		record := func(sprintID uint, change models.SprintScopeChangeKind) error {
+			var sprint models.Sprint
+			if err := tx.Select("id", "state").First(&sprint, sprintID).Error; err != nil {
+				return err
+			}
+			if sprint.State != models.SprintActive {
+				return nil
+			}
+			return tx.Create(&models.SprintScopeChange{
+				SprintID:  sprintID,
+				FeatureID: featureID,
+				TaskID:    taskID,
+				Change:    change,
+				UserID:    userID,
+				ChangedAt: time.Now(),
+			}).Error
+		}
+		if current != nil {
+			if err := record(*current, models.SprintItemRemoved); err != nil {
+				return err
+			}
+		}
+		if target != nil {
+			return record(*target, models.SprintItemAdded)
+		}
+		return nil
+	})
+}
+
+// GetScopeChanges lists what entered and left a sprint after it started, oldest first
+func (r *SprintRepository) GetScopeChanges(sprintID uint) ([]models.SprintScopeChange, error) {
+	changes := []models.SprintScopeChange{}
+	if err := r.db.Where("sprint_id = ?", sprintID).Order("changed_at, id").Find(&changes).Error; err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// sprintWork loads the features and tasks of a sprint and reports which are done. A
+// task is done once its feature is done, whether or not that feature is in the sprint.
+func sprintWork(tx *gorm.DB, sprint *models.Sprint) ([]models.Feature, []models.Task, map[uint]bool, error) {
+	workflow, err := NewWorkflowRepository(tx).GetWorkflow(sprint.ProjectID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var features []models.Feature
+	if err := tx.Select("id", "status", "estimate").Where("sprint_id = ?", sprint.ID).Order("id").Find(&features).Error; err != nil {
+		return nil, nil, nil, err
+	}
+	var tasks []models.Task
+	if err := tx.Select("id", "feature_id", "estimate").Where("sprint_id = ?", sprint.ID).Order("id").Find(&tasks).Error; err != nil {
+		return nil, nil, nil, err
+	}
+
+	var taskFeatures []models.Feature
+	featureIDs := make([]uint, 0, len(tasks))
+	for _, task := range tasks {
+		featureIDs = append(featureIDs, task.FeatureID)
+	}
+	if len(featureIDs) > 0 {
+		if err := tx.Select("id", "status").Where("id IN ?", featureIDs).Find(&taskFeatures).Error; err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	done := map[uint]bool{}
+	for _, feature := range append(append([]models.Feature{}, features...), taskFeatures...) {
+		done[feature.ID] = workflow.IsDone(feature.Status)
+	}
+	return features, tasks, done, nil
+}
+
+// GetSprintProgress totals the sprint's current scope and the part of it that is done
+func (r *SprintRepository) GetSprintProgress(sprint *models.Sprint) (*models.SprintProgress, error) {
+	features, tasks, done, err := sprintWork(r.db, sprint)
+	if err != nil {
+		return nil, err
+	}
+
+	progress := &models.SprintProgress{}
+	for _, feature := range features {
+		progress.Scope.Add(false, feature.Estimate)
+		if done[feature.ID] {
+			progress.Done.Add(false, feature.Estimate)
+		}
+	}
+	for _, task := range tasks {
+		progress.Scope.Add(true, task.Estimate)
+		if done[task.FeatureID] {
+			progress.Done.Add(true, task.Estimate)
+		}
+	}
+
+	var counts []struct {
+		Change models.SprintScopeChangeKind
+		Count  int
+	}
+	if err := r.db.Model(&models.SprintScopeChange{}).Select("change, COUNT(*) AS count").Where("sprint_id = ?", sprint.ID).Group("change").Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	for _, count := range counts {
+		if count.Change == models.SprintItemAdded {
+			progress.Added = count.Count
+		} else {
+			progress.Removed = count.Count
+		}
+	}
+	return progress, nil
+}
+
+// StartSprint makes the sprint active and records its current scope as the commitment
+func (r *SprintRepository) StartSprint(sprint *models.Sprint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		features, tasks, _, err := sprintWork(tx, sprint)
+		if err != nil {
+			return err
+		}
+
+		committed := models.SprintTotals{}
+		for _, feature := range features {
+			committed.Add(false, feature.Estimate)
+		}
+		for _, task := range tasks {
+			committed.Add(true, task.Estimate)
+		}
+
+		now := time.Now()
+		sprint.State = models.SprintActive
+		sprint.StartedAt = &now
+		sprint.Committed = committed
+		return tx.Select("*").Omit("CreatedAt").Save(sprint).Error
+	})
+}
+
+// NextPlannedSprint returns the project's first planned sprint by start date other
+// than the given one, or nil if there is none
+func (r *SprintRepository) NextPlannedSprint(sprint *models.Sprint) (*models.Sprint, error) {
+	var next models.Sprint
+	err := r.db.Where("project_id = ? AND state = ? AND id <> ?", sprint.ProjectID, models.SprintPlanned, sprint.ID).
+		Order("start_date, id").First(&next).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &next, nil
+}
+
+// CloseSprint closes the sprint, records what was completed and moves the unfinished
+// features and tasks to nextID. It returns ErrNoNextSprint if work is unfinished but
+// nextID is nil. Completed work stays in the closed sprint. When the next sprint has
+// already started, the carried-over work is recorded as added to its scope by userID.
+func (r *SprintRepository) CloseSprint(sprint *models.Sprint, nextID *uint, userID uint) (*models.SprintReport, error) {
+	report := &models.SprintReport{
+		Sprint:              sprint,
+		CompletedFeatureIDs: []uint{},
+		CompletedTaskIDs:    []uint{},
+		CarriedFeatureIDs:   []uint{},
+		CarriedTaskIDs:      []uint{},
+	}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		features, tasks, done, err := sprintWork(tx, sprint)
+		if err != nil {
+			return err
+		}
+
+		completed, carried := models.SprintTotals{}, models.SprintTotals{}
+		for _, feature := range features {
+			if done[feature.ID] {
+				completed.Add(false, feature.Estimate)
+				report.CompletedFeatureIDs = append(report.CompletedFeatureIDs, feature.ID)
+			} else {
+				carried.Add(false, feature.Estimate)
+				report.CarriedFeatureIDs = append(report.CarriedFeatureIDs, feature.ID)
+			}
+		}
+		for _, task := range tasks {
+			if done[task.FeatureID] {
+				completed.Add(true, task.Estimate)
+				report.CompletedTaskIDs = append(report.CompletedTaskIDs, task.ID)
+			} else {
+				carried.Add(true, task.Estimate)
+				report.CarriedTaskIDs = append(report.CarriedTaskIDs, task.ID)
+			}
+		}
+
+		if len(report.CarriedFeatureIDs) > 0 || len(report.CarriedTaskIDs) > 0 {
+			if nextID == nil {
+				return ErrNoNextSprint
+			}
+			report.NextSprintID = nextID
+			move := map[string]interface{}{"sprint_id": *nextID, "version": gorm.Expr("version + 1")}
+			if len(report.CarriedFeatureIDs) > 0 {
+				if err := tx.Model(&models.Feature{}).Where("id IN ?", report.CarriedFeatureIDs).Updates(move).Error; err != nil {
+					return err
+				}
+			}
+			if len(report.CarriedTaskIDs) > 0 {
+				if err := tx.Model(&models.Task{}).Where("id IN ?", report.CarriedTaskIDs).Updates(move).Error; err != nil {
+					return err
+				}
+			}
+
+			var next models.Sprint
+			if err := tx.Select("id", "state").First(&next, *nextID).Error; err != nil {
+				return err
+			}
+			if next.State == models.SprintActive {
+				now := time.Now()
+				changes := []models.SprintScopeChange{}
+				for i := range report.CarriedFeatureIDs {
+					changes = append(changes, models.SprintScopeChange{SprintID: next.ID, FeatureID: &report.CarriedFeatureIDs[i], Change: models.SprintItemAdded, UserID: userID, ChangedAt: now})
+				}
+				for i := range report.CarriedTaskIDs {
+					changes = append(changes, models.SprintScopeChange{SprintID: next.ID, TaskID: &report.CarriedTaskIDs[i], Change: models.SprintItemAdded, UserID: userID, ChangedAt: now})
+				}
+				if err := tx.Create(&changes).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		now := time.Now()
+		sprint.State = models.SprintClosed
+		sprint.ClosedAt = &now
+		sprint.Completed = completed
+		sprint.CarriedOver = carried
+		return tx.Select("*").Omit("CreatedAt").Save(sprint).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
@@ -2,9 +2,11 @@ package repositories
 
 import (
 	"FeaturePlus/models"
+	"fmt"
 	"strings"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type TagRepository struct {
@@ -15,8 +17,66 @@ func NewTagRepository(db *gorm.DB) *TagRepository {
 	return &TagRepository{db: db}
 }
 
-func (r *TagRepository) CreateTag(tag *models.FeatureTag) error {
-	return r.db.Create(tag).Error
+// taggedKind describes a kind of record that carries tags: the model linking it to
+// its tags, the column in that model naming the record, and how to find the project
+// whose tags it uses. GORM writes updated values back into models, so row and owner
+// hand out a fresh one each time.
+type taggedKind struct {
+	row     func() interface{}
+	owner   func() interface{}
+	column  string
+	project string
+	rows    func(ownerID uint, userID uint, tags []models.Tag) interface{}
+}
+
+var (
+	featureKind = taggedKind{
+		row:     func() interface{} { return &models.FeatureTag{} },
+		owner:   func() interface{} { return &models.Feature{} },
+		column:  "feature_id",
+		project: "SELECT project_id FROM features WHERE id = ? AND deleted_at IS NULL",
+		rows:    func(id uint, userID uint, tags []models.Tag) interface{} { return featureTagRows(id, userID, tags) },
+	}
+	taskKind = taggedKind{
+		row:     func() interface{} { return &models.TaskTag{} },
+		owner:   func() interface{} { return &models.Task{} },
+		column:  "task_id",
+		project: "SELECT features.project_id FROM tasks JOIN features ON features.id = tasks.feature_id WHERE tasks.id = ?",
+		rows: func(id uint, userID uint, tags []models.Tag) interface{} {
+			rows := make([]models.TaskTag, 0, len(tags))
+			for _, tag := range tags {
+				rows = append(rows, models.TaskTag{TagName: tag.Name, TaskID: id, TagID: tag.ID, CreatedByUser: userID})
+			}
+			return rows
+		},
+	}
+	projectKind = taggedKind{
+		row:     func() interface{} { return &models.ProjectTag{} },
+		owner:   func() interface{} { return &models.Project{} },
+		column:  "project_id",
+		project: "SELECT id FROM projects WHERE id = ?",
+		rows: func(id uint, userID uint, tags []models.Tag) interface{} {
+			rows := make([]models.ProjectTag, 0, len(tags))
+			for _, tag := range tags {
+				rows = append(rows, models.ProjectTag{TagName: tag.Name, ProjectID: id, TagID: tag.ID, CreatedByUser: userID})
+			}
+			return rows
+		},
+	}
+
+	taggedKinds = []taggedKind{featureKind, taskKind, projectKind}
+)
+
+// projectOf finds the project whose tags a record uses
+func (k taggedKind) projectOf(tx *gorm.DB, id uint) (int, error) {
+	var projectIDs []int
+	if err := tx.Raw(k.project, id).Scan(&projectIDs).Error; err != nil {
+		return 0, err
+	}
+	if len(projectIDs) == 0 {
+		return 0, gorm.ErrRecordNotFound
+	}
+	return projectIDs[0], nil
 }
 
 func (r *TagRepository) GetTagsByFeatureID(featureID uint) ([]models.FeatureTag, error) {
@@ -27,6 +87,24 @@ func (r *TagRepository) GetTagsByFeatureID(featureID uint) ([]models.FeatureTag,
 	return tags, nil
 }
 
+// GetTagsByTaskID lists a task's tags
+func (r *TagRepository) GetTagsByTaskID(taskID uint) ([]models.TaskTag, error) {
+	tags := []models.TaskTag{}
+	if err := r.db.Where("task_id = ?", taskID).Find(&tags).Error; err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// GetTagsByProjectID lists the tags a project itself carries
+func (r *TagRepository) GetTagsByProjectID(projectID uint) ([]models.ProjectTag, error) {
+	tags := []models.ProjectTag{}
+	if err := r.db.Where("project_id = ?", projectID).Find(&tags).Error; err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
 func (r *TagRepository) GetAllTags() ([]models.FeatureTag, error) {
 	var tags []models.FeatureTag
 	if err := r.db.Find(&tags).Error; err != nil {
@@ -44,6 +122,7 @@ func (r *TagRepository) GetFeaturesByTagName(tagName string) ([]models.Feature,
 		Where("feature_tags.tag_name = ?", tagName).
 		Preload("Assignee").
 		Preload("Tags").
+		Preload("CustomFieldValues").
 		Find(&features).Error; err != nil {
 		return nil, err
 	}
@@ -51,34 +130,357 @@ func (r *TagRepository) GetFeaturesByTagName(tagName string) ([]models.Feature,
 	return features, nil
 }
 
-func (r *TagRepository) DeleteTagsByFeatureID(featureID uint) error {
-	return r.db.Where("feature_id = ?", featureID).Delete(&models.FeatureTag{}).Error
+// GetTasksByTagName lists the tasks with a tag, in any project
+func (r *TagRepository) GetTasksByTagName(tagName string) ([]models.Task, error) {
+	tasks := []models.Task{}
+	err := r.db.
+		Joins("INNER JOIN task_tags ON tasks.id = task_tags.task_id").
+		Where("task_tags.tag_name = ?", tagName).
+		Order("tasks.id").
+		Find(&tasks).Error
+	if err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// GetProjectsByTagName lists the projects that carry a tag themselves
+func (r *TagRepository) GetProjectsByTagName(tagName string) ([]models.Project, error) {
+	projects := []models.Project{}
+	err := r.db.
+		Joins("INNER JOIN project_tags ON projects.id = project_tags.project_id").
+		Where("project_tags.tag_name = ?", tagName).
+		Preload("Owner").
+		Order("projects.id").
+		Find(&projects).Error
+	if err != nil {
+		return nil, err
+	}
+	return projects, nil
 }
 
 func (r *TagRepository) UpdateFeatureTags(featureID uint, userID uint, tagInput string) error {
-	// First delete existing tags for this feature
-	if err := r.DeleteTagsByFeatureID(featureID); err != nil {
-		return err
+	return r.replaceTags(featureKind, featureID, userID, tagInput)
+}
+
+// UpdateTaskTags replaces a task's tags with the tags in the input
+func (r *TagRepository) UpdateTaskTags(taskID uint, userID uint, tagInput string) error {
+	return r.replaceTags(taskKind, taskID, userID, tagInput)
+}
+
+// UpdateProjectTags replaces the tags a project itself carries with the tags in the input
+func (r *TagRepository) UpdateProjectTags(projectID uint, userID uint, tagInput string) error {
+	return r.replaceTags(projectKind, projectID, userID, tagInput)
+}
+
+// replaceTags swaps a record's tags for the tags in the input. Tags are part of the
+// record's representation, so changing them bumps its version.
+func (r *TagRepository) replaceTags(kind taggedKind, id uint, userID uint, tagInput string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		projectID, err := kind.projectOf(tx, id)
+		if err != nil {
+			return err
+		}
+		tags, err := usableTags(tx, kind, projectID, id, processTagString(tagInput))
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Where(kind.column+" = ?", id).Delete(kind.row()).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(kind.owner()).Where("id = ?", id).
+			UpdateColumn("version", gorm.Expr("version + 1")).Error; err != nil {
+			return err
+		}
+
+		if len(tags) == 0 {
+			return nil // No tags to add
+		}
+		return tx.Create(kind.rows(id, userID, tags)).Error
+	})
+}
+
+// AddFeatureTags adds tags to a feature, ignoring ones it already has
+func (r *TagRepository) AddFeatureTags(featureID uint, userID uint, tagNames []string) error {
+	tagStrings := processTagString(strings.Join(tagNames, ","))
+	if len(tagStrings) == 0 {
+		return nil
 	}
 
-	// Process the tag string
-	tagStrings := processTagString(tagInput)
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		projectID, err := featureKind.projectOf(tx, featureID)
+		if err != nil {
+			return err
+		}
+		tags, err := usableTags(tx, featureKind, projectID, featureID, tagStrings)
+		if err != nil {
+			return err
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(featureTagRows(featureID, userID, tags)).Error
+	})
+}
+
+// RemoveFeatureTags removes the named tags from a feature
+func (r *TagRepository) RemoveFeatureTags(featureID uint, tagNames []string) error {
+	tagStrings := processTagString(strings.Join(tagNames, ","))
 	if len(tagStrings) == 0 {
-		return nil // No tags to add
+		return nil
 	}
+	return r.db.Where("feature_id = ? AND tag_name IN ?", featureID, tagStrings).Delete(&models.FeatureTag{}).Error
+}
 
-	// Create tags in batch
-	var tags []models.FeatureTag
-	for _, tagName := range tagStrings {
-		tag := models.FeatureTag{
-			TagName:       tagName,
+// CheckFeatureTags reports a TagError when a tag input would add an archived tag to a
+// feature. featureID is 0 for a feature that does not exist yet.
+func (r *TagRepository) CheckFeatureTags(projectID int, featureID uint, tagInput string) error {
+	return checkArchivedTags(r.db, featureKind, projectID, featureID, processTagString(tagInput))
+}
+
+// checkArchivedTags refuses archived tags among names that the record does not carry yet
+func checkArchivedTags(tx *gorm.DB, kind taggedKind, projectID int, id uint, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	var archived []string
+	err := tx.Model(&models.Tag{}).
+		Where("project_id = ? AND name IN ? AND archived = ?", projectID, names, true).
+		Where("id NOT IN (?)", tx.Model(kind.row()).Select("tag_id").Where(kind.column+" = ?", id)).
+		Pluck("name", &archived).Error
+	if err != nil {
+		return err
+	}
+	if len(archived) > 0 {
+		return &TagError{Message: fmt.Sprintf("archived tags cannot be added: %s", strings.Join(archived, ", "))}
+	}
+	return nil
+}
+
+// usableTags resolves tag names in a project, refusing archived tags the record does
+// not carry yet
+func usableTags(tx *gorm.DB, kind taggedKind, projectID int, id uint, names []string) ([]models.Tag, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	if err := checkArchivedTags(tx, kind, projectID, id, names); err != nil {
+		return nil, err
+	}
+	return resolveTags(tx, projectID, names)
+}
+
+// resolveTags returns the project's tags with the given names, creating the missing ones
+func resolveTags(tx *gorm.DB, projectID int, names []string) ([]models.Tag, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	missing := make([]models.Tag, 0, len(names))
+	for _, name := range names {
+		missing = append(missing, models.Tag{ProjectID: projectID, Name: name})
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&missing).Error; err != nil {
+		return nil, err
+	}
+
+	var tags []models.Tag
+	if err := tx.Where("project_id = ? AND name IN ?", projectID, names).Find(&tags).Error; err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+func featureTagRows(featureID uint, userID uint, tags []models.Tag) []models.FeatureTag {
+	rows := make([]models.FeatureTag, 0, len(tags))
+	for _, tag := range tags {
+		rows = append(rows, models.FeatureTag{
+			TagName:       tag.Name,
 			FeatureID:     featureID,
+			TagID:         tag.ID,
 			CreatedByUser: userID,
+		})
+	}
+	return rows
+}
+
+// tagUsage counts the features, tasks and projects carrying each of a project's tags
+type tagUsage struct {
+	TagID    uint
+	Features int
+	Tasks    int
+	Projects int
+}
+
+// GetProjectTags lists a project's tags by name with the number of features, tasks
+// and projects carrying each. Archived tags are left out unless asked for.
+func (r *TagRepository) GetProjectTags(projectID int, includeArchived bool) ([]models.Tag, error) {
+	query := r.db.Where("project_id = ?", projectID)
+	if !includeArchived {
+		query = query.Where("archived = ?", false)
+	}
+	tags := []models.Tag{}
+	if err := query.Order("name").Find(&tags).Error; err != nil {
+		return nil, err
+	}
+	ids := make([]uint, 0, len(tags))
+	for _, tag := range tags {
+		ids = append(ids, tag.ID)
+	}
+
+	usage, err := r.countUsage(ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range tags {
+		u := usage[tags[i].ID]
+		tags[i].FeatureCount, tags[i].TaskCount, tags[i].ProjectCount = u.Features, u.Tasks, u.Projects
+	}
+	return tags, nil
+}
+
+// CountUsage fills in how many features, tasks and projects carry a tag
+func (r *TagRepository) CountUsage(tag *models.Tag) error {
+	usage, err := r.countUsage([]uint{tag.ID})
+	if err != nil {
+		return err
+	}
+	u := usage[tag.ID]
+	tag.FeatureCount, tag.TaskCount, tag.ProjectCount = u.Features, u.Tasks, u.Projects
+	return nil
+}
+
+func (r *TagRepository) countUsage(tagIDs []uint) (map[uint]tagUsage, error) {
+	usage := map[uint]tagUsage{}
+	if len(tagIDs) == 0 {
+		return usage, nil
+	}
+	count := func(query *gorm.DB, add func(*tagUsage, int)) error {
+		var counts []struct {
+			TagID uint
+			Count int
+		}
+		if err := query.Where("tag_id IN ?", tagIDs).Group("tag_id").Scan(&counts).Error; err != nil {
+			return err
 		}
-		tags = append(tags, tag)
+		for _, c := range counts {
+			u := usage[c.TagID]
+			add(&u, c.Count)
+			usage[c.TagID] = u
+		}
+		return nil
 	}
 
-	return r.db.Create(&tags).Error
+	features := r.db.Model(&models.FeatureTag{}).Select("tag_id, COUNT(*) AS count").
+		Joins("JOIN features ON features.id = feature_tags.feature_id AND features.deleted_at IS NULL")
+	if err := count(features, func(u *tagUsage, n int) { u.Features = n }); err != nil {
+		return nil, err
+	}
+	tasks := r.db.Model(&models.TaskTag{}).Select("tag_id, COUNT(*) AS count")
+	if err := count(tasks, func(u *tagUsage, n int) { u.Tasks = n }); err != nil {
+		return nil, err
+	}
+	projects := r.db.Model(&models.ProjectTag{}).Select("tag_id, COUNT(*) AS count")
+	if err := count(projects, func(u *tagUsage, n int) { u.Projects = n }); err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
+// GetTag looks a tag up within a project
+func (r *TagRepository) GetTag(projectID int, id int) (*models.Tag, error) {
+	var tag models.Tag
+	if err := r.db.Where("project_id = ?", projectID).First(&tag, id).Error; err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// GetTagByName looks a tag up by its name within a project
+func (r *TagRepository) GetTagByName(projectID int, name string) (*models.Tag, error) {
+	var tag models.Tag
+	if err := r.db.Where("project_id = ? AND name = ?", projectID, name).First(&tag).Error; err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+func (r *TagRepository) CreateTag(tag *models.Tag) error {
+	return r.db.Create(tag).Error
+}
+
+// UpdateTag saves a tag. A new name is carried over to everything with the tag.
+func (r *TagRepository) UpdateTag(tag *models.Tag, previousName string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Select("*").Omit("CreatedAt").Save(tag).Error; err != nil {
+			return err
+		}
+		if tag.Name == previousName {
+			return nil
+		}
+		for _, kind := range taggedKinds {
+			if err := bumpTagged(tx, kind, tag.ID); err != nil {
+				return err
+			}
+			if err := tx.Model(kind.row()).Where("tag_id = ?", tag.ID).UpdateColumn("tag_name", tag.Name).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// MergeTags moves everything tagged with source over to target and deletes source.
+// Records that already carry target simply lose source.
+func (r *TagRepository) MergeTags(source *models.Tag, target *models.Tag) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, kind := range taggedKinds {
+			if err := bumpTagged(tx, kind, source.ID); err != nil {
+				return err
+			}
+			err := tx.Where("tag_id = ? AND "+kind.column+" IN (?)", source.ID,
+				tx.Model(kind.row()).Select(kind.column).Where("tag_id = ?", target.ID)).
+				Delete(kind.row()).Error
+			if err != nil {
+				return err
+			}
+			err = tx.Model(kind.row()).Where("tag_id = ?", source.ID).
+				UpdateColumns(map[string]interface{}{"tag_id": target.ID, "tag_name": target.Name}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&models.Tag{}, source.ID).Error
+	})
+}
+
+// DeleteTag removes a tag from everything carrying it and deletes it
+func (r *TagRepository) DeleteTag(tag *models.Tag) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, kind := range taggedKinds {
+			if err := bumpTagged(tx, kind, tag.ID); err != nil {
+				return err
+			}
+			if err := tx.Where("tag_id = ?", tag.ID).Delete(kind.row()).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&models.Tag{}, tag.ID).Error
+	})
+}
+
+// bumpTagged bumps the version of every record of a kind carrying a tag, since tags
+// are part of the record's representation
+func bumpTagged(tx *gorm.DB, kind taggedKind, tagID uint) error {
+	return tx.Model(kind.owner()).
+		Where("id IN (?)", tx.Model(kind.row()).Select(kind.column).Where("tag_id = ?", tagID)).
+		UpdateColumn("version", gorm.Expr("version + 1")).Error
+}
+
+// NormalizeTagName cleans a tag name the way tag inputs are cleaned, and reports
+// whether it is a single valid name
+func NormalizeTagName(name string) (string, bool) {
+	names := processTagString(name)
+	if len(names) != 1 || len(names[0]) > 50 {
+		return "", false
+	}
+	return names[0], true
 }
 
 // processTagString converts a comma/space/semicolon-separated string into a slice of tag names
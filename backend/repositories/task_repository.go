@@ -9,10 +9,10 @@ import (
 type TaskRepository interface {
 	Create(task *models.Task) error
 	Update(task *models.Task) error
-	Delete(taskID uint) error
+	Patch(taskID uint, version uint, updates map[string]interface{}) error
+	Delete(taskID uint, version uint) error
 	GetByID(taskID uint) (*models.Task, error)
 	GetByFeatureID(featureID uint) ([]models.Task, error)
-	GetBySubFeatureID(subFeatureID uint) ([]models.Task, error)
 }
 
 type taskRepository struct {
@@ -27,12 +27,53 @@ func (r *taskRepository) Create(task *models.Task) error {
 	return r.db.Create(task).Error
 }
 
+// Update saves the task if task.Version is still the stored version. The overdue
+// flag and the sprint are managed elsewhere and are left alone.
 func (r *taskRepository) Update(task *models.Task) error {
-	return r.db.Save(task).Error
+	expected := task.Version
+	task.Version = expected + 1
+	result := r.db.Unscoped().Model(task).
+		Where("version = ?", expected).
+		Select("*").
+		Omit("CreatedAt", "Overdue", "OverdueSince", "SprintID").
+		Updates(task)
+	if result.Error != nil {
+		task.Version = expected
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		task.Version = expected
+		return ErrVersionConflict
+	}
+	return nil
 }
 
-func (r *taskRepository) Delete(taskID uint) error {
-	return r.db.Unscoped().Delete(&models.Task{}, taskID).Error
+func (r *taskRepository) Patch(taskID uint, version uint, updates map[string]interface{}) error {
+	if len(updates) == 0 {
+		return nil
+	}
+	updates["version"] = gorm.Expr("version + 1")
+	result := r.db.Unscoped().Model(&models.Task{}).Where("id = ? AND version = ?", taskID, version).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+func (r *taskRepository) Delete(taskID uint, version uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Unscoped().Where("version = ?", version).Delete(&models.Task{}, taskID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrVersionConflict
+		}
+		return tx.Where("task_id = ?", taskID).Delete(&models.TaskTag{}).Error
+	})
 }
 
 func (r *taskRepository) GetByID(taskID uint) (*models.Task, error) {
@@ -46,9 +87,3 @@ func (r *taskRepository) GetByFeatureID(featureID uint) ([]models.Task, error) {
 	err := r.db.Unscoped().Where("feature_id = ?", featureID).Find(&tasks).Error
 	return tasks, err
 }
-
-func (r *taskRepository) GetBySubFeatureID(subFeatureID uint) ([]models.Task, error) {
-	var tasks []models.Task
-	err := r.db.Unscoped().Where("sub_feature_id = ?", subFeatureID).Find(&tasks).Error
-	return tasks, err
-}
@@ -0,0 +1,113 @@
+package repositories
+
+import (
+	"FeaturePlus/models"
+
+	"gorm.io/gorm"
+)
+
+type WorkflowRepository struct {
+	db *gorm.DB
+}
+
+func NewWorkflowRepository(db *gorm.DB) *WorkflowRepository {
+	return &WorkflowRepository{db: db}
+}
+
+// GetWorkflow returns the project's workflow, or the default one if it has none
+func (r *WorkflowRepository) GetWorkflow(projectID int) (*models.Workflow, error) {
+	var statuses []models.WorkflowStatus
+	if err := r.db.Where("project_id = ?", projectID).Order("position, id").Find(&statuses).Error; err != nil {
+		return nil, err
+	}
+	if len(statuses) == 0 {
+		return models.DefaultWorkflow(projectID), nil
+	}
+
+	transitions := []models.WorkflowTransition{}
+	if err := r.db.Where("project_id = ?", projectID).Order("id").Find(&transitions).Error; err != nil {
+		return nil, err
+	}
+
+	return &models.Workflow{
+		ProjectID:   projectID,
+		Statuses:    statuses,
+		Transitions: transitions,
+	}, nil
+}
+
+// ReplaceWorkflow swaps the project's statuses and transitions for the given ones.
+// It fails with a StatusInUseError if features still use a status being removed.
+func (r *WorkflowRepository) ReplaceWorkflow(workflow *models.Workflow) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		keys := make([]models.FeatureStatus, 0, len(workflow.Statuses))
+		for _, status := range workflow.Statuses {
+			keys = append(keys, status.Key)
+		}
+
+		var inUse []string
+		err := tx.Model(&models.Feature{}).
+			Where("project_id = ? AND status NOT IN ?", workflow.ProjectID, keys).
+			Distinct().Order("status").Pluck("status", &inUse).Error
+		if err != nil {
+			return err
+		}
+		if len(inUse) > 0 {
+			return &StatusInUseError{Statuses: inUse}
+		}
+
+		if err := tx.Where("project_id = ?", workflow.ProjectID).Delete(&models.WorkflowTransition{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("project_id = ?", workflow.ProjectID).Delete(&models.WorkflowStatus{}).Error; err != nil {
+			return err
+		}
+
+		for i := range workflow.Statuses {
+			workflow.Statuses[i].ID = 0
+			workflow.Statuses[i].ProjectID = workflow.ProjectID
+		}
+		if err := tx.Create(&workflow.Statuses).Error; err != nil {
+			return err
+		}
+
+		if len(workflow.Transitions) > 0 {
+			for i := range workflow.Transitions {
+				workflow.Transitions[i].ID = 0
+				workflow.Transitions[i].ProjectID = workflow.ProjectID
+			}
+			if err := tx.Create(&workflow.Transitions).Error; err != nil {
+				return err
+			}
+		}
+		workflow.IsDefault = false
+		return nil
+	})
+}
+
+// ResetWorkflow removes the project's workflow so the default applies again. It fails
+// with a StatusInUseError if features use statuses the default does not have.
+func (r *WorkflowRepository) ResetWorkflow(projectID int) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var defaultKeys []models.FeatureStatus
+		for _, status := range models.DefaultWorkflow(projectID).Statuses {
+			defaultKeys = append(defaultKeys, status.Key)
+		}
+
+		var inUse []string
+		err := tx.Model(&models.Feature{}).
+			Where("project_id = ? AND status NOT IN ?", projectID, defaultKeys).
+			Distinct().Order("status").Pluck("status", &inUse).Error
+		if err != nil {
+			return err
+		}
+		if len(inUse) > 0 {
+			return &StatusInUseError{Statuses: inUse}
+		}
+
+		if err := tx.Where("project_id = ?", projectID).Delete(&models.WorkflowTransition{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("project_id = ?", projectID).Delete(&models.WorkflowStatus{}).Error
+	})
+}
@@ -0,0 +1,136 @@
+package repositories
+
+import (
+	"FeaturePlus/models"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// WorklogFilter selects worklogs. Zero fields are not filtered on, and From and To
+// are inclusive YYYY-MM-DD dates.
+type WorklogFilter struct {
+	UserID    uint
+	ProjectID int
+	FeatureID uint
+	TaskID    uint
+	From      string
+	To        string
+}
+
+// ErrTimerRunning is returned when a user starts a timer while one is running
+var ErrTimerRunning = errors.New("a timer is already running; stop it first")
+
+// runningTimer selects a user's timers that have not been stopped
+const runningTimer = "user_id = ? AND started_at IS NOT NULL AND stopped_at IS NULL"
+
+type WorklogRepository struct {
+	db *gorm.DB
+}
+
+func NewWorklogRepository(db *gorm.DB) *WorklogRepository {
+	return &WorklogRepository{db: db}
+}
+
+func (r *WorklogRepository) CreateWorklog(worklog *models.Worklog) error {
+	return r.db.Create(worklog).Error
+}
+
+func (r *WorklogRepository) GetWorklogByID(id uint) (*models.Worklog, error) {
+	var worklog models.Worklog
+	if err := r.db.First(&worklog, id).Error; err != nil {
+		return nil, err
+	}
+	return &worklog, nil
+}
+
+// UpdateWorklog saves every column of the worklog
+func (r *WorklogRepository) UpdateWorklog(worklog *models.Worklog) error {
+	return r.db.Save(worklog).Error
+}
+
+func (r *WorklogRepository) DeleteWorklog(id uint) error {
+	return r.db.Delete(&models.Worklog{}, id).Error
+}
+
+// GetRunningTimer returns the user's running timer, or gorm.ErrRecordNotFound when
+// none is running
+func (r *WorklogRepository) GetRunningTimer(userID uint) (*models.Worklog, error) {
+	var worklog models.Worklog
+	err := r.db.Where(runningTimer, userID).First(&worklog).Error
+	if err != nil {
+		return nil, err
+	}
+	return &worklog, nil
+}
+
+// StartTimer saves a running timer unless the user already has one. The unique index
+// on running timers catches a concurrent start that slips past the check.
+func (r *WorklogRepository) StartTimer(worklog *models.Worklog) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var running int64
+		if err := tx.Model(&models.Worklog{}).Where(runningTimer, worklog.UserID).Count(&running).Error; err != nil {
+			return err
+		}
+		if running > 0 {
+			return ErrTimerRunning
+		}
+		return tx.Create(worklog).Error
+	})
+	if err != nil && !errors.Is(err, ErrTimerRunning) {
+		if _, lookupErr := r.GetRunningTimer(worklog.UserID); lookupErr == nil {
+			return ErrTimerRunning
+		}
+	}
+	return err
+}
+
+// worklogRow is a worklog joined with the names shown next to it
+type worklogRow struct {
+	models.Worklog
+	Username     string
+	FeatureTitle string
+	TaskName     string
+}
+
+// ListWorklogs returns the matching worklogs by date, with the username, feature title
+// and task name filled in. Names of deleted features and tasks are left empty.
+// Running timers are included.
+func (r *WorklogRepository) ListWorklogs(filter WorklogFilter) ([]models.Worklog, error) {
+	query := r.db.Table("worklogs").
+		Select("worklogs.*, users.username, features.title AS feature_title, tasks.task_name").
+		Joins("LEFT JOIN users ON users.id = worklogs.user_id").
+		Joins("LEFT JOIN features ON features.id = worklogs.feature_id AND features.deleted_at IS NULL").
+		Joins("LEFT JOIN tasks ON tasks.id = worklogs.task_id AND tasks.deleted_at IS NULL")
+	if filter.UserID != 0 {
+		query = query.Where("worklogs.user_id = ?", filter.UserID)
+	}
+	if filter.ProjectID != 0 {
+		query = query.Where("worklogs.project_id = ?", filter.ProjectID)
+	}
+	if filter.FeatureID != 0 {
+		query = query.Where("worklogs.feature_id = ?", filter.FeatureID)
+	}
+	if filter.TaskID != 0 {
+		query = query.Where("worklogs.task_id = ?", filter.TaskID)
+	}
+	if filter.From != "" {
+		query = query.Where("worklogs.date >= ?", filter.From)
+	}
+	if filter.To != "" {
+		query = query.Where("worklogs.date <= ?", filter.To)
+	}
+
+	var rows []worklogRow
+	if err := query.Order("worklogs.date, worklogs.id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	worklogs := make([]models.Worklog, 0, len(rows))
+	for _, row := range rows {
+		worklog := row.Worklog
+		worklog.Username, worklog.FeatureTitle, worklog.TaskName = row.Username, row.FeatureTitle, row.TaskName
+		worklogs = append(worklogs, worklog)
+	}
+	return worklogs, nil
+}